@@ -0,0 +1,56 @@
+package tea
+
+import "github.com/muesli/termenv"
+
+// ColorProfile is the range of colors a terminal supports.
+type ColorProfile int
+
+// Color profiles, from the fewest colors to the most.
+const (
+	// NoColor means the output doesn't support colors at all, such as when
+	// it's not a terminal or NO_COLOR is set.
+	NoColor ColorProfile = iota
+	// ANSI is the 16 basic ANSI colors.
+	ANSI
+	// ANSI256 is the extended palette of 256 colors.
+	ANSI256
+	// TrueColor is 24-bit RGB color.
+	TrueColor
+)
+
+var colorProfileNames = map[ColorProfile]string{
+	NoColor:   "no color",
+	ANSI:      "ansi",
+	ANSI256:   "ansi256",
+	TrueColor: "truecolor",
+}
+
+// String returns a string representation of the color profile.
+func (c ColorProfile) String() string {
+	return colorProfileNames[c]
+}
+
+// ColorProfileMsg reports the range of colors the terminal supports, so that
+// styles can be adapted to it. It's sent to Update once when the program
+// starts.
+//
+// The profile is detected from the environment, such as the TERM and
+// COLORTERM variables, and takes NO_COLOR and CLICOLOR_FORCE into account.
+type ColorProfileMsg struct {
+	Profile ColorProfile
+}
+
+// ColorProfile returns the range of colors the program's output supports. See
+// ColorProfileMsg for how it's detected.
+func (p *Program) ColorProfile() ColorProfile {
+	switch p.output.Profile {
+	case termenv.TrueColor:
+		return TrueColor
+	case termenv.ANSI256:
+		return ANSI256
+	case termenv.ANSI:
+		return ANSI
+	default:
+		return NoColor
+	}
+}
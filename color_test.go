@@ -0,0 +1,47 @@
+package tea
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestColorProfile(t *testing.T) {
+	tt := []struct {
+		name     string
+		env      []string
+		expected ColorProfile
+	}{
+		{"not a terminal", []string{"TERM=xterm-256color", "COLORTERM=truecolor"}, NoColor},
+		{"forced", []string{"CLICOLOR_FORCE=1"}, ANSI},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := NewProgram(nil, WithOutput(&buf), WithEnvironment(tc.env))
+			if c := p.ColorProfile(); c != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, c)
+			}
+		})
+	}
+}
+
+func TestColorProfileMsg(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(ColorProfileMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithEnvironment([]string{"CLICOLOR_FORCE=1"}))
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	last := m.msgs[len(m.msgs)-1]
+	if last != (ColorProfileMsg{Profile: ANSI}) {
+		t.Fatalf("expected the ansi color profile to be reported, got %#v", last)
+	}
+}
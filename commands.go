@@ -1,6 +1,8 @@
 package tea
 
 import (
+	"errors"
+	"sync/atomic"
 	"time"
 )
 
@@ -43,6 +45,121 @@ func Sequence(cmds ...Cmd) Cmd {
 // sequenceMsg is used internally to run the given commands in order.
 type sequenceMsg []Cmd
 
+// BarrierMsg is sent to Update by a barrier created with Barrier once the
+// commands in flight have settled. Tag is the tag the barrier was created
+// with.
+type BarrierMsg struct {
+	Tag string
+}
+
+// Barrier returns a command that waits for all the commands in flight to
+// return, and for their messages to be handled by Update, and then sends a
+// BarrierMsg with the given tag. It lets you sequence the phases of a
+// program, such as loading several things at once with Batch before switching
+// screens:
+//
+//	return m, tea.Batch(loadUser, loadSettings, tea.Barrier("loaded"))
+//
+// Commands returned while handling those messages, including the ones in
+// batches and sequences, are waited for too. Note that the barrier waits
+// until no command at all is in flight, so it's best-effort: commands
+// returned after the barrier delay it as well, and a program that keeps
+// commands running all the time, such as a ticker, never gets past it.
+func Barrier(tag string) Cmd {
+	return func() Msg {
+		return barrierMsg{tag: tag}
+	}
+}
+
+// barrierMsg is an internal message that registers a barrier. You can send a
+// barrierMsg with Barrier.
+type barrierMsg struct {
+	tag string
+}
+
+// CmdTag identifies a command made cancellable with Cancellable.
+type CmdTag uint64
+
+// lastCmdTag is the last tag handed out by Cancellable.
+var lastCmdTag uint64
+
+// Cancellable makes a command cancellable. It returns the command to return
+// from Update in place of cmd, and a tag to cancel it with Cancel, which
+// drops its message if it hasn't been handled yet. This is handy for
+// commands that take a while, like a Tick that's no longer wanted after the
+// program moves on:
+//
+//	m.blinkTag, cmd = tea.Cancellable(tea.Tick(time.Second, blink))
+//	return m, cmd
+//
+//	// later, once the cursor no longer blinks
+//	return m, tea.Cancel(m.blinkTag)
+//
+// Cancelling only keeps the message from Update; the command itself still
+// runs to completion in the background, since there's no way to stop it.
+func Cancellable(cmd Cmd) (CmdTag, Cmd) {
+	tag := CmdTag(atomic.AddUint64(&lastCmdTag, 1))
+	if cmd == nil {
+		return tag, nil
+	}
+	return tag, func() Msg {
+		return cancellableMsg{tag: tag, cmd: cmd}
+	}
+}
+
+// Cancel returns a command that cancels the cancellable command with the
+// given tag, so that its message is dropped instead of being sent to Update.
+// If the message has already been handled, or the tag is unknown, it does
+// nothing. A command that hasn't started running yet, such as one in the
+// same Batch as the Cancel, may start after the Cancel and not be cancelled.
+func Cancel(tag CmdTag) Cmd {
+	return func() Msg {
+		return cancelMsg{tag: tag}
+	}
+}
+
+// PendingCmdsMsg lists the tags of the cancellable commands that are running,
+// in the order they were created. It's sent to Update in response to
+// PendingCmds.
+type PendingCmdsMsg []CmdTag
+
+// PendingCmds is a command that lists the cancellable commands that are
+// running, that is, that haven't been cancelled and whose message hasn't been
+// handled yet. The list is sent to Update in a PendingCmdsMsg.
+func PendingCmds() Msg {
+	return pendingCmdsMsg{}
+}
+
+// cancellableMsg is an internal message that runs a cancellable command. You
+// can send a cancellableMsg with Cancellable.
+type cancellableMsg struct {
+	tag CmdTag
+	cmd Cmd
+}
+
+// cancellableDoneMsg is an internal message that wraps the message returned
+// by a cancellable command.
+type cancellableDoneMsg struct {
+	tag CmdTag
+	msg Msg
+}
+
+// cancelMsg is an internal message that cancels a cancellable command. You
+// can send a cancelMsg with Cancel.
+type cancelMsg struct {
+	tag CmdTag
+}
+
+// pendingCmdsMsg is an internal message that lists the cancellable commands.
+// You can send a pendingCmdsMsg with PendingCmds.
+type pendingCmdsMsg struct{}
+
+// cmdDoneMsg is an internal message that wraps the message returned by a
+// command, so that the program can keep track of the commands in flight.
+type cmdDoneMsg struct {
+	msg Msg
+}
+
 // Every is a command that ticks in sync with the system clock. So, if you
 // wanted to tick with the system clock every second, minute or hour you
 // could use this. It's also handy for having different things tick in sync.
@@ -170,3 +287,124 @@ func Sequentially(cmds ...Cmd) Cmd {
 		return nil
 	}
 }
+
+// ErrCmdTimeout is returned by RunCmdTimeout when the command doesn't return
+// in time.
+var ErrCmdTimeout = errors.New("command timed out")
+
+// runCmdTimeout is how long RunCmd waits for a command to return.
+const runCmdTimeout = 10 * time.Second
+
+// RunCmd runs the given command to completion, outside of a program, and
+// returns its message. It's meant for testing commands:
+//
+//	msg := tea.RunCmd(tea.Tick(time.Millisecond, func(t time.Time) tea.Msg {
+//	    return tickMsg(t)
+//	}))
+//	if _, ok := msg.(tickMsg); !ok {
+//	    t.Fatalf("expected a tick, got %#v", msg)
+//	}
+//
+// The commands of a batch or a sequence are run too, concurrently or in order
+// like a program would, and their messages are returned as a []Msg in the
+// order of the commands. Nil messages are left out. RunCmd gives up on
+// commands that take longer than 10 seconds in total and returns nil; use
+// RunCmdTimeout to choose the timeout and tell this apart.
+func RunCmd(cmd Cmd) Msg {
+	msg, _ := RunCmdTimeout(cmd, runCmdTimeout)
+	return msg
+}
+
+// RunCmdTimeout is like RunCmd, but gives up after the given timeout and
+// returns ErrCmdTimeout. The command is left running in the background.
+func RunCmdTimeout(cmd Cmd, timeout time.Duration) (Msg, error) {
+	if cmd == nil {
+		return nil, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	return runCmd(cmd, timer.C)
+}
+
+// runCmd runs the given command and, if it returns a batch or a sequence,
+// the commands in it, until they're done or the timeout fires.
+func runCmd(cmd Cmd, timeout <-chan time.Time) (Msg, error) {
+	ch := make(chan Msg, 1)
+	go func() {
+		ch <- cmd()
+	}()
+
+	var msg Msg
+	select {
+	case msg = <-ch:
+	case <-timeout:
+		return nil, ErrCmdTimeout
+	}
+
+	switch msg := msg.(type) {
+	case BatchMsg:
+		return runBatch(msg, timeout)
+	case sequenceMsg:
+		var msgs []Msg
+		for _, cmd := range msg {
+			if cmd == nil {
+				continue
+			}
+			m, err := runCmd(cmd, timeout)
+			if err != nil {
+				return nil, err
+			}
+			msgs = appendMsg(msgs, m)
+		}
+		return msgs, nil
+	case cancellableMsg:
+		// There's no program to cancel it, so it's like any other command.
+		return runCmd(msg.cmd, timeout)
+	}
+	return msg, nil
+}
+
+// runBatch runs the commands of a batch concurrently and returns their
+// messages in the order of the commands.
+func runBatch(cmds []Cmd, timeout <-chan time.Time) (Msg, error) {
+	type result struct {
+		msg Msg
+		err error
+	}
+
+	results := make([]chan result, len(cmds))
+	for i, cmd := range cmds {
+		results[i] = make(chan result, 1)
+		if cmd == nil {
+			results[i] <- result{}
+			continue
+		}
+		go func(cmd Cmd, ch chan result) {
+			msg, err := runCmd(cmd, timeout)
+			ch <- result{msg, err}
+		}(cmd, results[i])
+	}
+
+	var msgs []Msg
+	for _, ch := range results {
+		r := <-ch
+		if r.err != nil {
+			return nil, r.err
+		}
+		msgs = appendMsg(msgs, r.msg)
+	}
+	return msgs, nil
+}
+
+// appendMsg appends the given message to msgs, flattening the messages of
+// nested batches and sequences, and leaving out nil.
+func appendMsg(msgs []Msg, msg Msg) []Msg {
+	switch msg := msg.(type) {
+	case nil:
+		return msgs
+	case []Msg:
+		return append(msgs, msg...)
+	}
+	return append(msgs, msg)
+}
@@ -1,7 +1,10 @@
 package tea
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -105,3 +108,183 @@ func TestBatch(t *testing.T) {
 		}
 	})
 }
+
+func TestBarrier(t *testing.T) {
+	type loadedMsg string
+
+	load := func(what string, d time.Duration, then ...Cmd) Cmd {
+		return func() Msg {
+			time.Sleep(d)
+			if len(then) > 0 {
+				// Commands returned by commands are waited for too.
+				return Batch(append(then, func() Msg { return loadedMsg(what) })...)()
+			}
+			return loadedMsg(what)
+		}
+	}
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{
+		init: Batch(
+			load("user", 20*time.Millisecond, load("avatar", 20*time.Millisecond)),
+			load("settings", 10*time.Millisecond),
+			Sequence(load("first", 5*time.Millisecond), load("second", 5*time.Millisecond)),
+			Barrier("loaded"),
+		),
+		done: func(msg Msg) bool {
+			_, ok := msg.(BarrierMsg)
+			return ok
+		},
+	}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded []Msg
+	for _, msg := range m.msgs {
+		switch msg.(type) {
+		case loadedMsg, BarrierMsg:
+			loaded = append(loaded, msg)
+		}
+	}
+	if len(loaded) != 6 {
+		t.Fatalf("expected 5 loaded messages and the barrier, got %#v", loaded)
+	}
+	if last := loaded[len(loaded)-1]; !reflect.DeepEqual(last, BarrierMsg{Tag: "loaded"}) {
+		t.Errorf("expected the barrier last, got %#v", loaded)
+	}
+}
+
+type cancelModel struct {
+	slow, fast CmdTag
+	msgs       []Msg
+}
+
+type cancelTestMsg string
+
+func (m *cancelModel) Init() Cmd {
+	var slow, fast Cmd
+	m.slow, slow = Cancellable(Tick(100*time.Millisecond, func(time.Time) Msg {
+		return cancelTestMsg("slow")
+	}))
+	m.fast, fast = Cancellable(func() Msg { return cancelTestMsg("fast") })
+	return Batch(slow, fast)
+}
+
+func (m *cancelModel) Update(msg Msg) (Model, Cmd) {
+	switch msg := msg.(type) {
+	case cancelTestMsg:
+		m.msgs = append(m.msgs, msg)
+		switch msg {
+		case "fast":
+			return m, Sequence(
+				PendingCmds,
+				Cancel(m.slow),
+				Cancel(m.fast), // already done
+				PendingCmds,
+				Tick(200*time.Millisecond, func(time.Time) Msg { return cancelTestMsg("done") }),
+			)
+		case "done":
+			return m, Quit
+		}
+	case PendingCmdsMsg:
+		m.msgs = append(m.msgs, msg)
+	}
+	return m, nil
+}
+
+func (m *cancelModel) View() string {
+	return "success\n"
+}
+
+func TestCancellable(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &cancelModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Msg{
+		cancelTestMsg("fast"),
+		PendingCmdsMsg{m.slow},
+		PendingCmdsMsg{},
+		cancelTestMsg("done"),
+	}
+	if !reflect.DeepEqual(m.msgs, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, m.msgs)
+	}
+}
+
+func TestRunCmd(t *testing.T) {
+	type testMsg int
+
+	msgAfter := func(d time.Duration, msg Msg) Cmd {
+		return func() Msg {
+			time.Sleep(d)
+			return msg
+		}
+	}
+
+	t.Run("nil cmd", func(t *testing.T) {
+		if msg := RunCmd(nil); msg != nil {
+			t.Fatalf("expected nil, got %#v", msg)
+		}
+	})
+
+	t.Run("single cmd", func(t *testing.T) {
+		msg := RunCmd(Tick(time.Millisecond, func(time.Time) Msg { return testMsg(1) }))
+		if msg != testMsg(1) {
+			t.Fatalf("expected %#v, got %#v", testMsg(1), msg)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		msg := RunCmd(Batch(
+			msgAfter(20*time.Millisecond, testMsg(1)),
+			msgAfter(0, testMsg(2)),
+			msgAfter(0, nil),
+			Batch(msgAfter(10*time.Millisecond, testMsg(3)), msgAfter(0, testMsg(4))),
+		))
+		expected := []Msg{testMsg(1), testMsg(2), testMsg(3), testMsg(4)}
+		if !reflect.DeepEqual(msg, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msg)
+		}
+	})
+
+	t.Run("sequence", func(t *testing.T) {
+		msg := RunCmd(Sequence(
+			msgAfter(10*time.Millisecond, testMsg(1)),
+			nil,
+			Sequence(msgAfter(0, testMsg(2))),
+			msgAfter(0, testMsg(3)),
+		))
+		expected := []Msg{testMsg(1), testMsg(2), testMsg(3)}
+		if !reflect.DeepEqual(msg, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msg)
+		}
+	})
+
+	t.Run("cancellable", func(t *testing.T) {
+		_, cmd := Cancellable(msgAfter(0, testMsg(1)))
+		if msg := RunCmd(cmd); msg != testMsg(1) {
+			t.Fatalf("expected %#v, got %#v", testMsg(1), msg)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		cmd := Batch(msgAfter(0, testMsg(1)), msgAfter(time.Second, testMsg(2)))
+		msg, err := RunCmdTimeout(cmd, 20*time.Millisecond)
+		if !errors.Is(err, ErrCmdTimeout) {
+			t.Fatalf("expected ErrCmdTimeout, got %v", err)
+		}
+		if msg != nil {
+			t.Fatalf("expected nil, got %#v", msg)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package tea
+
+import (
+	"os"
+	"strings"
+)
+
+// environ is an environment given as a list of "key=value" strings, like the
+// one returned by os.Environ. It's used in place of the process environment
+// when set with WithEnvironment.
+type environ []string
+
+// Environ returns the environment as a list of "key=value" strings.
+func (e environ) Environ() []string {
+	return []string(e)
+}
+
+// Getenv returns the value of the given variable, or an empty string if it's
+// not set. If a variable is listed more than once, the last value wins.
+func (e environ) Getenv(key string) string {
+	prefix := key + "="
+	for i := len(e) - 1; i >= 0; i-- {
+		if strings.HasPrefix(e[i], prefix) {
+			return e[i][len(prefix):]
+		}
+	}
+	return ""
+}
+
+// getenv returns the value of the given variable in the program's
+// environment, which is the process environment unless one was set with
+// WithEnvironment.
+func (p *Program) getenv(key string) string {
+	if p.environ != nil {
+		return p.environ.Getenv(key)
+	}
+	return os.Getenv(key)
+}
@@ -0,0 +1,56 @@
+package tea
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestEnviron(t *testing.T) {
+	env := environ{"TERM=xterm", "COLORTERM=", "TERM=xterm-256color", "EMPTY="}
+
+	tt := []struct {
+		key      string
+		expected string
+	}{
+		{"TERM", "xterm-256color"},
+		{"COLORTERM", ""},
+		{"EMPTY", ""},
+		{"MISSING", ""},
+		{"TER", ""},
+	}
+
+	for _, tc := range tt {
+		if v := env.Getenv(tc.key); v != tc.expected {
+			t.Errorf("expected %s to be %q, got %q", tc.key, tc.expected, v)
+		}
+	}
+}
+
+func TestWithEnvironment(t *testing.T) {
+	// Outputs which aren't terminals don't get colors, unless the environment
+	// forces them.
+	env := []string{"CLICOLOR_FORCE=1"}
+
+	t.Run("before output", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgram(nil, WithEnvironment(env), WithOutput(&buf))
+		if p.output.Profile != termenv.ANSI {
+			t.Errorf("expected the ansi profile, got %v", p.output.Profile)
+		}
+
+		_, _ = p.output.WriteString("x")
+		if buf.String() != "x" {
+			t.Errorf("expected custom output to be kept, got %q", buf.String())
+		}
+	})
+
+	t.Run("after output", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgram(nil, WithOutput(&buf), WithEnvironment(env))
+		if p.output.Profile != termenv.ANSI {
+			t.Errorf("expected the ansi profile, got %v", p.output.Profile)
+		}
+	})
+}
@@ -30,6 +30,11 @@ func Exec(c ExecCommand, fn ExecCallback) Cmd {
 // the Program resumes. It's useful for spawning other interactive applications
 // such as editors and shells from within a Program.
 //
+// While the command runs it's wired to the Program's terminal, which is
+// restored to its original state beforehand. Once the command exits, the
+// terminal state the Program had set up, such as the altscreen and mouse
+// modes, is re-applied before the callback's message is delivered to Update.
+//
 // To produce the command, pass an *exec.Cmd and a function which returns
 // a message containing the error which may have occurred when running the
 // ExecCommand.
@@ -79,3 +79,19 @@ func TestTeaExec(t *testing.T) {
 		})
 	}
 }
+
+func TestTeaExecRestoresModes(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testExecModel{cmd: "true"}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithMouseAllMotion())
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mouse tracking is enabled on startup and again once the command exits.
+	if n := bytes.Count(buf.Bytes(), []byte("\x1b[?1003h")); n != 2 {
+		t.Errorf("expected all motion to be enabled twice, got %d times: %q", n, buf.String())
+	}
+}
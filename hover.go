@@ -0,0 +1,134 @@
+package tea
+
+import "sync"
+
+// MouseEnterMsg is sent to Update when the mouse pointer enters a hover
+// region registered with Program.AddHoverRegion.
+type MouseEnterMsg struct {
+	ID string
+}
+
+// MouseLeaveMsg is sent to Update when the mouse pointer leaves a hover region
+// registered with Program.AddHoverRegion.
+type MouseLeaveMsg struct {
+	ID string
+}
+
+// AddHoverRegion registers a rectangular region of the terminal, identified by
+// the given id, for which the program sends a MouseEnterMsg to Update when the
+// mouse pointer enters it and a MouseLeaveMsg when it leaves it. The region
+// starts at the given cell coordinates and spans w columns and h rows. Like
+// mouse events, coordinates are relative to the viewport set with
+// WithViewport, if any.
+//
+// Tracking the pointer requires mouse motion events, so this is meant to be
+// used with WithMouseAllMotion (or the EnableMouseAllMotion command). With
+// cell motion the regions are only updated on clicks and drags.
+//
+// When regions overlap, only the topmost region under the pointer is
+// considered hovered. Regions registered later sit on top of those
+// registered earlier. Adding a region with an id that's already registered
+// replaces it and moves it to the top.
+//
+// It's safe to call AddHoverRegion from any goroutine.
+func (p *Program) AddHoverRegion(id string, x, y, w, h int) {
+	p.hover.add(hoverRegion{id: id, x: x, y: y, w: w, h: h})
+}
+
+// RemoveHoverRegion unregisters the hover region with the given id. If the
+// pointer is currently within that region no MouseLeaveMsg is sent.
+//
+// It's safe to call RemoveHoverRegion from any goroutine.
+func (p *Program) RemoveHoverRegion(id string) {
+	p.hover.remove(id)
+}
+
+// hoverRegion is a rectangular region of the terminal tracked for hovering.
+type hoverRegion struct {
+	id         string
+	x, y, w, h int
+}
+
+func (r hoverRegion) contains(x, y int) bool {
+	return x >= r.x && x < r.x+r.w && y >= r.y && y < r.y+r.h
+}
+
+// hoverTracker keeps track of which hover region the mouse pointer is in.
+type hoverTracker struct {
+	mtx     sync.Mutex
+	regions []hoverRegion
+
+	// the id of the region the pointer is in, if hovering
+	active   string
+	hovering bool
+}
+
+func (h *hoverTracker) add(r hoverRegion) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.removeLocked(r.id)
+	h.regions = append(h.regions, r)
+}
+
+func (h *hoverTracker) remove(id string) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.removeLocked(id)
+	if h.hovering && h.active == id {
+		h.hovering = false
+	}
+}
+
+func (h *hoverTracker) removeLocked(id string) {
+	for i, r := range h.regions {
+		if r.id == id {
+			h.regions = append(h.regions[:i], h.regions[i+1:]...)
+			return
+		}
+	}
+}
+
+// filter inserts enter and leave messages ahead of the mouse events which
+// caused the pointer to cross a region's boundaries.
+func (h *hoverTracker) filter(msgs []Msg) []Msg {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if len(h.regions) == 0 && !h.hovering {
+		return msgs
+	}
+
+	out := make([]Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		m, ok := msg.(MouseMsg)
+		if !ok {
+			out = append(out, msg)
+			continue
+		}
+
+		// Find the topmost region under the pointer.
+		var id string
+		var found bool
+		for i := len(h.regions) - 1; i >= 0; i-- {
+			if h.regions[i].contains(m.X, m.Y) {
+				id, found = h.regions[i].id, true
+				break
+			}
+		}
+
+		if h.hovering && (!found || id != h.active) {
+			out = append(out, MouseLeaveMsg{ID: h.active})
+			h.hovering = false
+		}
+		if found && !h.hovering {
+			out = append(out, MouseEnterMsg{ID: id})
+			h.active, h.hovering = id, true
+		}
+
+		out = append(out, msg)
+	}
+
+	return out
+}
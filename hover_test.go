@@ -0,0 +1,70 @@
+package tea
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHoverRegions(t *testing.T) {
+	motion := func(x, y int) MouseMsg {
+		return MouseMsg{X: x, Y: y, Type: MouseMotion}
+	}
+
+	var h hoverTracker
+	h.add(hoverRegion{id: "a", x: 0, y: 0, w: 10, h: 5})
+	h.add(hoverRegion{id: "b", x: 5, y: 0, w: 10, h: 5})
+
+	steps := []struct {
+		name     string
+		msgs     []Msg
+		expected []Msg
+	}{
+		{
+			name:     "outside",
+			msgs:     []Msg{motion(20, 20)},
+			expected: []Msg{motion(20, 20)},
+		},
+		{
+			name:     "enter a",
+			msgs:     []Msg{motion(1, 1)},
+			expected: []Msg{MouseEnterMsg{ID: "a"}, motion(1, 1)},
+		},
+		{
+			name:     "move within a",
+			msgs:     []Msg{motion(2, 2), KeyMsg{Type: KeyEnter}},
+			expected: []Msg{motion(2, 2), KeyMsg{Type: KeyEnter}},
+		},
+		{
+			name:     "overlap prefers topmost",
+			msgs:     []Msg{motion(6, 1)},
+			expected: []Msg{MouseLeaveMsg{ID: "a"}, MouseEnterMsg{ID: "b"}, motion(6, 1)},
+		},
+		{
+			name:     "leave b",
+			msgs:     []Msg{MouseMsg{X: 30, Y: 1, Type: MouseLeft}},
+			expected: []Msg{MouseLeaveMsg{ID: "b"}, MouseMsg{X: 30, Y: 1, Type: MouseLeft}},
+		},
+	}
+
+	for _, step := range steps {
+		if actual := h.filter(step.msgs); !reflect.DeepEqual(step.expected, actual) {
+			t.Fatalf("%s: expected %#v but got %#v", step.name, step.expected, actual)
+		}
+	}
+
+	t.Run("re-adding moves to top", func(t *testing.T) {
+		h.add(hoverRegion{id: "a", x: 0, y: 0, w: 10, h: 5})
+		expected := []Msg{MouseEnterMsg{ID: "a"}, motion(6, 1)}
+		if actual := h.filter([]Msg{motion(6, 1)}); !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("expected %#v but got %#v", expected, actual)
+		}
+	})
+
+	t.Run("removing the hovered region", func(t *testing.T) {
+		h.remove("a")
+		expected := []Msg{MouseEnterMsg{ID: "b"}, motion(6, 1)}
+		if actual := h.filter([]Msg{motion(6, 1)}); !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("expected %#v but got %#v", expected, actual)
+		}
+	})
+}
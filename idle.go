@@ -0,0 +1,64 @@
+package tea
+
+import "time"
+
+// IdleMsg is sent to Update when the user hasn't given any input for the
+// duration set with WithIdleTimeout, and again each time that duration passes
+// with still no input.
+type IdleMsg struct{}
+
+// isUserInput reports whether a message comes from the user typing or using
+// the mouse. Only these keep a program from going idle.
+func isUserInput(msg Msg) bool {
+	switch msg.(type) {
+	case KeyMsg, MouseMsg, MouseHighlightMsg, BulkInputMsg, RawMsg:
+		return true
+	}
+	return false
+}
+
+// idleTimer sends an IdleMsg for every timeout that passes without input.
+type idleTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// newIdleTimer returns a timer that's already counting down, or nil if
+// timeout isn't greater than zero.
+func newIdleTimer(timeout time.Duration) *idleTimer {
+	if timeout <= 0 {
+		return nil
+	}
+	return &idleTimer{timeout: timeout, timer: time.NewTimer(timeout)}
+}
+
+// C returns the channel that fires when the timeout has passed. It's nil for
+// a nil timer, so that receiving from it blocks forever.
+func (t *idleTimer) C() <-chan time.Time {
+	if t == nil {
+		return nil
+	}
+	return t.timer.C
+}
+
+// restart starts counting down from the full timeout again, whether or not
+// the timer has fired.
+func (t *idleTimer) restart() {
+	if t == nil {
+		return
+	}
+	if !t.timer.Stop() {
+		select {
+		case <-t.timer.C:
+		default:
+		}
+	}
+	t.timer.Reset(t.timeout)
+}
+
+// stop stops the timer for good.
+func (t *idleTimer) stop() {
+	if t != nil {
+		t.timer.Stop()
+	}
+}
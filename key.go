@@ -1,6 +1,7 @@
 package tea
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"unicode/utf8"
@@ -563,9 +564,105 @@ var sequences = map[string]Key{
 	"\x1bOD": {Type: KeyLeft, Alt: false},
 }
 
+// RawMsg holds the exact bytes of a read from the input, before they're
+// parsed into key and mouse events. It's only sent when the program is run
+// with WithRawInput or WithRawInputOnly, for things like macro recorders and
+// terminal emulators, which need to know exactly what the terminal sent.
+//
+// A single read may hold several key presses or mouse events, or part of
+// one, if the terminal split it up.
+type RawMsg []byte
+
+// unknownSequenceMsg is an internal message holding an input sequence we
+// didn't recognize. It never reaches Update: the read loop either hands it to
+// the handler set with WithUnknownSequenceHandler or drops it.
+type unknownSequenceMsg string
+
+// BulkInputMsg holds text that arrived all at once, most likely because it
+// was pasted, when bulk input detection is enabled with
+// WithBulkInputDetection. Line breaks are kept as the terminal sent them,
+// usually as carriage returns.
+//
+// It's a guess rather than a paste: paste detection isn't supported, and
+// anything else that sends enough text at once arrives the same way.
+type BulkInputMsg []rune
+
+// String returns the text as a string.
+func (b BulkInputMsg) String() string {
+	return string(b)
+}
+
+// bulkInputThreshold is the number of characters arriving in a single read
+// above which we consider the input to be bulk input rather than typing.
+const bulkInputThreshold = 32
+
+// bulkInput turns the given messages into a single BulkInputMsg if they're all
+// printable characters, spaces, tabs and line breaks, and there are at least
+// bulkInputThreshold of them. Otherwise they're returned as is.
+func bulkInput(msgs []Msg) []Msg {
+	var runes []rune
+	for _, msg := range msgs {
+		k, ok := msg.(KeyMsg)
+		if !ok || k.Alt {
+			return msgs
+		}
+		switch k.Type {
+		case KeyRunes, KeySpace:
+			runes = append(runes, k.Runes...)
+		case KeyEnter:
+			runes = append(runes, '\r')
+		case KeyCtrlJ:
+			runes = append(runes, '\n')
+		case KeyTab:
+			runes = append(runes, '\t')
+		default:
+			return msgs
+		}
+	}
+	if len(runes) < bulkInputThreshold {
+		return msgs
+	}
+	return []Msg{BulkInputMsg(runes)}
+}
+
+// normalizeLineEndings turns the CRLF and CR line endings of bulk input into
+// LF. cr is whether the bulk input before these messages ended with a CR, in
+// which case an LF at the start of the first one is the rest of a CRLF split
+// across reads; whether the last of them does is returned, so that it can be
+// passed on to the next call.
+func normalizeLineEndings(msgs []Msg, cr bool) ([]Msg, bool) {
+	out := msgs[:0]
+	for _, msg := range msgs {
+		b, ok := msg.(BulkInputMsg)
+		if !ok {
+			cr = false
+			out = append(out, msg)
+			continue
+		}
+		if cr && len(b) > 0 && b[0] == '\n' {
+			b = b[1:]
+		}
+		n := make(BulkInputMsg, 0, len(b))
+		for i, r := range b {
+			if r == '\r' {
+				if i+1 < len(b) && b[i+1] == '\n' {
+					continue // the LF comes next
+				}
+				r = '\n'
+			}
+			n = append(n, r)
+		}
+		cr = len(b) > 0 && b[len(b)-1] == '\r'
+		if len(n) > 0 {
+			out = append(out, n)
+		}
+	}
+	return out, cr
+}
+
 // readInputs reads keypress and mouse inputs from a TTY and returns messages
 // containing information about the key or mouse events accordingly.
-func readInputs(input io.Reader) ([]Msg, error) {
+func readInputs(input io.Reader, pixels bool) ([]Msg, error) {
 	var buf [256]byte
 
 	// Read and block
@@ -578,18 +675,98 @@ func readInputs(input io.Reader) ([]Msg, error) {
 	if err != nil {
 		return nil, err
 	}
+	b = dropEchoedModes(b)
+	if len(b) == 0 {
+		return nil, nil
+	}
 
-	// Check if it's a mouse event. For now we're parsing X10-type mouse events
-	// only.
-	mouseEvent, err := parseX10MouseEvents(b)
-	if err == nil {
+	// Check if it's a mouse event, either SGR, X10 or UTF-8 encoded.
+	if mouseEvents, err := parseMouseEvents(b, pixels); err == nil {
 		var m []Msg
-		for _, v := range mouseEvent {
+		for _, v := range mouseEvents {
 			m = append(m, MouseMsg(v))
 		}
 		return m, nil
 	}
 
+	// The buffer may hold mouse events mixed with other input, such as a
+	// keypress arriving together with a click. Split it up and parse each part
+	// on its own.
+	var msgs []Msg
+	for _, part := range splitMouseEvents(b) {
+		if part.mouse {
+			if h, ok := parseHighlightReport(part.buf); ok {
+				msgs = append(msgs, h)
+				continue
+			}
+
+			mouseEvents, err := parseMouseEvents(part.buf, pixels)
+			if err != nil {
+				if corruptMouseEvent(part.buf) {
+					// The terminal driver slipped a line ending into the
+					// event. Drop it rather than report a bogus event.
+					continue
+				}
+				return nil, err
+			}
+			for _, v := range mouseEvents {
+				msgs = append(msgs, MouseMsg(v))
+			}
+			continue
+		}
+
+		keys, err := parseKeys(part.buf)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, keys...)
+	}
+
+	return msgs, nil
+}
+
+// dropEchoedModes removes DEC private mode set and reset sequences, such as the
+// ones enabling mouse tracking, from b. Terminals never send them as input, so
+// when they show up there it's because the terminal echoed what was written to
+// it, which happens when putting it in raw mode failed.
+func dropEchoedModes(b []byte) []byte {
+	if !bytes.Contains(b, []byte("\x1b[?")) {
+		return b
+	}
+
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); {
+		if n := echoedModeLen(b[i:]); n > 0 {
+			i += n
+			continue
+		}
+		out = append(out, b[i])
+		i++
+	}
+	return out
+}
+
+// echoedModeLen returns the length of the DEC private mode set or reset
+// sequence, like "\x1b[?1002h", at the start of b, or zero if there's none.
+func echoedModeLen(b []byte) int {
+	if !bytes.HasPrefix(b, []byte("\x1b[?")) {
+		return 0
+	}
+	for i := 3; i < len(b); i++ {
+		switch c := b[i]; {
+		case c >= '0' && c <= '9', c == ';':
+		case (c == 'h' || c == 'l') && i > 3:
+			return i + 1
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// parseKeys parses a buffer of input which doesn't contain mouse events into
+// key messages and terminal reports.
+func parseKeys(b []byte) ([]Msg, error) {
 	var runeSets [][]rune
 	var runes []rune
 
@@ -602,7 +779,11 @@ func readInputs(input io.Reader) ([]Msg, error) {
 			return nil, errors.New("could not decode rune")
 		}
 
-		if r == '\x1b' && len(runes) > 1 {
+		// The string terminator (ESC \) of an OSC or DCS sequence, such as a
+		// color report or a terminal version, is part of it.
+		st := len(runes) > 1 && runes[0] == '\x1b' && (runes[1] == ']' || runes[1] == 'P') &&
+			i+1 < len(b) && b[i+1] == '\\'
+		if r == '\x1b' && len(runes) > 1 && !st {
 			// a new key sequence has started
 			runeSets = append(runeSets, runes)
 			runes = []rune{}
@@ -610,9 +791,19 @@ func readInputs(input io.Reader) ([]Msg, error) {
 
 		runes = append(runes, r)
 		w = width
+
+		// The string terminator ends an OSC or DCS sequence, so whatever
+		// follows it is separate.
+		if r == '\\' && len(runes) > 3 && runes[len(runes)-2] == '\x1b' &&
+			(runes[1] == ']' || runes[1] == 'P') {
+			runeSets = append(runeSets, runes)
+			runes = []rune{}
+		}
 	}
 	// add the final set of runes we decoded
-	runeSets = append(runeSets, runes)
+	if len(runes) > 0 || len(runeSets) == 0 {
+		runeSets = append(runeSets, runes)
+	}
 
 	if len(runeSets) == 0 {
 		return nil, errors.New("received 0 runes from input")
@@ -626,9 +817,23 @@ func readInputs(input io.Reader) ([]Msg, error) {
 			continue
 		}
 
-		// Is this an unrecognized CSI sequence? If so, ignore it.
+		// Is it a report the terminal sent in response to one of our queries?
+		if msg, ok := parseReport(string(runes)); ok {
+			msgs = append(msgs, msg)
+			continue
+		}
+
+		// Is it a mouse event from the DEC locator?
+		if m, ok := parseDECLocatorEvent(string(runes)); ok {
+			msgs = append(msgs, MouseMsg(m))
+			continue
+		}
+
+		// Is this an unrecognized CSI sequence? If so, pass it on so it can
+		// be handed to the program's unknown sequence handler, if any.
 		if len(runes) > 2 && runes[0] == 0x1b && (runes[1] == '[' ||
 			(len(runes) > 3 && runes[1] == 0x1b && runes[2] == '[')) {
+			msgs = append(msgs, unknownSequenceMsg(string(runes)))
 			continue
 		}
 
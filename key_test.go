@@ -2,8 +2,13 @@ package tea
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestKeyString(t *testing.T) {
@@ -128,6 +133,30 @@ func TestReadInput(t *testing.T) {
 				},
 			},
 		},
+		{"wheel down",
+			[]byte("\x1b[<65;33;17M"),
+			[]Msg{
+				MouseMsg{
+					Type: MouseWheelDown,
+				},
+			},
+		},
+		{"left",
+			[]byte("\x1b[M \xc2\x85!"),
+			[]Msg{
+				MouseMsg{
+					Type: MouseLeft,
+				},
+			},
+		},
+		{"left",
+			[]byte("\x1b[2;1;10;20;1&w"),
+			[]Msg{
+				MouseMsg{
+					Type: MouseLeft,
+				},
+			},
+		},
 		{"shift+tab",
 			[]byte{'\x1b', '[', 'Z'},
 			[]Msg{
@@ -164,7 +193,7 @@ func TestReadInput(t *testing.T) {
 		},
 		{"unrecognized CSI",
 			[]byte{'\x1b', '[', '-', '-', '-', '-', 'X'},
-			[]Msg{},
+			[]Msg{unknownSequenceMsg("\x1b[----X")},
 		},
 		// Powershell sequences.
 		{"up",
@@ -193,7 +222,7 @@ func TestReadInput(t *testing.T) {
 		},
 	} {
 		t.Run(fmt.Sprintf("%d: %s", i, td.keyname), func(t *testing.T) {
-			msgs, err := readInputs(bytes.NewReader(td.in))
+			msgs, err := readInputs(bytes.NewReader(td.in), false)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -222,3 +251,475 @@ func TestReadInput(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleUnknownSequences(t *testing.T) {
+	type customMsg string
+
+	msgs := func() []Msg {
+		return []Msg{
+			KeyMsg{Type: KeyUp},
+			unknownSequenceMsg("\x1b[1337x"),
+			unknownSequenceMsg("\x1b[----X"),
+		}
+	}
+
+	t.Run("without handler", func(t *testing.T) {
+		p := NewProgram(nil)
+		out := p.handleUnknownSequences(msgs())
+		expected := []Msg{KeyMsg{Type: KeyUp}}
+		if !reflect.DeepEqual(out, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, out)
+		}
+	})
+
+	t.Run("with handler", func(t *testing.T) {
+		p := NewProgram(nil, WithUnknownSequenceHandler(func(seq []byte) Msg {
+			if string(seq) == "\x1b[1337x" {
+				return customMsg(seq)
+			}
+			return nil
+		}))
+		out := p.handleUnknownSequences(msgs())
+		expected := []Msg{KeyMsg{Type: KeyUp}, customMsg("\x1b[1337x")}
+		if !reflect.DeepEqual(out, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, out)
+		}
+	})
+}
+
+func TestReadInputsMixed(t *testing.T) {
+	tt := []struct {
+		name     string
+		in       []byte
+		expected []Msg
+	}{
+		{
+			name: "key then sgr click",
+			in:   []byte("q\x1b[<0;1;1M"),
+			expected: []Msg{
+				KeyMsg{Type: KeyRunes, Runes: []rune{'q'}},
+				MouseMsg{X: 0, Y: 0, Type: MouseLeft},
+			},
+		},
+		{
+			name: "sgr click then key",
+			in:   []byte("\x1b[<0;5;3Mq"),
+			expected: []Msg{
+				MouseMsg{X: 4, Y: 2, Type: MouseLeft},
+				KeyMsg{Type: KeyRunes, Runes: []rune{'q'}},
+			},
+		},
+		{
+			name: "arrow key between x10 events",
+			in:   []byte("\x1b[M !!\x1b[A\x1b[M#!!"),
+			expected: []Msg{
+				MouseMsg{X: 0, Y: 0, Type: MouseLeft},
+				KeyMsg{Type: KeyUp},
+				MouseMsg{X: 0, Y: 0, Type: MouseRelease},
+			},
+		},
+		{
+			name: "csi parameter before sgr event",
+			in:   []byte("\x1b[5\x1b[<0;3;2M"),
+			expected: []Msg{
+				unknownSequenceMsg("\x1b[5"),
+				MouseMsg{X: 2, Y: 1, Type: MouseLeft},
+			},
+		},
+		{
+			name: "csi parameters before sgr release",
+			in:   []byte("\x1b[1;5\x1b[<0;3;2m"),
+			expected: []Msg{
+				unknownSequenceMsg("\x1b[1;5"),
+				MouseMsg{X: 2, Y: 1, Type: MouseRelease, Released: MouseLeft},
+			},
+		},
+		{
+			name: "modified key before x10 event",
+			in:   []byte("\x1b[27;5;9~\x1b[M !!"),
+			expected: []Msg{
+				unknownSequenceMsg("\x1b[27;5;9~"),
+				MouseMsg{X: 0, Y: 0, Type: MouseLeft},
+			},
+		},
+		{
+			// The event is dropped, along with its last byte, which the
+			// inserted line ending pushed out.
+			name: "x10 event with inserted cr",
+			in:   []byte("\x1b[M \r!!a"),
+			expected: []Msg{
+				KeyMsg{Type: KeyRunes, Runes: []rune{'a'}},
+			},
+		},
+		{
+			name: "x10 event with inserted lf between good ones",
+			in:   []byte("\x1b[M !!\x1b[M#\n!!\x1b[M !!"),
+			expected: []Msg{
+				MouseMsg{X: 0, Y: 0, Type: MouseLeft},
+				MouseMsg{X: 0, Y: 0, Type: MouseLeft},
+			},
+		},
+		{
+			name: "x10 event with translated cr between good ones",
+			in:   []byte("\x1b[M !!\x1b[M#\n!\x1b[M !!"),
+			expected: []Msg{
+				MouseMsg{X: 0, Y: 0, Type: MouseLeft},
+				MouseMsg{X: 0, Y: 0, Type: MouseLeft},
+			},
+		},
+		{
+			name: "utf-8 event then key",
+			in:   []byte("\x1b[M \xc2\x85!a"),
+			expected: []Msg{
+				MouseMsg{X: 100, Y: 0, Type: MouseLeft},
+				KeyMsg{Type: KeyRunes, Runes: []rune{'a'}},
+			},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			msgs, err := readInputs(bytes.NewReader(tc.in), false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(msgs, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, msgs)
+			}
+		})
+	}
+}
+
+func TestReadInputsMouseError(t *testing.T) {
+	// Only events corrupted by line endings are dropped, other malformed
+	// events are reported.
+	if _, err := readInputs(bytes.NewReader([]byte("q\x1b[<0;1M")), false); err == nil {
+		t.Error("expected an error for a malformed sgr event")
+	}
+}
+
+func TestInputDebugReader(t *testing.T) {
+	in := []byte("q\x1b[<0;1;1M")
+
+	var dump bytes.Buffer
+	msgs, err := readInputs(&inputDebugReader{r: bytes.NewReader(in), w: &dump}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected, err := readInputs(bytes.NewReader(in), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, msgs)
+	}
+
+	for _, s := range []string{`read 10 bytes: "q\x1b[<0;1;1M"`, "71 1b 5b 3c"} {
+		if !strings.Contains(dump.String(), s) {
+			t.Errorf("expected dump to contain %q, got:\n%s", s, dump.String())
+		}
+	}
+}
+
+func TestRawInput(t *testing.T) {
+	in := []byte("ab")
+
+	run := func(t *testing.T, opt ProgramOption, done func(Msg) bool) []Msg {
+		var buf bytes.Buffer
+		m := &testReportModel{done: done}
+		p := NewProgram(m, WithInput(bytes.NewReader(in)), WithOutput(&buf), opt)
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only keep the input messages.
+		var msgs []Msg
+		for _, msg := range m.msgs {
+			switch msg.(type) {
+			case RawMsg, KeyMsg:
+				msgs = append(msgs, msg)
+			}
+		}
+		return msgs
+	}
+
+	t.Run("alongside", func(t *testing.T) {
+		msgs := run(t, WithRawInput(), func(msg Msg) bool {
+			k, ok := msg.(KeyMsg)
+			return ok && k.String() == "b"
+		})
+		expected := []Msg{
+			RawMsg(in),
+			KeyMsg{Type: KeyRunes, Runes: []rune{'a'}},
+			KeyMsg{Type: KeyRunes, Runes: []rune{'b'}},
+		}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+	})
+
+	t.Run("only", func(t *testing.T) {
+		msgs := run(t, WithRawInputOnly(), func(msg Msg) bool {
+			_, ok := msg.(RawMsg)
+			return ok
+		})
+		expected := []Msg{RawMsg(in)}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+	})
+}
+
+func TestIncompleteSequence(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"a", false},
+		{"\x1b", true},
+		{"a\x1b", true},
+		{"\x1ba", false},
+		{"\x1b[", true},
+		{"\x1b[1;5", true},
+		{"\x1b[1;5A", false},
+		{"\x1b[<0;10", true},
+		{"\x1b[<0;10;20M", false},
+		{"\x1b[M", true},
+		{"\x1b[M !", true},
+		{"\x1b[M !!", false},
+		{"\x1bO", true},
+		{"\x1bOP", false},
+		{"\x1b]11;rgb:0000/0000", true},
+		{"\x1b]11;rgb:0000/0000/0000\a", false},
+		{"\x1b]11;rgb:0000/0000/0000\x1b\\", false},
+		{"\x1b[Aq", false},
+		{"\x1bP>|xterm(388)", true},
+		{"\x1bP>|xterm(388)\x1b\\", false},
+	}
+
+	for _, tc := range tests {
+		if got := incompleteSequence([]byte(tc.in)); got != tc.want {
+			t.Errorf("incompleteSequence(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSequenceReader(t *testing.T) {
+	t.Run("split sequence", func(t *testing.T) {
+		r, w := io.Pipe()
+		s := newSequenceReader(r, time.Second)
+		defer s.close()
+
+		go func() {
+			_, _ = w.Write([]byte("\x1b["))
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write([]byte("A"))
+		}()
+
+		msgs, err := readInputs(s, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []Msg{KeyMsg{Type: KeyUp}}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+	})
+
+	t.Run("lone escape", func(t *testing.T) {
+		r, w := io.Pipe()
+		s := newSequenceReader(r, 10*time.Millisecond)
+		defer s.close()
+
+		go func() {
+			_, _ = w.Write([]byte("\x1b"))
+		}()
+
+		msgs, err := readInputs(s, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := []Msg{KeyMsg{Type: KeyEscape}}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		s := newSequenceReader(bytes.NewReader([]byte("a")), time.Second)
+		defer s.close()
+
+		if msgs, err := readInputs(s, false); err != nil || len(msgs) != 1 {
+			t.Fatalf("expected one message, got %#v, %v", msgs, err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := readInputs(s, false); !errors.Is(err, io.EOF) {
+				t.Fatalf("expected EOF, got %v", err)
+			}
+		}
+	})
+}
+
+func TestReadInputsEchoedModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		expected []Msg
+	}{
+		{
+			"echoed mouse enable before a click",
+			"\x1b[?1002h\x1b[?1006h\x1b[<0;1;1M",
+			[]Msg{MouseMsg{Type: MouseLeft}},
+		},
+		{
+			"echoed mode between keys",
+			"a\x1b[?1002lb",
+			[]Msg{
+				KeyMsg{Type: KeyRunes, Runes: []rune{'a'}},
+				KeyMsg{Type: KeyRunes, Runes: []rune{'b'}},
+			},
+		},
+		{
+			"echoed mode only",
+			"\x1b[?1000;1006h",
+			nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msgs, err := readInputs(bytes.NewReader([]byte(tc.in)), false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(msgs, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, msgs)
+			}
+		})
+	}
+}
+
+func TestBulkInput(t *testing.T) {
+	long := strings.Repeat("pasted ", 5)
+
+	tests := []struct {
+		name     string
+		in       string
+		expected []Msg
+	}{
+		{
+			"typing",
+			"ab",
+			nil,
+		},
+		{
+			"paste",
+			long + "\r" + long + "\tend\n",
+			[]Msg{BulkInputMsg(long + "\r" + long + "\tend\n")},
+		},
+		{
+			"escape sequence",
+			long + "\x1b[A",
+			nil,
+		},
+		{
+			"control key",
+			long + "\x03",
+			nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msgs, err := readInputs(strings.NewReader(tc.in), false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := tc.expected
+			if expected == nil {
+				// Left as is.
+				expected = msgs
+			}
+			if got := bulkInput(msgs); !reflect.DeepEqual(got, expected) {
+				t.Fatalf("expected %#v, got %#v", expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeLineEndings(t *testing.T) {
+	key := KeyMsg{Type: KeyEnter}
+
+	tests := []struct {
+		name       string
+		cr         bool
+		in         []Msg
+		expected   []Msg
+		expectedCR bool
+	}{
+		{
+			"mixed",
+			false,
+			[]Msg{BulkInputMsg("a\r\nb\rc\nd\r\r\ne")},
+			[]Msg{BulkInputMsg("a\nb\nc\nd\n\ne")},
+			false,
+		},
+		{
+			"other messages",
+			false,
+			[]Msg{key, BulkInputMsg("a\r\n"), key},
+			[]Msg{key, BulkInputMsg("a\n"), key},
+			false,
+		},
+		{
+			"ending with CR",
+			false,
+			[]Msg{BulkInputMsg("a\r")},
+			[]Msg{BulkInputMsg("a\n")},
+			true,
+		},
+		{
+			"CRLF split across reads",
+			true,
+			[]Msg{BulkInputMsg("\nb\r\n")},
+			[]Msg{BulkInputMsg("b\n")},
+			false,
+		},
+		{
+			"CRLF split across messages",
+			false,
+			[]Msg{BulkInputMsg("a\r"), BulkInputMsg("\nb")},
+			[]Msg{BulkInputMsg("a\n"), BulkInputMsg("b")},
+			false,
+		},
+		{
+			"only the rest of a CRLF",
+			true,
+			[]Msg{BulkInputMsg("\n")},
+			[]Msg{},
+			false,
+		},
+		{
+			"CR followed by a key",
+			true,
+			[]Msg{key, BulkInputMsg("\nb")},
+			[]Msg{key, BulkInputMsg("\nb")},
+			false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, cr := normalizeLineEndings(tc.in, tc.cr)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, got)
+			}
+			if cr != tc.expectedCR {
+				t.Errorf("expected cr %v, got %v", tc.expectedCR, cr)
+			}
+		})
+	}
+}
@@ -7,8 +7,14 @@ import (
 )
 
 // LogToFile sets up default logging to log to a file. This is helpful as we
-// can't print to the terminal since our TUI is occupying it. If the file
-// doesn't exist it will be created.
+// can't print to the terminal since our TUI is occupying it: calling
+// log.Println while a program is running would scribble over its UI. If the
+// file doesn't exist it will be created.
+//
+// Only the standard logger from the log package is redirected; the output of
+// any Program is left untouched. It's meant for debug logging during
+// development. You can follow the log from another terminal with
+// tail -f.
 //
 // Don't forget to close the file when you're done with it.
 //
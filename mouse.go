@@ -3,6 +3,10 @@ package tea
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // MouseMsg contains information about a mouse event and are sent to a programs
@@ -13,11 +17,44 @@ type MouseMsg MouseEvent
 // MouseEvent represents a mouse event, which could be a click, a scroll wheel
 // movement, a cursor movement, or a combination.
 type MouseEvent struct {
-	X    int
-	Y    int
-	Type MouseEventType
-	Alt  bool
-	Ctrl bool
+	X     int
+	Y     int
+	Type  MouseEventType
+	Shift bool
+	Alt   bool
+	Ctrl  bool
+
+	// Delta is the number of notches the wheel was scrolled by for wheel
+	// events. It's only populated when the program is run with
+	// WithReportScroll; a zero value should be treated as a single notch.
+	Delta int
+
+	// Button is the number of the button the terminal reported for events of
+	// type MouseUnknown, so that buttons we don't have a type for aren't lost.
+	// In SGR mode it's also set on the MouseRelease events of those buttons.
+	// Buttons 6 and 7 are reported by the horizontal wheel, or by tilting the
+	// wheel sideways; terminals encode both the same way, so they can't be
+	// told apart. Buttons 8 to 11 are extra buttons, such as back and forward.
+	Button int
+
+	// PixelX and PixelY are the position of the event in pixels, when the
+	// program is run with WithMousePixels and the terminal supports it. X and
+	// Y are then derived from them using the terminal's cell size, or zero if
+	// the terminal didn't report it.
+	PixelX int
+	PixelY int
+
+	// Released is the button that was released, for events of type
+	// MouseRelease. In SGR mode it's the button the terminal reported. The
+	// other encodings don't report which button was released, so it's the
+	// button that was pressed most recently. This assumes only one button is
+	// held down at a time; it's MouseUnknown if no press was seen.
+	Released MouseEventType
+
+	// Dragging is set on wheel events when the terminal reports them as
+	// motion too, which happens when the wheel is scrolled during a drag in
+	// all motion mode.
+	Dragging bool
 }
 
 // String returns a string representation of a mouse event.
@@ -28,11 +65,229 @@ func (m MouseEvent) String() (s string) {
 	if m.Alt {
 		s += "alt+"
 	}
+	if m.Shift {
+		s += "shift+"
+	}
 	s += mouseEventTypes[m.Type]
 	return s
 }
 
+// IsWheel reports whether the event is a wheel movement. This includes the
+// horizontal wheel, which is reported as buttons 6 and 7 of type
+// MouseUnknown.
+func (m MouseEvent) IsWheel() bool {
+	switch m.Type {
+	case MouseWheelUp, MouseWheelDown:
+		return true
+	case MouseUnknown:
+		return m.Button == 6 || m.Button == 7
+	}
+	return false
+}
+
+// IsButton reports whether the event is a button press. This includes the
+// extra buttons 8 to 11, which are reported with type MouseUnknown. Note that
+// drag events, i.e. motion with a button held down, are reported as presses of
+// that button too.
+func (m MouseEvent) IsButton() bool {
+	switch m.Type {
+	case MouseLeft, MouseMiddle, MouseRight:
+		return true
+	case MouseUnknown:
+		return m.Button >= 8 && m.Button <= 11
+	}
+	return false
+}
+
+// IsMotion reports whether the event is a movement of the pointer with no
+// button pressed.
+func (m MouseEvent) IsMotion() bool {
+	return m.Type == MouseMotion
+}
+
+// IsRelease reports whether the event is a button release.
+func (m MouseEvent) IsRelease() bool {
+	return m.Type == MouseRelease
+}
+
+// Modifier is a modifier key held down during a mouse event. Modifiers can be
+// combined into a set with |.
+type Modifier int
+
+// Modifier keys.
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// Modifiers returns the set of modifier keys held down during the event, so
+// that combinations can be matched at once:
+//
+//	if msg.Type == tea.MouseLeft && msg.Modifiers() == tea.ModCtrl|tea.ModShift {
+//	    // ctrl+shift+click, and nothing else
+//	}
+func (m MouseEvent) Modifiers() Modifier {
+	var mods Modifier
+	if m.Shift {
+		mods |= ModShift
+	}
+	if m.Alt {
+		mods |= ModAlt
+	}
+	if m.Ctrl {
+		mods |= ModCtrl
+	}
+	return mods
+}
+
+// HasMods reports whether all of the given modifier keys were held down
+// during the event. Other modifiers may have been held down too; compare
+// with Modifiers to match a combination exactly.
+func (m MouseEvent) HasMods(mods Modifier) bool {
+	return m.Modifiers()&mods == mods
+}
+
+// InScrollArea reports whether the event is on one of the rows of a scroll
+// area with the given boundaries, as passed to SyncScrollArea, ScrollUp and
+// ScrollDown: the rows from topBoundary up to, but not including,
+// bottomBoundary.
+func (m MouseEvent) InScrollArea(topBoundary, bottomBoundary int) bool {
+	return m.Y >= topBoundary && m.Y < bottomBoundary
+}
+
+// RelativeTo returns the event with its row made relative to the given row,
+// such as the top boundary of a scroll area, so that a click on that row has
+// a Y of 0. Events above it get a negative Y; check InScrollArea first to
+// know whether the event is in the area at all. The column and the position
+// in pixels are left as they are.
+//
+//	if msg.InScrollArea(top, bottom) {
+//	    line := m.offset + msg.RelativeTo(top).Y
+//	    // ...
+//	}
+func (m MouseEvent) RelativeTo(regionTop int) MouseEvent {
+	m.Y -= regionTop
+	return m
+}
+
+// CellCoords returns the cell the event's pixel position, PixelX and PixelY,
+// falls in, given the size of a cell in pixels as reported by CellSizeMsg.
+// See PixelToCell.
+func (m MouseEvent) CellCoords(cellW, cellH int) (x, y int) {
+	return PixelToCell(m.PixelX, m.PixelY, cellW, cellH)
+}
+
+// PixelToCell returns the cell a position in pixels falls in, given the size
+// of a cell in pixels as reported by CellSizeMsg. Positions are rounded down,
+// so pixels 0 to cellW-1 are in column 0, and a position left of or above the
+// origin is in a negative cell. If the cell size isn't known, that is, either
+// dimension is zero or less, the result is 0, 0.
+//
+// To go the other way, multiply: the cell at x, y starts at pixel x*cellW,
+// y*cellH.
+func PixelToCell(px, py, cellW, cellH int) (x, y int) {
+	if cellW <= 0 || cellH <= 0 {
+		return 0, 0
+	}
+	return floorDiv(px, cellW), floorDiv(py, cellH)
+}
+
+// floorDiv divides a by a positive b, rounding towards negative infinity
+// rather than towards zero.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b < 0 {
+		q--
+	}
+	return q
+}
+
+// NewMouseEvent returns a mouse event of the given type at the given
+// position, with the given modifier keys held down. It's meant for building
+// events in tests, rather than encoding escape sequences by hand.
+func NewMouseEvent(x, y int, t MouseEventType, mods ...Modifier) MouseEvent {
+	m := MouseEvent{X: x, Y: y, Type: t}
+	for _, mod := range mods {
+		m.Shift = m.Shift || mod&ModShift != 0
+		m.Alt = m.Alt || mod&ModAlt != 0
+		m.Ctrl = m.Ctrl || mod&ModCtrl != 0
+	}
+	return m
+}
+
+// EncodeSGR encodes a mouse event the way a terminal reports it in SGR mode,
+// which is handy for feeding events to a program through WithInput in tests.
+// Parsing the result yields the event again.
+//
+// Releases are encoded as releases of the button in Released, or in Button
+// for buttons without a type. Events of type MouseUnknown without a Button
+// can't be encoded, so nil is returned for them.
+func EncodeSGR(m MouseEvent) []byte {
+	var b int
+	switch m.Type {
+	case MouseLeft:
+		b = 0
+	case MouseMiddle:
+		b = 1
+	case MouseRight:
+		b = 2
+	case MouseMotion:
+		b = 3 | 0b0010_0000
+	case MouseWheelUp:
+		b = 0b0100_0000
+	case MouseWheelDown:
+		b = 0b0100_0001
+	case MouseRelease:
+		switch {
+		case m.Button != 0:
+			b = encodeButton(m.Button)
+		case m.Released == MouseMiddle:
+			b = 1
+		case m.Released == MouseRight:
+			b = 2
+		}
+	default:
+		if m.Button == 0 {
+			return nil
+		}
+		b = encodeButton(m.Button)
+	}
+
+	if m.Dragging && b&0b0100_0000 != 0 {
+		b |= 0b0010_0000
+	}
+	if m.Shift {
+		b |= 0b0000_0100
+	}
+	if m.Alt {
+		b |= 0b0000_1000
+	}
+	if m.Ctrl {
+		b |= 0b0001_0000
+	}
+
+	final := 'M'
+	if m.Type == MouseRelease {
+		final = 'm'
+	}
+	return []byte(fmt.Sprintf("\x1b[<%d;%d;%d%c", b, m.X+1, m.Y+1, final))
+}
+
+// encodeButton returns the button code of the numbered buttons without a
+// type: 6 and 7 are on the wheel, 8 to 11 are extra buttons.
+func encodeButton(n int) int {
+	if n >= 8 {
+		return 0b1000_0000 | (n-8)&0b11
+	}
+	return 0b0100_0000 | (n-4)&0b11
+}
+
 // MouseEventType indicates the type of mouse event occurring.
+//
+// Middle clicks are reported as MouseMiddle even when they paste, as on X11:
+// terminals report the click the same way either way, and paste detection
+// isn't supported, so there's no paste to tie the click to.
 type MouseEventType int
 
 // Mouse event types.
@@ -58,6 +313,330 @@ var mouseEventTypes = map[MouseEventType]string{
 	MouseMotion:    "motion",
 }
 
+// MouseMode is the mode of mouse tracking in effect.
+type MouseMode int
+
+// Mouse modes.
+const (
+	// MouseModeOff means mouse events aren't reported.
+	MouseModeOff MouseMode = iota
+	// MouseModeCellMotion reports clicks, the wheel, and motion while a
+	// button is held down. See EnableMouseCellMotion.
+	MouseModeCellMotion
+	// MouseModeAllMotion reports motion whether or not a button is held
+	// down, too. See EnableMouseAllMotion.
+	MouseModeAllMotion
+	// MouseModeExtendedMotion is either of the above with coordinates
+	// encoded as UTF-8. See WithMouseUTF8.
+	MouseModeExtendedMotion
+	// MouseModePixelsMotion is either of the above with positions reported
+	// in pixels. See WithMousePixels.
+	MouseModePixelsMotion
+)
+
+var mouseModeNames = map[MouseMode]string{
+	MouseModeOff:            "off",
+	MouseModeCellMotion:     "cell motion",
+	MouseModeAllMotion:      "all motion",
+	MouseModeExtendedMotion: "extended motion",
+	MouseModePixelsMotion:   "pixels motion",
+}
+
+// String returns a string representation of the mouse mode.
+func (m MouseMode) String() string {
+	return mouseModeNames[m]
+}
+
+// coalesceWheelEvents merges consecutive wheel events scrolling in the same
+// direction at the same position into a single event, recording the number
+// of events merged in its Delta. Single wheel events get a Delta of 1.
+func coalesceWheelEvents(msgs []Msg) []Msg {
+	out := make([]Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		m, ok := msg.(MouseMsg)
+		if !ok || (m.Type != MouseWheelUp && m.Type != MouseWheelDown) {
+			out = append(out, msg)
+			continue
+		}
+
+		if len(out) > 0 {
+			if prev, ok := out[len(out)-1].(MouseMsg); ok {
+				merged := prev
+				merged.Delta = m.Delta
+				if merged == m {
+					prev.Delta++
+					out[len(out)-1] = prev
+					continue
+				}
+			}
+		}
+
+		m.Delta = 1
+		out = append(out, m)
+	}
+	return out
+}
+
+// dropDuplicateMotions drops motion events identical to the event right before
+// them, as some terminals re-report motion while the pointer stands still.
+// Other mouse events are always kept. As duplicates may span reads, the last
+// message of the previous batch is passed in as prev, and the last message of
+// this batch is returned for the next call.
+func dropDuplicateMotions(msgs []Msg, prev Msg) ([]Msg, Msg) {
+	out := make([]Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		if m, ok := msg.(MouseMsg); ok && m.Type == MouseMotion {
+			if p, ok := prev.(MouseMsg); ok && p == m {
+				continue
+			}
+		}
+		out = append(out, msg)
+		prev = msg
+	}
+	return out, prev
+}
+
+// deriveMouseCells sets the cell positions of mouse events reported in pixels,
+// using the cell size last reported by the terminal. cell is the size known
+// before these messages; the size known after them is returned, so that it
+// can be passed on to the next call. Until a size is known X and Y are left
+// at zero.
+func deriveMouseCells(msgs []Msg, cell CellSizeMsg) ([]Msg, CellSizeMsg) {
+	for i, msg := range msgs {
+		switch msg := msg.(type) {
+		case CellSizeMsg:
+			cell = msg
+		case MouseMsg:
+			if cell.Width > 0 && cell.Height > 0 {
+				msg.X, msg.Y = MouseEvent(msg).CellCoords(cell.Width, cell.Height)
+				msgs[i] = msg
+			}
+		}
+	}
+	return msgs, cell
+}
+
+// viewportMouseEvents makes the positions of mouse events relative to the
+// given viewport and drops the events outside of it. Releases outside of it
+// are kept, moved to its nearest edge, so that drags leaving the viewport
+// still end.
+func viewportMouseEvents(msgs []Msg, v region) []Msg {
+	out := make([]Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		m, ok := msg.(MouseMsg)
+		if !ok {
+			out = append(out, msg)
+			continue
+		}
+
+		m.X -= v.x
+		m.Y -= v.y
+		if m.X < 0 || m.Y < 0 || m.X >= v.width || m.Y >= v.height {
+			if m.Type != MouseRelease {
+				continue
+			}
+			switch {
+			case m.X < 0:
+				m.X = 0
+			case m.X >= v.width:
+				m.X = v.width - 1
+			}
+			switch {
+			case m.Y < 0:
+				m.Y = 0
+			case m.Y >= v.height:
+				m.Y = v.height - 1
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// annotateReleases sets the button that was released on the release events
+// among the given messages which don't report it, assuming it's the button
+// that was pressed last. pressed is the button pressed before these messages,
+// if any; the button pressed after them is returned, so that it can be passed
+// on to the next call.
+func annotateReleases(msgs []Msg, pressed MouseEventType) ([]Msg, MouseEventType) {
+	for i, msg := range msgs {
+		m, ok := msg.(MouseMsg)
+		if !ok {
+			continue
+		}
+		switch {
+		case MouseEvent(m).IsButton():
+			pressed = m.Type
+		case MouseEvent(m).IsRelease():
+			if m.Released == MouseUnknown && m.Button == 0 {
+				m.Released = pressed
+				msgs[i] = m
+			}
+			pressed = MouseUnknown
+		}
+	}
+	return msgs, pressed
+}
+
+// mouseButton identifies a mouse button: by its type, or by its number for
+// buttons of type MouseUnknown.
+type mouseButton struct {
+	typ    MouseEventType
+	button int
+}
+
+// mouseFilter drops the mouse events for which accept returns false. It keeps
+// track of the buttons whose press was let through, so that their release is
+// let through too, whatever accept says, as otherwise the program would think
+// the button is still held down.
+type mouseFilter struct {
+	accept  func(MouseEvent) bool
+	pressed map[mouseButton]bool
+}
+
+func newMouseFilter(accept func(MouseEvent) bool) *mouseFilter {
+	return &mouseFilter{
+		accept:  accept,
+		pressed: make(map[mouseButton]bool),
+	}
+}
+
+// filter returns the messages without the mouse events that are dropped.
+// Other messages are kept.
+func (f *mouseFilter) filter(msgs []Msg) []Msg {
+	out := msgs[:0]
+	for _, msg := range msgs {
+		m, ok := msg.(MouseMsg)
+		if !ok {
+			out = append(out, msg)
+			continue
+		}
+
+		e := MouseEvent(m)
+		keep := f.accept(e)
+		switch {
+		case e.IsButton():
+			b := mouseButton{typ: e.Type, button: e.Button}
+			if keep {
+				f.pressed[b] = true
+			} else {
+				delete(f.pressed, b)
+			}
+		case e.IsRelease():
+			b := mouseButton{typ: e.Released, button: e.Button}
+			if f.pressed[b] {
+				keep = true
+				delete(f.pressed, b)
+			}
+		}
+
+		if keep {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// parseMouseEvents parses a buffer consisting solely of mouse events, in any
+// of the encodings we support. If pixels is true, SGR events are taken to
+// report positions in pixels.
+func parseMouseEvents(buf []byte, pixels bool) ([]MouseEvent, error) {
+	m, err := parseSGRMouseEvents(buf, pixels)
+	if err != nil {
+		m, err = parseX10MouseEvents(buf)
+	}
+	if err != nil {
+		m, err = parseUTF8MouseEvents(buf)
+	}
+	return m, err
+}
+
+// inputPart is part of a buffer of input, which is either a single mouse event
+// or anything else.
+type inputPart struct {
+	buf   []byte
+	mouse bool
+}
+
+// splitMouseEvents splits a buffer of input into parts that are either a
+// single mouse event or other input, in the order they appear in.
+//
+// Mouse events are looked for at every position, not just at the start of a
+// sequence. Terminals with modifyOtherKeys enabled may precede them with
+// stray framing, such as a lone CSI parameter; that ends up in a part of its
+// own, and the mouse event is still recognized.
+func splitMouseEvents(buf []byte) []inputPart {
+	var parts []inputPart
+	start := 0
+	for i := 0; i < len(buf); {
+		n := mouseEventLen(buf[i:])
+		if n == 0 {
+			i++
+			continue
+		}
+		if i > start {
+			parts = append(parts, inputPart{buf: buf[start:i]})
+		}
+		parts = append(parts, inputPart{buf: buf[i : i+n], mouse: true})
+		i += n
+		start = i
+	}
+	if start < len(buf) {
+		parts = append(parts, inputPart{buf: buf[start:]})
+	}
+	return parts
+}
+
+// mouseEventLen returns the length of the mouse event at the start of the
+// given buffer, or 0 if it doesn't start with one.
+func mouseEventLen(buf []byte) int {
+	if n := highlightReportLen(buf); n > 0 {
+		return n
+	}
+
+	switch {
+	case bytes.HasPrefix(buf, []byte("\x1b[<")):
+		// SGR: parameters up to a final M or m.
+		for i := 3; i < len(buf); i++ {
+			switch c := buf[i]; {
+			case c >= '0' && c <= '9', c == ';':
+				continue
+			case (c == 'M' || c == 'm') && i > 3:
+				return i + 1
+			}
+			return 0
+		}
+
+	case bytes.HasPrefix(buf, []byte("\x1b[M")):
+		// X10 or UTF-8: three values, which in the latter case may be
+		// multi-byte runes.
+		n := 3
+		for i := 0; i < 3; i++ {
+			if n >= len(buf) {
+				return 0
+			}
+			if r, w := utf8.DecodeRune(buf[n:]); r != utf8.RuneError && w > 1 {
+				n += w
+			} else {
+				n++
+			}
+		}
+		if corruptMouseEvent(buf[:n]) && n < len(buf) && buf[n] != '\x1b' {
+			// A line ending was inserted into the event, pushing its last
+			// value out. Unless another sequence starts right away, that
+			// value belongs to the event.
+			n++
+		}
+		return n
+	}
+
+	return 0
+}
+
+// x10MouseByteOffset is the offset added to every value of an X10 mouse event
+// so that it's a printable character.
+const x10MouseByteOffset = 32
+
 // Parse X10-encoded mouse events; the simplest kind. The last release of X10
 // was December 1986, by the way.
 //
@@ -65,85 +644,428 @@ var mouseEventTypes = map[MouseEventType]string{
 //
 //	ESC [M Cb Cx Cy
 //
+// Coordinates above 94 are encoded as bytes of 127 and up. When the terminal
+// is in UTF-8 mode some terminals encode those as multi-byte UTF-8 sequences,
+// which we decode to their rune value.
+//
 // See: http://www.xfree86.org/current/ctlseqs.html#Mouse%20Tracking
 func parseX10MouseEvents(buf []byte) ([]MouseEvent, error) {
+	r, ok := parseLegacyMouseEvents(buf, decodeX10Values)
+	if !ok {
+		return r, errors.New("not an X10 mouse event")
+	}
+	return r, nil
+}
+
+// Parse UTF-8 encoded mouse events, as sent in extended mouse mode 1005.
+// They look like X10 mouse events, but each value is encoded as a UTF-8
+// rune, so coordinates of 95 and above take up two bytes. This extends the
+// range of coordinates to 2015.
+//
+// See: https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Extended-coordinates
+func parseUTF8MouseEvents(buf []byte) ([]MouseEvent, error) {
+	r, ok := parseLegacyMouseEvents(buf, decodeUTF8Values)
+	if !ok {
+		return r, errors.New("not a UTF-8 mouse event")
+	}
+	return r, nil
+}
+
+// parseLegacyMouseEvents parses the mouse events sharing the X10 layout, using
+// the given function to decode each event's values.
+func parseLegacyMouseEvents(buf []byte, decode func([]byte) ([3]int, bool)) ([]MouseEvent, bool) {
 	var r []MouseEvent
 
 	seq := []byte("\x1b[M")
 	if !bytes.Contains(buf, seq) {
-		return r, errors.New("not an X10 mouse event")
+		return r, false
 	}
 
 	for _, v := range bytes.Split(buf, seq) {
 		if len(v) == 0 {
 			continue
 		}
-		if len(v) != 3 {
-			return r, errors.New("not an X10 mouse event")
+		vals, ok := decode(v)
+		if !ok {
+			return r, false
 		}
 
-		var m MouseEvent
-		const byteOffset = 32
-		e := v[0] - byteOffset
+		m := parseMouseButton(vals[0], false)
 
-		const (
-			bitShift  = 0b0000_0100
-			bitAlt    = 0b0000_1000
-			bitCtrl   = 0b0001_0000
-			bitMotion = 0b0010_0000
-			bitWheel  = 0b0100_0000
+		// (1,1) is the upper left. We subtract 1 to normalize it to (0,0).
+		m.X = vals[1] - x10MouseByteOffset - 1
+		m.Y = vals[2] - x10MouseByteOffset - 1
 
-			bitsMask = 0b0000_0011
+		r = append(r, m)
+	}
 
-			bitsLeft    = 0b0000_0000
-			bitsMiddle  = 0b0000_0001
-			bitsRight   = 0b0000_0010
-			bitsRelease = 0b0000_0011
+	return r, true
+}
 
-			bitsWheelUp   = 0b0000_0000
-			bitsWheelDown = 0b0000_0001
-		)
+// Parse SGR-encoded mouse events. Unlike X10, SGR encodes its values as
+// decimal numbers, so coordinates aren't limited to 223, and releases are
+// reported with a distinct final character.
+//
+// SGR mouse events look like:
+//
+//	ESC [ < Cb ; Cx ; Cy (M or m)
+//
+// where M is used for button presses and m for releases.
+//
+// See: https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Extended-coordinates
+func parseSGRMouseEvents(buf []byte, pixels bool) ([]MouseEvent, error) {
+	var r []MouseEvent
 
-		if e&bitWheel != 0 {
-			// Check the low two bits.
-			switch e & bitsMask {
-			case bitsWheelUp:
-				m.Type = MouseWheelUp
-			case bitsWheelDown:
-				m.Type = MouseWheelDown
-			}
-		} else {
-			// Check the low two bits.
-			// We do not separate clicking and dragging.
-			switch e & bitsMask {
-			case bitsLeft:
-				m.Type = MouseLeft
-			case bitsMiddle:
-				m.Type = MouseMiddle
-			case bitsRight:
-				m.Type = MouseRight
-			case bitsRelease:
-				if e&bitMotion != 0 {
-					m.Type = MouseMotion
-				} else {
-					m.Type = MouseRelease
-				}
-			}
+	seq := []byte("\x1b[<")
+	if !bytes.Contains(buf, seq) {
+		return r, errors.New("not an SGR mouse event")
+	}
+
+	for _, v := range bytes.Split(buf, seq) {
+		if len(v) == 0 {
+			continue
+		}
+
+		release := false
+		switch v[len(v)-1] {
+		case 'M':
+		case 'm':
+			release = true
+		default:
+			return r, errors.New("not an SGR mouse event")
 		}
 
-		if e&bitAlt != 0 {
-			m.Alt = true
+		parts := strings.Split(string(v[:len(v)-1]), ";")
+		if len(parts) != 3 {
+			return r, errors.New("not an SGR mouse event")
 		}
-		if e&bitCtrl != 0 {
-			m.Ctrl = true
+		var vals [3]int
+		for i, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 {
+				return r, errors.New("not an SGR mouse event")
+			}
+			vals[i] = n
 		}
 
-		// (1,1) is the upper left. We subtract 1 to normalize it to (0,0).
-		m.X = int(v[1]) - byteOffset - 1
-		m.Y = int(v[2]) - byteOffset - 1
+		m := parseMouseButton(vals[0], true)
+		if release && m.Type != MouseWheelUp && m.Type != MouseWheelDown {
+			if m.IsButton() {
+				// Unlike X10, SGR reports which button was released.
+				m.Released = m.Type
+			}
+			m.Type = MouseRelease
+		}
+
+		if pixels {
+			// SGR-Pixels reports the same events, but positions are in
+			// pixels, which we pass on as they are.
+			m.PixelX = vals[1]
+			m.PixelY = vals[2]
+		} else {
+			// (1,1) is the upper left. We subtract 1 to normalize it to (0,0).
+			m.X = vals[1] - 1
+			m.Y = vals[2] - 1
+		}
 
 		r = append(r, m)
 	}
 
 	return r, nil
 }
+
+// Control sequences enabling and disabling the DEC locator. We ask for
+// locator reports in character cells (DECELR) on button presses and releases
+// (DECSLE).
+const (
+	enableDECLocatorSeq  = "\x1b[1;2'z\x1b[1'{\x1b[3'{"
+	disableDECLocatorSeq = "\x1b[0'z"
+)
+
+// parseDECLocatorEvent parses a DEC locator report, which some DEC-compatible
+// terminals send instead of xterm mouse events when the locator is enabled
+// with WithDECLocator.
+//
+// Locator reports look like:
+//
+//	ESC [ Pe ; Pb ; Pr ; Pc ; Pp & w
+//
+// where Pe is the event, Pb a mask of the buttons held down, Pr and Pc the
+// row and column, and Pp the page, which is optional. Only button presses and
+// releases are reported as mouse events.
+//
+// See: https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h2-Mouse-Tracking
+func parseDECLocatorEvent(seq string) (MouseEvent, bool) {
+	const (
+		prefix = "\x1b["
+		suffix = "&w"
+	)
+	if !strings.HasPrefix(seq, prefix) || !strings.HasSuffix(seq, suffix) {
+		return MouseEvent{}, false
+	}
+
+	parts := strings.Split(seq[len(prefix):len(seq)-len(suffix)], ";")
+	if len(parts) != 4 && len(parts) != 5 {
+		return MouseEvent{}, false
+	}
+	var vals [4]int
+	for i := range vals {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return MouseEvent{}, false
+		}
+		vals[i] = n
+	}
+
+	var m MouseEvent
+	switch vals[0] {
+	case 2:
+		m.Type = MouseLeft
+	case 4:
+		m.Type = MouseMiddle
+	case 6:
+		m.Type = MouseRight
+	case 3, 5, 7:
+		m.Type = MouseRelease
+	default:
+		// The locator is unavailable, left the filter rectangle or reported
+		// a button we don't know about.
+		return MouseEvent{}, false
+	}
+
+	// (1,1) is the upper left. We subtract 1 to normalize it to (0,0).
+	m.X = vals[3] - 1
+	m.Y = vals[2] - 1
+
+	return m, true
+}
+
+// MouseHighlightMsg reports the text highlighted with the mouse in highlight
+// tracking mode, see WithMouseHighlight. It's sent once the button is
+// released. Positions are zero-based cells of the terminal: the start and end
+// of the highlighted text, and the position of the pointer when the button
+// was released. If no text was highlighted, they're all the same.
+type MouseHighlightMsg struct {
+	StartX, StartY int
+	EndX, EndY     int
+	X, Y           int
+}
+
+// Control sequences enabling and disabling highlight tracking (mode 1001),
+// and telling the terminal to start highlighting at a cell. The parameters of
+// the latter are the column and row to start at, and the first row and one
+// past the last row highlighting may extend to, which we don't limit.
+const (
+	enableMouseHighlightSeq  = "\x1b[?1001h"
+	disableMouseHighlightSeq = "\x1b[?1001l"
+	startHighlightSeq        = "\x1b[1;%d;%d;1;65535T"
+)
+
+// highlightReportLen returns the length of the highlight tracking report at
+// the start of the given buffer, or 0 if it doesn't start with one. Reports
+// look like X10 mouse events:
+//
+//	ESC [ t Cx Cy
+//	ESC [ T Cx Cy Cx Cy Cx Cy
+//
+// The first is sent when no text was highlighted, with the position of the
+// pointer. The second gives the start and end of the highlighted text, and
+// the position of the pointer.
+func highlightReportLen(buf []byte) int {
+	var n int
+	switch {
+	case bytes.HasPrefix(buf, []byte("\x1b[t")):
+		n = 3 + 2
+	case bytes.HasPrefix(buf, []byte("\x1b[T")):
+		n = 3 + 6
+	default:
+		return 0
+	}
+	if len(buf) < n {
+		return 0
+	}
+	for _, c := range buf[3:n] {
+		if c <= x10MouseByteOffset {
+			return 0
+		}
+	}
+	return n
+}
+
+// parseHighlightReport parses a highlight tracking report, as sent in mode
+// 1001 when the mouse button is released.
+func parseHighlightReport(buf []byte) (MouseHighlightMsg, bool) {
+	n := highlightReportLen(buf)
+	if n == 0 || n != len(buf) {
+		return MouseHighlightMsg{}, false
+	}
+
+	// (1,1) is the upper left. We subtract 1 to normalize it to (0,0).
+	v := make([]int, 0, 6)
+	for _, c := range buf[3:] {
+		v = append(v, int(c)-x10MouseByteOffset-1)
+	}
+	if len(v) == 2 {
+		return MouseHighlightMsg{
+			StartX: v[0], StartY: v[1],
+			EndX: v[0], EndY: v[1],
+			X: v[0], Y: v[1],
+		}, true
+	}
+	return MouseHighlightMsg{
+		StartX: v[0], StartY: v[1],
+		EndX: v[2], EndY: v[3],
+		X: v[4], Y: v[5],
+	}, true
+}
+
+// startHighlights answers the presses of the left button among the given
+// messages, telling the terminal to start highlighting where the button was
+// pressed. In highlight tracking mode the terminal waits for this, and stops
+// responding if it doesn't come.
+func (p *Program) startHighlights(msgs []Msg) {
+	for _, msg := range msgs {
+		if m, ok := msg.(MouseMsg); ok && m.Type == MouseLeft {
+			p.renderer.execute(fmt.Sprintf(startHighlightSeq, m.X+1, m.Y+1))
+		}
+	}
+}
+
+// parseMouseButton decodes the button and modifiers of a mouse event from its
+// button code. Both X10 and SGR share the same layout for the button code,
+// the only difference being that X10 adds an offset to it to make it
+// printable.
+func parseMouseButton(b int, isSGR bool) MouseEvent {
+	var m MouseEvent
+	e := b
+	if !isSGR {
+		e = (e - x10MouseByteOffset) & 0xff
+	}
+
+	const (
+		bitShift  = 0b0000_0100
+		bitAlt    = 0b0000_1000
+		bitCtrl   = 0b0001_0000
+		bitMotion = 0b0010_0000
+		bitWheel  = 0b0100_0000
+		bitExtra  = 0b1000_0000
+
+		bitsMask = 0b0000_0011
+
+		bitsLeft    = 0b0000_0000
+		bitsMiddle  = 0b0000_0001
+		bitsRight   = 0b0000_0010
+		bitsRelease = 0b0000_0011
+
+		bitsWheelUp   = 0b0000_0000
+		bitsWheelDown = 0b0000_0001
+	)
+
+	switch {
+	case e&bitExtra != 0:
+		// Buttons 8 to 11.
+		m.Button = 8 + e&bitsMask
+	case e&bitWheel != 0:
+		// The wheel may be scrolled while dragging, in which case the motion
+		// bit is set as well.
+		m.Dragging = e&bitMotion != 0
+
+		// Check the low two bits.
+		switch e & bitsMask {
+		case bitsWheelUp:
+			m.Type = MouseWheelUp
+		case bitsWheelDown:
+			m.Type = MouseWheelDown
+		default:
+			// Buttons 6 and 7, the horizontal wheel.
+			m.Button = 4 + e&bitsMask
+		}
+	default:
+		// Check the low two bits.
+		// We do not separate clicking and dragging.
+		switch e & bitsMask {
+		case bitsLeft:
+			m.Type = MouseLeft
+		case bitsMiddle:
+			m.Type = MouseMiddle
+		case bitsRight:
+			m.Type = MouseRight
+		case bitsRelease:
+			if e&bitMotion != 0 {
+				m.Type = MouseMotion
+			} else {
+				m.Type = MouseRelease
+			}
+		}
+	}
+
+	if e&bitShift != 0 {
+		m.Shift = true
+	}
+	if e&bitAlt != 0 {
+		m.Alt = true
+	}
+	if e&bitCtrl != 0 {
+		m.Ctrl = true
+	}
+
+	return m
+}
+
+// decodeX10Values decodes the button, x and y values of an X10 mouse event,
+// excluding the ESC [ M prefix. Normally each value is a single byte, but
+// values of 128 and up may arrive as multi-byte UTF-8 sequences, in which case
+// they're decoded to their rune value.
+func decodeX10Values(v []byte) (vals [3]int, ok bool) {
+	// The common case: three single-byte values. We don't attempt to decode
+	// UTF-8 here as any multi-byte encoding would make the event longer.
+	if len(v) != 3 {
+		return decodeUTF8Values(v)
+	}
+	for i, b := range v {
+		if isCorruptMouseByte(b) {
+			return vals, false
+		}
+		vals[i] = int(b)
+	}
+	return vals, true
+}
+
+// isCorruptMouseByte reports whether b is a line ending, which shows up in X10
+// and UTF-8 mouse events when the input was mangled by CR/LF translation.
+// Values are offset so that they're printable, so a line ending can only be
+// a legitimate value for coordinates out of range, which are bogus anyway.
+func isCorruptMouseByte(b byte) bool {
+	return b == '\r' || b == '\n'
+}
+
+// corruptMouseEvent reports whether buf is an X10 or UTF-8 mouse event which
+// was mangled by CR/LF translation, see isCorruptMouseByte.
+func corruptMouseEvent(buf []byte) bool {
+	seq := []byte("\x1b[M")
+	return bytes.HasPrefix(buf, seq) && bytes.ContainsAny(buf[len(seq):], "\r\n")
+}
+
+// decodeUTF8Values decodes the three UTF-8 encoded values of a mouse event in
+// mode 1005. Bytes which aren't valid UTF-8 are taken as they are, as some
+// terminals send large values as raw bytes.
+func decodeUTF8Values(v []byte) (vals [3]int, ok bool) {
+	var n int
+	for i := range vals {
+		if n >= len(v) {
+			return vals, false
+		}
+		if r, w := utf8.DecodeRune(v[n:]); r != utf8.RuneError && w > 1 {
+			vals[i] = int(r)
+			n += w
+			continue
+		}
+		if isCorruptMouseByte(v[n]) {
+			return vals, false
+		}
+		vals[i] = int(v[n])
+		n++
+	}
+
+	return vals, n == len(v)
+}
@@ -1,6 +1,12 @@
 package tea
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
 
 func TestMouseEvent_String(t *testing.T) {
 	tt := []struct {
@@ -73,6 +79,24 @@ func TestMouseEvent_String(t *testing.T) {
 			},
 			expected: "ctrl+alt+left",
 		},
+		{
+			name: "shift+left",
+			event: MouseEvent{
+				Type:  MouseLeft,
+				Shift: true,
+			},
+			expected: "shift+left",
+		},
+		{
+			name: "ctrl+alt+shift+left",
+			event: MouseEvent{
+				Type:  MouseLeft,
+				Shift: true,
+				Alt:   true,
+				Ctrl:  true,
+			},
+			expected: "ctrl+alt+shift+left",
+		},
 		{
 			name: "ignore coordinates",
 			event: MouseEvent{
@@ -107,6 +131,69 @@ func TestMouseEvent_String(t *testing.T) {
 	}
 }
 
+func TestMouseEventPredicates(t *testing.T) {
+	tt := []struct {
+		typ     MouseEventType
+		wheel   bool
+		button  bool
+		motion  bool
+		release bool
+	}{
+		{typ: MouseUnknown},
+		{typ: MouseLeft, button: true},
+		{typ: MouseRight, button: true},
+		{typ: MouseMiddle, button: true},
+		{typ: MouseRelease, release: true},
+		{typ: MouseWheelUp, wheel: true},
+		{typ: MouseWheelDown, wheel: true},
+		{typ: MouseMotion, motion: true},
+	}
+
+	if len(tt) != len(mouseEventTypes) {
+		t.Fatalf("expected all %d mouse event types to be covered, got %d", len(mouseEventTypes), len(tt))
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(mouseEventTypes[tc.typ], func(t *testing.T) {
+			m := MouseEvent{Type: tc.typ}
+			if m.IsWheel() != tc.wheel {
+				t.Errorf("expected IsWheel to be %v", tc.wheel)
+			}
+			if m.IsButton() != tc.button {
+				t.Errorf("expected IsButton to be %v", tc.button)
+			}
+			if m.IsMotion() != tc.motion {
+				t.Errorf("expected IsMotion to be %v", tc.motion)
+			}
+			if m.IsRelease() != tc.release {
+				t.Errorf("expected IsRelease to be %v", tc.release)
+			}
+		})
+	}
+	// Buttons we don't have a type for.
+	for _, tc := range []struct {
+		event  MouseEvent
+		wheel  bool
+		button bool
+	}{
+		{event: MouseEvent{Type: MouseUnknown, Button: 6}, wheel: true},
+		{event: MouseEvent{Type: MouseUnknown, Button: 7}, wheel: true},
+		{event: MouseEvent{Type: MouseUnknown, Button: 8}, button: true},
+		{event: MouseEvent{Type: MouseUnknown, Button: 11}, button: true},
+		{event: MouseEvent{Type: MouseUnknown, Button: 12}},
+		{event: MouseEvent{Type: MouseRelease, Button: 9}},
+	} {
+		if tc.event.IsWheel() != tc.wheel {
+			t.Errorf("expected IsWheel of %#v to be %v", tc.event, tc.wheel)
+		}
+		if tc.event.IsButton() != tc.button {
+			t.Errorf("expected IsButton of %#v to be %v", tc.event, tc.button)
+		}
+	}
+}
+
 func TestParseX10MouseEvent(t *testing.T) {
 	encode := func(b byte, x, y int) []byte {
 		return []byte{
@@ -119,6 +206,12 @@ func TestParseX10MouseEvent(t *testing.T) {
 		}
 	}
 
+	encodeUTF8 := func(b byte, x, y int) []byte {
+		buf := []byte{'\x1b', '[', 'M', byte(32) + b}
+		buf = append(buf, string(rune(x+32+1))...)
+		return append(buf, string(rune(y+32+1))...)
+	}
+
 	tt := []struct {
 		name     string
 		buf      []byte
@@ -271,6 +364,18 @@ func TestParseX10MouseEvent(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "shift+right",
+			buf:  encode(0b0010_0110, 32, 16),
+			expected: []MouseEvent{
+				{
+					X:     32,
+					Y:     16,
+					Type:  MouseRight,
+					Shift: true,
+				},
+			},
+		},
 		{
 			name: "ctrl+right",
 			buf:  encode(0b0011_0010, 32, 16),
@@ -339,9 +444,10 @@ func TestParseX10MouseEvent(t *testing.T) {
 			buf:  encode(0b0100_0010, 32, 16),
 			expected: []MouseEvent{
 				{
-					X:    32,
-					Y:    16,
-					Type: MouseUnknown,
+					X:      32,
+					Y:      16,
+					Type:   MouseUnknown,
+					Button: 6,
 				},
 			},
 		},
@@ -350,10 +456,11 @@ func TestParseX10MouseEvent(t *testing.T) {
 			buf:  encode(0b0100_1010, 32, 16),
 			expected: []MouseEvent{
 				{
-					X:    32,
-					Y:    16,
-					Type: MouseUnknown,
-					Alt:  true,
+					X:      32,
+					Y:      16,
+					Type:   MouseUnknown,
+					Alt:    true,
+					Button: 6,
 				},
 			},
 		},
@@ -369,6 +476,51 @@ func TestParseX10MouseEvent(t *testing.T) {
 				},
 			},
 		},
+		// UTF-8 encoded coordinates.
+		{
+			name: "utf-8 encoded x",
+			buf:  encodeUTF8(0b0000_0000, 100, 16),
+			expected: []MouseEvent{
+				{
+					X:    100,
+					Y:    16,
+					Type: MouseLeft,
+				},
+			},
+		},
+		{
+			name: "utf-8 encoded x and y",
+			buf:  encodeUTF8(0b0000_0000, 150, 200),
+			expected: []MouseEvent{
+				{
+					X:    150,
+					Y:    200,
+					Type: MouseLeft,
+				},
+			},
+		},
+		{
+			name: "utf-8 encoded beyond a byte",
+			buf:  encodeUTF8(0b0000_0011, 300, 250),
+			expected: []MouseEvent{
+				{
+					X:    300,
+					Y:    250,
+					Type: MouseRelease,
+				},
+			},
+		},
+		{
+			name: "utf-8 encoded and raw 0xff",
+			buf:  append(encodeUTF8(0b0000_0000, 120, 0)[:6], 0xff),
+			expected: []MouseEvent{
+				{
+					X:    120,
+					Y:    222,
+					Type: MouseLeft,
+				},
+			},
+		},
 		// Batched events.
 		{
 			name: "batched events",
@@ -432,6 +584,10 @@ func TestParseX10MouseEvent_error(t *testing.T) {
 			name: "long buf",
 			buf:  []byte("\x1b[M@A11"),
 		},
+		{
+			name: "long utf-8 buf",
+			buf:  []byte("\x1b[M@\xc2\x85A1"),
+		},
 	}
 
 	for i := range tt {
@@ -446,3 +602,1093 @@ func TestParseX10MouseEvent_error(t *testing.T) {
 		})
 	}
 }
+
+func TestParseUTF8MouseEvent(t *testing.T) {
+	encode := func(b byte, x, y int) []byte {
+		buf := []byte{'\x1b', '[', 'M', byte(32) + b}
+		buf = append(buf, string(rune(x+32+1))...)
+		return append(buf, string(rune(y+32+1))...)
+	}
+
+	tt := []struct {
+		name     string
+		buf      []byte
+		expected []MouseEvent
+	}{
+		{
+			name: "single byte values",
+			buf:  encode(0b0000_0000, 32, 16),
+			expected: []MouseEvent{
+				{
+					X:    32,
+					Y:    16,
+					Type: MouseLeft,
+				},
+			},
+		},
+		{
+			name: "first multi-byte x",
+			buf:  encode(0b0000_0000, 95, 0),
+			expected: []MouseEvent{
+				{
+					X:    95,
+					Y:    0,
+					Type: MouseLeft,
+				},
+			},
+		},
+		{
+			name: "utf-8 encoded x",
+			buf:  encode(0b0000_0000, 100, 16),
+			expected: []MouseEvent{
+				{
+					X:    100,
+					Y:    16,
+					Type: MouseLeft,
+				},
+			},
+		},
+		{
+			name: "utf-8 encoded x and y",
+			buf:  encode(0b0000_0000, 150, 200),
+			expected: []MouseEvent{
+				{
+					X:    150,
+					Y:    200,
+					Type: MouseLeft,
+				},
+			},
+		},
+		{
+			name: "utf-8 encoded beyond a byte",
+			buf:  encode(0b0000_0011, 300, 250),
+			expected: []MouseEvent{
+				{
+					X:    300,
+					Y:    250,
+					Type: MouseRelease,
+				},
+			},
+		},
+		{
+			name: "utf-8 encoded and raw 0xff",
+			buf:  append(encode(0b0000_0000, 120, 0)[:6], 0xff),
+			expected: []MouseEvent{
+				{
+					X:    120,
+					Y:    222,
+					Type: MouseLeft,
+				},
+			},
+		},
+		{
+			name: "batched events",
+			buf:  append(encode(0b0000_0000, 100, 16), encode(0b0000_0011, 1000, 500)...),
+			expected: []MouseEvent{
+				{
+					X:    100,
+					Y:    16,
+					Type: MouseLeft,
+				},
+				{
+					X:    1000,
+					Y:    500,
+					Type: MouseRelease,
+				},
+			},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseUTF8MouseEvents(tc.buf)
+			if err != nil {
+				t.Fatalf("unexpected error for test: %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %#v but got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseUTF8MouseEvent_error(t *testing.T) {
+	tt := []struct {
+		name string
+		buf  []byte
+	}{
+		{
+			name: "empty buf",
+			buf:  nil,
+		},
+		{
+			name: "short buf",
+			buf:  []byte("\x1b[M@\xc2\x85"),
+		},
+		{
+			name: "long buf",
+			buf:  []byte("\x1b[M@\xc2\x85A1"),
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseUTF8MouseEvents(tc.buf); err == nil {
+				t.Fatalf("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestParseSGRMouseEvent(t *testing.T) {
+	encode := func(b, x, y int, release bool) []byte {
+		final := 'M'
+		if release {
+			final = 'm'
+		}
+		return []byte(fmt.Sprintf("\x1b[<%d;%d;%d%c", b, x+1, y+1, final))
+	}
+
+	tt := []struct {
+		name     string
+		buf      []byte
+		expected []MouseEvent
+	}{
+		{
+			name: "zero position",
+			buf:  encode(0, 0, 0, false),
+			expected: []MouseEvent{
+				{X: 0, Y: 0, Type: MouseLeft},
+			},
+		},
+		{
+			name: "beyond x10 range",
+			buf:  encode(0, 300, 500, false),
+			expected: []MouseEvent{
+				{X: 300, Y: 500, Type: MouseLeft},
+			},
+		},
+		{
+			name: "left release",
+			buf:  encode(0, 32, 16, true),
+			expected: []MouseEvent{
+				{X: 32, Y: 16, Type: MouseRelease, Released: MouseLeft},
+			},
+		},
+		{
+			name: "right release",
+			buf:  encode(2, 32, 16, true),
+			expected: []MouseEvent{
+				{X: 32, Y: 16, Type: MouseRelease, Released: MouseRight},
+			},
+		},
+		{
+			name: "motion",
+			buf:  encode(35, 32, 16, false),
+			expected: []MouseEvent{
+				{X: 32, Y: 16, Type: MouseMotion},
+			},
+		},
+		{
+			name: "wheel down",
+			buf:  encode(65, 32, 16, false),
+			expected: []MouseEvent{
+				{X: 32, Y: 16, Type: MouseWheelDown},
+			},
+		},
+		{
+			name: "ctrl+alt+shift+middle",
+			buf:  encode(29, 32, 16, false),
+			expected: []MouseEvent{
+				{X: 32, Y: 16, Type: MouseMiddle, Shift: true, Alt: true, Ctrl: true},
+			},
+		},
+		{
+			name: "batched events",
+			buf:  append(encode(0, 32, 16, false), encode(0, 64, 32, true)...),
+			expected: []MouseEvent{
+				{X: 32, Y: 16, Type: MouseLeft},
+				{X: 64, Y: 32, Type: MouseRelease, Released: MouseLeft},
+			},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseSGRMouseEvents(tc.buf, false)
+			if err != nil {
+				t.Fatalf("unexpected error for test: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Fatalf("expected %#v but got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseSGRMouseEvent_error(t *testing.T) {
+	tt := []struct {
+		name string
+		buf  []byte
+	}{
+		{
+			name: "empty buf",
+			buf:  nil,
+		},
+		{
+			name: "x10 event",
+			buf:  []byte("\x1b[M@A1"),
+		},
+		{
+			name: "missing final",
+			buf:  []byte("\x1b[<0;1;1"),
+		},
+		{
+			name: "missing coordinate",
+			buf:  []byte("\x1b[<0;1M"),
+		},
+		{
+			name: "invalid number",
+			buf:  []byte("\x1b[<0;a;1M"),
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseSGRMouseEvents(tc.buf, false); err == nil {
+				t.Fatalf("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestParseMouseModifiersParity(t *testing.T) {
+	const (
+		bitShift = 0b0000_0100
+		bitAlt   = 0b0000_1000
+		bitCtrl  = 0b0001_0000
+	)
+
+	buttons := []struct {
+		name string
+		code int
+	}{
+		{"left", 0b0000_0000},
+		{"middle", 0b0000_0001},
+		{"right", 0b0000_0010},
+		{"release", 0b0000_0011},
+		{"motion", 0b0010_0011},
+		{"drag", 0b0010_0000},
+		{"wheel up", 0b0100_0000},
+		{"wheel down", 0b0100_0001},
+	}
+
+	for _, button := range buttons {
+		for mods := 0; mods < 8; mods++ {
+			code := button.code
+			shift, alt, ctrl := mods&1 != 0, mods&2 != 0, mods&4 != 0
+			if shift {
+				code |= bitShift
+			}
+			if alt {
+				code |= bitAlt
+			}
+			if ctrl {
+				code |= bitCtrl
+			}
+
+			name := fmt.Sprintf("%s shift=%v alt=%v ctrl=%v", button.name, shift, alt, ctrl)
+			t.Run(name, func(t *testing.T) {
+				x10 := []byte{'\x1b', '[', 'M', byte(code + 32), byte(10 + 33), byte(20 + 33)}
+				sgr := []byte(fmt.Sprintf("\x1b[<%d;%d;%dM", code, 10+1, 20+1))
+
+				x10Events, err := parseX10MouseEvents(x10)
+				if err != nil {
+					t.Fatalf("unexpected error parsing x10: %v", err)
+				}
+				sgrEvents, err := parseSGRMouseEvents(sgr, false)
+				if err != nil {
+					t.Fatalf("unexpected error parsing sgr: %v", err)
+				}
+
+				if x10Events[0] != sgrEvents[0] {
+					t.Fatalf("x10 and sgr disagree: %#v vs %#v", x10Events[0], sgrEvents[0])
+				}
+
+				e := x10Events[0]
+				if e.Shift != shift || e.Alt != alt || e.Ctrl != ctrl {
+					t.Fatalf("expected shift=%v alt=%v ctrl=%v, got %#v", shift, alt, ctrl, e)
+				}
+			})
+		}
+	}
+}
+
+func TestCoalesceWheelEvents(t *testing.T) {
+	up := MouseMsg{X: 1, Y: 2, Type: MouseWheelUp}
+	down := MouseMsg{X: 1, Y: 2, Type: MouseWheelDown}
+	withDelta := func(m MouseMsg, d int) MouseMsg {
+		m.Delta = d
+		return m
+	}
+
+	tt := []struct {
+		name     string
+		msgs     []Msg
+		expected []Msg
+	}{
+		{
+			name:     "single notch",
+			msgs:     []Msg{up},
+			expected: []Msg{withDelta(up, 1)},
+		},
+		{
+			name:     "batched notches",
+			msgs:     []Msg{up, up, up},
+			expected: []Msg{withDelta(up, 3)},
+		},
+		{
+			name:     "direction change",
+			msgs:     []Msg{up, up, down},
+			expected: []Msg{withDelta(up, 2), withDelta(down, 1)},
+		},
+		{
+			name: "position change",
+			msgs: []Msg{up, MouseMsg{X: 5, Y: 2, Type: MouseWheelUp}},
+			expected: []Msg{
+				withDelta(up, 1),
+				MouseMsg{X: 5, Y: 2, Type: MouseWheelUp, Delta: 1},
+			},
+		},
+		{
+			name: "other events untouched",
+			msgs: []Msg{MouseMsg{Type: MouseLeft}, KeyMsg{Type: KeyUp}, up},
+			expected: []Msg{
+				MouseMsg{Type: MouseLeft},
+				KeyMsg{Type: KeyUp},
+				withDelta(up, 1),
+			},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			actual := coalesceWheelEvents(tc.msgs)
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Fatalf("expected %#v but got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAnnotateReleases(t *testing.T) {
+	release := MouseMsg{Type: MouseRelease}
+	releaseOf := func(t MouseEventType) MouseMsg {
+		return MouseMsg{Type: MouseRelease, Released: t}
+	}
+
+	t.Run("press then release", func(t *testing.T) {
+		msgs, pressed := annotateReleases([]Msg{
+			MouseMsg{Type: MouseRight},
+			KeyMsg{Type: KeyUp},
+			release,
+		}, MouseUnknown)
+		expected := []Msg{MouseMsg{Type: MouseRight}, KeyMsg{Type: KeyUp}, releaseOf(MouseRight)}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+		if pressed != MouseUnknown {
+			t.Fatalf("expected no button to be pressed, got %v", pressed)
+		}
+	})
+
+	t.Run("drag", func(t *testing.T) {
+		msgs, _ := annotateReleases([]Msg{
+			MouseMsg{X: 1, Type: MouseLeft},
+			MouseMsg{X: 2, Type: MouseLeft},
+			release,
+		}, MouseUnknown)
+		if msgs[2] != releaseOf(MouseLeft) {
+			t.Fatalf("expected release of the left button, got %#v", msgs[2])
+		}
+	})
+
+	t.Run("across reads", func(t *testing.T) {
+		_, pressed := annotateReleases([]Msg{MouseMsg{Type: MouseMiddle}}, MouseUnknown)
+		msgs, _ := annotateReleases([]Msg{release}, pressed)
+		if msgs[0] != releaseOf(MouseMiddle) {
+			t.Fatalf("expected release of the middle button, got %#v", msgs[0])
+		}
+	})
+
+	t.Run("reported release", func(t *testing.T) {
+		// SGR reports the button released, which is kept as it is.
+		msgs, pressed := annotateReleases([]Msg{
+			MouseMsg{Type: MouseLeft},
+			releaseOf(MouseRight),
+			MouseMsg{Type: MouseRelease, Button: 9},
+		}, MouseUnknown)
+		expected := []Msg{
+			MouseMsg{Type: MouseLeft},
+			releaseOf(MouseRight),
+			MouseMsg{Type: MouseRelease, Button: 9},
+		}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+		if pressed != MouseUnknown {
+			t.Fatalf("expected no button to be pressed, got %v", pressed)
+		}
+	})
+
+	t.Run("release without press", func(t *testing.T) {
+		msgs, _ := annotateReleases([]Msg{release, release}, MouseLeft)
+		expected := []Msg{releaseOf(MouseLeft), release}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+	})
+}
+
+func TestDropDuplicateMotions(t *testing.T) {
+	motion := func(x, y int) MouseMsg {
+		return MouseMsg{X: x, Y: y, Type: MouseMotion}
+	}
+
+	t.Run("duplicates collapse", func(t *testing.T) {
+		msgs, last := dropDuplicateMotions([]Msg{motion(1, 1), motion(1, 1), motion(1, 1)}, nil)
+		expected := []Msg{motion(1, 1)}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+		if last != motion(1, 1) {
+			t.Fatalf("expected last message to be returned, got %#v", last)
+		}
+	})
+
+	t.Run("moved motion passes", func(t *testing.T) {
+		msgs, _ := dropDuplicateMotions([]Msg{motion(1, 1), motion(2, 1), motion(2, 1), motion(1, 1)}, nil)
+		expected := []Msg{motion(1, 1), motion(2, 1), motion(1, 1)}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+	})
+
+	t.Run("modifiers differ", func(t *testing.T) {
+		shifted := motion(1, 1)
+		shifted.Shift = true
+		msgs, _ := dropDuplicateMotions([]Msg{motion(1, 1), shifted}, nil)
+		expected := []Msg{motion(1, 1), shifted}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+	})
+
+	t.Run("other events are kept", func(t *testing.T) {
+		click := MouseMsg{X: 1, Y: 1, Type: MouseLeft}
+		msgs, _ := dropDuplicateMotions([]Msg{click, click, motion(1, 1), click}, nil)
+		expected := []Msg{click, click, motion(1, 1), click}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, msgs)
+		}
+	})
+
+	t.Run("across reads", func(t *testing.T) {
+		msgs, _ := dropDuplicateMotions([]Msg{motion(1, 1)}, motion(1, 1))
+		if len(msgs) != 0 {
+			t.Fatalf("expected duplicate of previous read to be dropped, got %#v", msgs)
+		}
+	})
+}
+
+func TestParseDECLocatorEvent(t *testing.T) {
+	tt := []struct {
+		name     string
+		seq      string
+		expected MouseEvent
+		ok       bool
+	}{
+		{
+			name:     "left press",
+			seq:      "\x1b[2;1;10;20;1&w",
+			expected: MouseEvent{X: 19, Y: 9, Type: MouseLeft},
+			ok:       true,
+		},
+		{
+			name:     "left release without page",
+			seq:      "\x1b[3;0;10;20&w",
+			expected: MouseEvent{X: 19, Y: 9, Type: MouseRelease},
+			ok:       true,
+		},
+		{
+			name:     "middle press",
+			seq:      "\x1b[4;2;1;1;1&w",
+			expected: MouseEvent{X: 0, Y: 0, Type: MouseMiddle},
+			ok:       true,
+		},
+		{
+			name:     "right press",
+			seq:      "\x1b[6;4;300;400;1&w",
+			expected: MouseEvent{X: 399, Y: 299, Type: MouseRight},
+			ok:       true,
+		},
+		{
+			name: "locator unavailable",
+			seq:  "\x1b[0&w",
+		},
+		{
+			name: "outside filter rectangle",
+			seq:  "\x1b[10;0;10;20;1&w",
+		},
+		{
+			name: "not a locator report",
+			seq:  "\x1b[2;1;10;20;1w",
+		},
+		{
+			name: "invalid params",
+			seq:  "\x1b[2;a;10;20;1&w",
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			m, ok := parseDECLocatorEvent(tc.seq)
+			if ok != tc.ok {
+				t.Fatalf("expected ok to be %v, got %v", tc.ok, ok)
+			}
+			if m != tc.expected {
+				t.Fatalf("expected %#v, got %#v", tc.expected, m)
+			}
+		})
+	}
+}
+
+func TestParseMouseButtonUnknownButtons(t *testing.T) {
+	tt := []struct {
+		name     string
+		b        int
+		expected MouseEvent
+	}{
+		{
+			name:     "wheel left",
+			b:        0b0100_0010,
+			expected: MouseEvent{Type: MouseUnknown, Button: 6},
+		},
+		{
+			name:     "wheel right with shift",
+			b:        0b0100_0111,
+			expected: MouseEvent{Type: MouseUnknown, Button: 7, Shift: true},
+		},
+		{
+			name:     "button 8",
+			b:        0b1000_0000,
+			expected: MouseEvent{Type: MouseUnknown, Button: 8},
+		},
+		{
+			name:     "button 11",
+			b:        0b1000_0011,
+			expected: MouseEvent{Type: MouseUnknown, Button: 11},
+		},
+		{
+			name:     "wheel up has no button",
+			b:        0b0100_0000,
+			expected: MouseEvent{Type: MouseWheelUp},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			if m := parseMouseButton(tc.b, true); m != tc.expected {
+				t.Errorf("sgr: expected %#v, got %#v", tc.expected, m)
+			}
+			if m := parseMouseButton(tc.b+x10MouseByteOffset, false); m != tc.expected {
+				t.Errorf("x10: expected %#v, got %#v", tc.expected, m)
+			}
+		})
+	}
+
+	t.Run("sgr release", func(t *testing.T) {
+		m, err := parseSGRMouseEvents([]byte("\x1b[<128;1;1m"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []MouseEvent{{Type: MouseRelease, Button: 8}}
+		if !reflect.DeepEqual(m, expected) {
+			t.Errorf("expected %#v, got %#v", expected, m)
+		}
+	})
+}
+
+func TestParseSGRMouseEventsPixels(t *testing.T) {
+	tt := []struct {
+		name     string
+		buf      []byte
+		expected []MouseEvent
+	}{
+		{
+			name:     "press",
+			buf:      []byte("\x1b[<0;640;480M"),
+			expected: []MouseEvent{{PixelX: 640, PixelY: 480, Type: MouseLeft}},
+		},
+		{
+			name:     "release",
+			buf:      []byte("\x1b[<0;641;481m"),
+			expected: []MouseEvent{{PixelX: 641, PixelY: 481, Type: MouseRelease, Released: MouseLeft}},
+		},
+		{
+			name:     "origin",
+			buf:      []byte("\x1b[<0;0;0m"),
+			expected: []MouseEvent{{Type: MouseRelease, Released: MouseLeft}},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := parseSGRMouseEvents(tc.buf, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, m)
+			}
+		})
+	}
+}
+
+func TestReadInputsPixelRelease(t *testing.T) {
+	// A left-button drag ending in a release, in pixels. The release is
+	// decoded from the final m, and its position isn't shifted like cell
+	// positions are.
+	in := "\x1b[<0;100;40M\x1b[<32;101;41M\x1b[<0;102;42m"
+	msgs, err := readInputs(bytes.NewReader([]byte(in)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Msg{
+		MouseMsg{Type: MouseLeft, PixelX: 100, PixelY: 40},
+		MouseMsg{Type: MouseLeft, PixelX: 101, PixelY: 41},
+		MouseMsg{Type: MouseRelease, Released: MouseLeft, PixelX: 102, PixelY: 42},
+	}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, msgs)
+	}
+
+	msgs, _ = deriveMouseCells(msgs, CellSizeMsg{Width: 10, Height: 20})
+	if m := msgs[2].(MouseMsg); m.X != 10 || m.Y != 2 {
+		t.Errorf("expected the release in cell 10,2, got %d,%d", m.X, m.Y)
+	}
+}
+
+func TestNewMouseEvent(t *testing.T) {
+	m := NewMouseEvent(3, 4, MouseLeft, ModShift, ModCtrl|ModAlt)
+	expected := MouseEvent{X: 3, Y: 4, Type: MouseLeft, Shift: true, Alt: true, Ctrl: true}
+	if m != expected {
+		t.Fatalf("expected %#v, got %#v", expected, m)
+	}
+}
+
+func TestMouseEventModifiers(t *testing.T) {
+	tt := []struct {
+		event    MouseEvent
+		expected Modifier
+	}{
+		{MouseEvent{}, 0},
+		{MouseEvent{Shift: true}, ModShift},
+		{MouseEvent{Alt: true}, ModAlt},
+		{MouseEvent{Ctrl: true}, ModCtrl},
+		{MouseEvent{Ctrl: true, Shift: true}, ModCtrl | ModShift},
+		{MouseEvent{Ctrl: true, Alt: true, Shift: true}, ModCtrl | ModAlt | ModShift},
+	}
+
+	for _, tc := range tt {
+		mods := tc.event.Modifiers()
+		if mods != tc.expected {
+			t.Errorf("expected %b for %s, got %b", tc.expected, tc.event, mods)
+		}
+		// Modifiers and NewMouseEvent are inverses.
+		if m := NewMouseEvent(0, 0, tc.event.Type, mods); m != tc.event {
+			t.Errorf("expected %#v, got %#v", tc.event, m)
+		}
+	}
+}
+
+func TestMouseEventHasMods(t *testing.T) {
+	m := MouseEvent{Type: MouseLeft, Ctrl: true, Alt: true}
+
+	for _, mods := range []Modifier{0, ModCtrl, ModAlt, ModCtrl | ModAlt} {
+		if !m.HasMods(mods) {
+			t.Errorf("expected %s to have modifiers %b", m, mods)
+		}
+	}
+	for _, mods := range []Modifier{ModShift, ModCtrl | ModShift, ModCtrl | ModAlt | ModShift} {
+		if m.HasMods(mods) {
+			t.Errorf("expected %s not to have modifiers %b", m, mods)
+		}
+	}
+}
+
+func TestMouseEventScrollArea(t *testing.T) {
+	const top, bottom = 3, 8
+
+	tests := []struct {
+		y      int
+		inside bool
+	}{
+		{2, false},
+		{3, true},
+		{7, true},
+		{8, false},
+	}
+	for _, tc := range tests {
+		m := MouseEvent{X: 4, Y: tc.y, Type: MouseLeft}
+		if got := m.InScrollArea(top, bottom); got != tc.inside {
+			t.Errorf("expected row %d to be in the scroll area: %v, got %v", tc.y, tc.inside, got)
+		}
+		r := m.RelativeTo(top)
+		if r.Y != tc.y-top || r.X != m.X || r.Type != m.Type {
+			t.Errorf("expected %v relative to row %d to be on row %d, got %v", m, top, tc.y-top, r)
+		}
+	}
+}
+
+func TestPixelToCell(t *testing.T) {
+	tt := []struct {
+		px, py, cellW, cellH int
+		x, y                 int
+	}{
+		{0, 0, 10, 20, 0, 0},
+		{9, 19, 10, 20, 0, 0},
+		{10, 20, 10, 20, 1, 1},
+		{19, 39, 10, 20, 1, 1},
+		{25, 61, 10, 20, 2, 3},
+		{-1, -1, 10, 20, -1, -1},
+		{-10, -20, 10, 20, -1, -1},
+		{-11, -21, 10, 20, -2, -2},
+		{25, 61, 0, 20, 0, 0},
+		{25, 61, 10, -1, 0, 0},
+	}
+	for _, tc := range tt {
+		x, y := PixelToCell(tc.px, tc.py, tc.cellW, tc.cellH)
+		if x != tc.x || y != tc.y {
+			t.Errorf("expected %d,%d in %dx%d cells to be cell %d,%d, got %d,%d",
+				tc.px, tc.py, tc.cellW, tc.cellH, tc.x, tc.y, x, y)
+		}
+
+		m := MouseEvent{PixelX: tc.px, PixelY: tc.py}
+		if x, y := m.CellCoords(tc.cellW, tc.cellH); x != tc.x || y != tc.y {
+			t.Errorf("expected CellCoords to match PixelToCell %d,%d, got %d,%d", tc.x, tc.y, x, y)
+		}
+	}
+}
+
+func TestEncodeSGR(t *testing.T) {
+	t.Run("wire form", func(t *testing.T) {
+		tt := []struct {
+			event    MouseEvent
+			expected string
+		}{
+			{NewMouseEvent(0, 0, MouseLeft), "\x1b[<0;1;1M"},
+			{NewMouseEvent(9, 19, MouseRight, ModAlt), "\x1b[<10;10;20M"},
+			{MouseEvent{X: 1, Y: 1, Type: MouseRelease, Released: MouseMiddle}, "\x1b[<1;2;2m"},
+			{MouseEvent{Type: MouseUnknown}, ""},
+		}
+		for _, tc := range tt {
+			if s := string(EncodeSGR(tc.event)); s != tc.expected {
+				t.Errorf("expected %#v to encode to %q, got %q", tc.event, tc.expected, s)
+			}
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, m := range []MouseEvent{
+			NewMouseEvent(0, 0, MouseLeft),
+			NewMouseEvent(300, 200, MouseMiddle, ModShift),
+			NewMouseEvent(5, 5, MouseRight, ModCtrl, ModShift),
+			{X: 1, Y: 2, Type: MouseRelease, Released: MouseLeft},
+			NewMouseEvent(7, 8, MouseMotion, ModAlt),
+			NewMouseEvent(7, 8, MouseWheelUp),
+			NewMouseEvent(7, 8, MouseWheelDown, ModCtrl),
+			{X: 1, Y: 1, Type: MouseUnknown, Button: 7},
+			{X: 1, Y: 1, Type: MouseUnknown, Button: 9},
+			{X: 1, Y: 1, Type: MouseRelease, Button: 10},
+		} {
+			events, err := parseMouseEvents(EncodeSGR(m), false)
+			if err != nil {
+				t.Fatalf("unexpected error parsing %#v: %v", m, err)
+			}
+			if len(events) != 1 || events[0] != m {
+				t.Errorf("expected %#v, got %#v", m, events)
+			}
+		}
+	})
+}
+
+func TestParseMouseButtonWheelWhileDragging(t *testing.T) {
+	// Wheel down with the motion bit set, as sent when scrolling during a
+	// drag in all motion mode.
+	const b = 0b0110_0001
+
+	expected := MouseEvent{Type: MouseWheelDown, Dragging: true}
+	if m := parseMouseButton(b, true); m != expected {
+		t.Errorf("sgr: expected %#v, got %#v", expected, m)
+	}
+	if m := parseMouseButton(b+x10MouseByteOffset, false); m != expected {
+		t.Errorf("x10: expected %#v, got %#v", expected, m)
+	}
+
+	events, err := parseSGRMouseEvents([]byte("\x1b[<96;3;4M"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = MouseEvent{X: 2, Y: 3, Type: MouseWheelUp, Dragging: true}
+	if len(events) != 1 || events[0] != expected {
+		t.Errorf("expected %#v, got %#v", expected, events)
+	}
+	if s := string(EncodeSGR(expected)); s != "\x1b[<96;3;4M" {
+		t.Errorf("expected event to encode to the same sequence, got %q", s)
+	}
+}
+
+func TestProgramMouseMode(t *testing.T) {
+	newProgram := func(opts ...ProgramOption) *Program {
+		var buf bytes.Buffer
+		p := NewProgram(nil, append(opts, WithOutput(&buf))...)
+		p.renderer = newRenderer(p.output, false)
+		return p
+	}
+
+	t.Run("not started", func(t *testing.T) {
+		if m := NewProgram(nil).MouseMode(); m != MouseModeOff {
+			t.Fatalf("expected %v, got %v", MouseModeOff, m)
+		}
+	})
+
+	t.Run("tracking", func(t *testing.T) {
+		p := newProgram()
+		if m := p.MouseMode(); m != MouseModeOff {
+			t.Fatalf("expected %v, got %v", MouseModeOff, m)
+		}
+
+		p.renderer.enableMouseCellMotion()
+		if m := p.MouseMode(); m != MouseModeCellMotion {
+			t.Fatalf("expected %v, got %v", MouseModeCellMotion, m)
+		}
+
+		p.renderer.disableMouseCellMotion()
+		p.renderer.enableMouseAllMotion()
+		if m := p.MouseMode(); m != MouseModeAllMotion {
+			t.Fatalf("expected %v, got %v", MouseModeAllMotion, m)
+		}
+
+		p.renderer.disableMouseAllMotion()
+		if m := p.MouseMode(); m != MouseModeOff {
+			t.Fatalf("expected %v, got %v", MouseModeOff, m)
+		}
+	})
+
+	t.Run("encodings", func(t *testing.T) {
+		p := newProgram(WithMouseUTF8())
+		p.renderer.enableMouseCellMotion()
+		if m := p.MouseMode(); m != MouseModeExtendedMotion {
+			t.Fatalf("expected %v, got %v", MouseModeExtendedMotion, m)
+		}
+
+		p = newProgram(WithMousePixels())
+		if m := p.MouseMode(); m != MouseModeOff {
+			t.Fatalf("expected %v, got %v", MouseModeOff, m)
+		}
+		p.renderer.enableMouseAllMotion()
+		if m := p.MouseMode(); m != MouseModePixelsMotion {
+			t.Fatalf("expected %v, got %v", MouseModePixelsMotion, m)
+		}
+	})
+
+	t.Run("while running", func(t *testing.T) {
+		var buf bytes.Buffer
+		var in bytes.Buffer
+
+		p := NewProgram(&testModel{}, WithInput(&in), WithOutput(&buf), WithMouseCellMotion())
+		go func() {
+			// Polls concurrently with Run setting up the renderer.
+			for p.MouseMode() != MouseModeCellMotion {
+				time.Sleep(time.Millisecond)
+			}
+			p.Quit()
+		}()
+
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestParseX10MouseEventCorrupt(t *testing.T) {
+	for _, buf := range [][]byte{
+		[]byte("\x1b[M \r!!"),
+		[]byte("\x1b[M !\n!"),
+		[]byte("\x1b[M !!\r"),
+	} {
+		if _, err := parseX10MouseEvents(buf); err == nil {
+			t.Errorf("expected an error for %q", buf)
+		}
+		if _, err := parseUTF8MouseEvents(buf); err == nil {
+			t.Errorf("expected an error for %q in utf-8 mode", buf)
+		}
+	}
+}
+
+func TestDeriveMouseCells(t *testing.T) {
+	msgs := []Msg{
+		MouseMsg{Type: MouseLeft, PixelX: 35, PixelY: 50},
+		CellSizeMsg{Width: 10, Height: 20},
+		MouseMsg{Type: MouseRelease, PixelX: 35, PixelY: 50},
+	}
+	msgs, cell := deriveMouseCells(msgs, CellSizeMsg{})
+
+	expected := []Msg{
+		MouseMsg{Type: MouseLeft, PixelX: 35, PixelY: 50},
+		CellSizeMsg{Width: 10, Height: 20},
+		MouseMsg{Type: MouseRelease, X: 3, Y: 2, PixelX: 35, PixelY: 50},
+	}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, msgs)
+	}
+
+	// The cell size carries over to the next read.
+	msgs, _ = deriveMouseCells([]Msg{MouseMsg{Type: MouseMotion, PixelX: 9, PixelY: 45}}, cell)
+	if m := msgs[0].(MouseMsg); m.X != 0 || m.Y != 2 {
+		t.Errorf("expected cell 0,2, got %d,%d", m.X, m.Y)
+	}
+}
+
+func TestViewportMouseEvents(t *testing.T) {
+	v := region{x: 10, y: 5, width: 20, height: 4}
+	msgs := viewportMouseEvents([]Msg{
+		MouseMsg{X: 10, Y: 5, Type: MouseLeft},
+		MouseMsg{X: 29, Y: 8, Type: MouseMotion},
+		KeyMsg{Type: KeyEnter},
+		MouseMsg{X: 30, Y: 8, Type: MouseMotion},
+		MouseMsg{X: 9, Y: 6, Type: MouseWheelUp},
+		MouseMsg{X: 40, Y: 2, Type: MouseRelease},
+	}, v)
+
+	expected := []Msg{
+		MouseMsg{X: 0, Y: 0, Type: MouseLeft},
+		MouseMsg{X: 19, Y: 3, Type: MouseMotion},
+		KeyMsg{Type: KeyEnter},
+		MouseMsg{X: 19, Y: 0, Type: MouseRelease},
+	}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, msgs)
+	}
+}
+
+func TestMouseFilter(t *testing.T) {
+	noMiddle := func(e MouseEvent) bool {
+		return e.Type != MouseMiddle && !e.IsRelease()
+	}
+	f := newMouseFilter(noMiddle)
+
+	msgs := []Msg{
+		MouseMsg{Type: MouseMiddle},
+		MouseMsg{Type: MouseRelease, Released: MouseMiddle},
+		MouseMsg{Type: MouseLeft},
+		KeyMsg{Type: KeyEnter},
+		MouseMsg{Type: MouseRelease, Released: MouseLeft},
+		MouseMsg{Type: MouseRelease, Released: MouseLeft},
+		MouseMsg{Type: MouseUnknown, Button: 8},
+		MouseMsg{Type: MouseRelease, Button: 8},
+	}
+	expected := []Msg{
+		// The release of the left button is kept as its press was, but
+		// only once.
+		MouseMsg{Type: MouseLeft},
+		KeyMsg{Type: KeyEnter},
+		MouseMsg{Type: MouseRelease, Released: MouseLeft},
+		MouseMsg{Type: MouseUnknown, Button: 8},
+		MouseMsg{Type: MouseRelease, Button: 8},
+	}
+	if out := f.filter(msgs); !reflect.DeepEqual(out, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, out)
+	}
+}
+
+func TestParseHighlightReport(t *testing.T) {
+	tt := []struct {
+		name     string
+		seq      string
+		expected []Msg
+	}{
+		{
+			name: "nothing highlighted",
+			seq:  "\x1b[t" + string([]byte{32 + 10, 32 + 5}),
+			expected: []Msg{MouseHighlightMsg{
+				StartX: 9, StartY: 4,
+				EndX: 9, EndY: 4,
+				X: 9, Y: 4,
+			}},
+		},
+		{
+			name: "highlighted",
+			seq:  "\x1b[T" + string([]byte{32 + 1, 32 + 1, 32 + 20, 32 + 3, 32 + 21, 32 + 3}),
+			expected: []Msg{MouseHighlightMsg{
+				StartX: 0, StartY: 0,
+				EndX: 19, EndY: 2,
+				X: 20, Y: 2,
+			}},
+		},
+		{
+			name: "followed by a key",
+			seq:  "\x1b[t" + string([]byte{32 + 1, 32 + 2}) + "a",
+			expected: []Msg{
+				MouseHighlightMsg{StartY: 1, EndY: 1, Y: 1},
+				KeyMsg{Type: KeyRunes, Runes: []rune{'a'}},
+			},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			msgs, err := readInputs(bytes.NewReader([]byte(tc.seq)), false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(msgs, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, msgs)
+			}
+		})
+	}
+}
+
+func TestHighlightReportLen(t *testing.T) {
+	for _, seq := range []string{
+		"\x1b[t",
+		"\x1b[t!",
+		"\x1b[T!!!!!",
+		"\x1b[t !",
+		"\x1b[1t",
+	} {
+		if n := highlightReportLen([]byte(seq)); n != 0 {
+			t.Errorf("expected %q not to be a highlight report, got length %d", seq, n)
+		}
+	}
+}
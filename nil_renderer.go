@@ -2,18 +2,41 @@ package tea
 
 type nilRenderer struct{}
 
-func (n nilRenderer) start()                  {}
-func (n nilRenderer) stop()                   {}
-func (n nilRenderer) kill()                   {}
-func (n nilRenderer) write(v string)          {}
-func (n nilRenderer) repaint()                {}
-func (n nilRenderer) clearScreen()            {}
-func (n nilRenderer) altScreen() bool         { return false }
-func (n nilRenderer) enterAltScreen()         {}
-func (n nilRenderer) exitAltScreen()          {}
-func (n nilRenderer) showCursor()             {}
-func (n nilRenderer) hideCursor()             {}
-func (n nilRenderer) enableMouseCellMotion()  {}
-func (n nilRenderer) disableMouseCellMotion() {}
-func (n nilRenderer) enableMouseAllMotion()   {}
-func (n nilRenderer) disableMouseAllMotion()  {}
+func (n nilRenderer) start()                       {}
+func (n nilRenderer) stop()                        {}
+func (n nilRenderer) kill()                        {}
+func (n nilRenderer) write(v string)               {}
+func (n nilRenderer) writeBytes(b []byte)          {}
+func (n nilRenderer) flush()                       {}
+func (n nilRenderer) afterFlush(fn func())         { fn() }
+func (n nilRenderer) batch(fn func())              { fn() }
+func (n nilRenderer) repaint()                     {}
+func (n nilRenderer) clearScreen()                 {}
+func (n nilRenderer) clearToEndOfScreen()          {}
+func (n nilRenderer) clearToEndOfLine()            {}
+func (n nilRenderer) clearLine()                   {}
+func (n nilRenderer) scrollUp(int)                 {}
+func (n nilRenderer) scrollDown(int)               {}
+func (n nilRenderer) resetTerminal()               {}
+func (n nilRenderer) execute(seq string)           {}
+func (n nilRenderer) altScreen() bool              { return false }
+func (n nilRenderer) enterAltScreen()              {}
+func (n nilRenderer) exitAltScreen()               {}
+func (n nilRenderer) reverseVideo() bool           { return false }
+func (n nilRenderer) setReverseVideo(bool)         {}
+func (n nilRenderer) alternateScroll() bool        { return false }
+func (n nilRenderer) setAlternateScroll(bool)      {}
+func (n nilRenderer) insertMode() bool             { return false }
+func (n nilRenderer) setInsertMode(bool)           {}
+func (n nilRenderer) cursorStyle() cursorStyle     { return cursorStyleDefault }
+func (n nilRenderer) setCursorStyle(cursorStyle)   {}
+func (n nilRenderer) showCursor()                  {}
+func (n nilRenderer) hideCursor()                  {}
+func (n nilRenderer) setCursorPosition(x, y int)   {}
+func (n nilRenderer) syncCursor(bool, int, int)    {}
+func (n nilRenderer) mouseCellMotionEnabled() bool { return false }
+func (n nilRenderer) mouseAllMotionEnabled() bool  { return false }
+func (n nilRenderer) enableMouseCellMotion()       {}
+func (n nilRenderer) disableMouseCellMotion()      {}
+func (n nilRenderer) enableMouseAllMotion()        {}
+func (n nilRenderer) disableMouseAllMotion()       {}
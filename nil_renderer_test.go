@@ -8,6 +8,17 @@ func TestNilRenderer(t *testing.T) {
 	r.stop()
 	r.kill()
 	r.write("a")
+	r.flush()
+	var called bool
+	r.afterFlush(func() { called = true })
+	if !called {
+		t.Errorf("afterFlush should call the function right away")
+	}
+	called = false
+	r.batch(func() { called = true })
+	if !called {
+		t.Errorf("batch should call the function")
+	}
 	r.repaint()
 	r.enterAltScreen()
 	if r.altScreen() {
@@ -15,9 +26,36 @@ func TestNilRenderer(t *testing.T) {
 	}
 	r.exitAltScreen()
 	r.clearScreen()
+	r.clearToEndOfScreen()
+	r.clearToEndOfLine()
+	r.clearLine()
+	r.scrollUp(1)
+	r.scrollDown(1)
+	r.resetTerminal()
+	r.execute("\x1b[c")
+	r.setReverseVideo(true)
+	if r.reverseVideo() {
+		t.Errorf("reverseVideo should always return false")
+	}
+	r.setAlternateScroll(true)
+	if r.alternateScroll() {
+		t.Errorf("alternateScroll should always return false")
+	}
+	r.setInsertMode(true)
+	if r.insertMode() {
+		t.Errorf("insertMode should always return false")
+	}
+	r.setCursorStyle(cursorStyle(2))
+	if r.cursorStyle() != cursorStyleDefault {
+		t.Errorf("cursorStyle should always return the default style")
+	}
 	r.showCursor()
 	r.hideCursor()
+	r.setCursorPosition(1, 1)
 	r.enableMouseCellMotion()
+	if r.mouseCellMotionEnabled() || r.mouseAllMotionEnabled() {
+		t.Errorf("mouse should always be disabled")
+	}
 	r.disableMouseCellMotion()
 	r.enableMouseAllMotion()
 	r.disableMouseAllMotion()
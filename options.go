@@ -3,6 +3,7 @@ package tea
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/muesli/termenv"
 )
@@ -32,6 +33,23 @@ func WithOutput(output io.Writer) ProgramOption {
 	}
 }
 
+// WithEnvironment sets the environment the program uses instead of the
+// process environment, given as a list of "key=value" strings like the one
+// returned by os.Environ. This is useful where the environment is stripped,
+// and for making capability dependent behavior reproducible in tests.
+//
+// The environment is consulted when detecting the output's capabilities:
+// NO_COLOR, CLICOLOR and CLICOLOR_FORCE can disable or force colors, CI
+// makes the output be treated as not being a terminal, and, if it is one,
+// TERM and COLORTERM decide the color profile and COLORFGBG whether the
+// background is dark. On Windows, ConEmuANSI, ANSICON and ANSICON_VER are
+// consulted as well.
+func WithEnvironment(env []string) ProgramOption {
+	return func(p *Program) {
+		p.environ = environ(env)
+	}
+}
+
 // WithInput sets the input which, by default, is stdin. In most cases you
 // won't need to use this.
 func WithInput(input io.Reader) ProgramOption {
@@ -56,6 +74,21 @@ func WithoutSignalHandler() ProgramOption {
 	}
 }
 
+// WithoutCtrlCQuit stops the program from quitting automatically when it
+// receives an interrupt (SIGINT), which is what pressing ctrl+c does when
+// input is not a TTY. Instead, the interrupt is delivered to Update as a
+// KeyMsg of type KeyCtrlC, just like ctrl+c is when the terminal is in raw
+// mode.
+//
+// Unlike WithoutSignalHandler this keeps the signal handler in place, so
+// SIGTERM still quits the program, and panic recovery is unaffected. Note
+// that your program is then responsible for offering a way to quit.
+func WithoutCtrlCQuit() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withoutCtrlCQuit
+	}
+}
+
 // WithoutCatchPanics disables the panic catching that Bubble Tea does by
 // default. If panic catching is disabled the terminal will be in a fairly
 // unusable state after a panic because Bubble Tea will not perform its usual
@@ -86,6 +119,41 @@ func WithAltScreen() ProgramOption {
 	}
 }
 
+// WithInBandResize asks the terminal to report resizes itself, in the input,
+// as newer terminals can (mode 2048). Each report is sent to Update as a
+// WindowSizeMsg. This is how resizes are detected where there's no SIGWINCH
+// signal, notably on Windows, and it works over SSH too.
+//
+// Terminals that don't support it ignore the request, and the program carries
+// on detecting resizes as usual, where it can. Where both work, Update may
+// get the same size twice for a resize. It has no effect with WithViewport,
+// whose size is fixed.
+func WithInBandResize() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withInBandResize
+	}
+}
+
+// WithAltScreenIfSupported starts the program with the alternate screen buffer
+// enabled, like WithAltScreen, but only if the terminal supports it. On
+// terminals that don't, such as the Linux console and VT100s, entering it
+// anyway leaves a mess behind.
+//
+// Support is looked up in the terminfo entry of the terminal named by TERM,
+// which must define both smcup and rmcup, the sequences that enter and exit
+// the alternate screen. On Windows, which has no terminfo, consoles are
+// assumed to support it.
+//
+// If the terminal doesn't support it, or the output isn't a terminal at all,
+// or there's no terminfo entry to tell, the program runs inline, as if the
+// option hadn't been given, and an AltScreenUnavailableMsg is sent to Update
+// so that it can adapt its view.
+func WithAltScreenIfSupported() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withAltScreenIfSupported
+	}
+}
+
 // WithMouseCellMotion starts the program with the mouse enabled in "cell
 // motion" mode.
 //
@@ -97,6 +165,12 @@ func WithAltScreen() ProgramOption {
 // the EnableMouseCellMotion command. To disable the mouse when the program is
 // running use the DisableMouse command.
 //
+// Along with the mouse, the SGR encoding of coordinates (mode 1006) is
+// enabled, which works in terminals of any size, unlike the X10 encoding
+// terminals without SGR support fall back to, which stops at 222 columns and
+// rows. This is the recommended setup for modern terminals; see WithMouseUTF8
+// for older ones.
+//
 // The mouse will be automatically disabled when the program exits.
 func WithMouseCellMotion() ProgramOption {
 	return func(p *Program) {
@@ -119,6 +193,9 @@ func WithMouseCellMotion() ProgramOption {
 // EnableMouseAllMotion command. To disable the mouse when the program is
 // running use the DisableMouse command.
 //
+// As with WithMouseCellMotion, the SGR encoding of coordinates is enabled
+// along with the mouse.
+//
 // The mouse will be automatically disabled when the program exits.
 func WithMouseAllMotion() ProgramOption {
 	return func(p *Program) {
@@ -127,6 +204,72 @@ func WithMouseAllMotion() ProgramOption {
 	}
 }
 
+// WithMouseUTF8 asks the terminal to encode mouse coordinates as UTF-8
+// (extended mouse mode 1005) whenever the mouse is enabled. This lifts the
+// X10 encoding's limit of 222 columns and rows for terminals which don't
+// support the SGR encoding but do support this one.
+//
+// Terminals supporting both prefer SGR, so with this option the SGR encoding
+// isn't enabled along with the mouse.
+//
+// It doesn't enable the mouse on its own; use it alongside
+// WithMouseCellMotion, WithMouseAllMotion or the respective commands.
+// Incoming events are decoded regardless of the encoding the terminal picks.
+func WithMouseUTF8() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withMouseUTF8
+	}
+}
+
+// WithDECLocator enables the DEC locator, which some DEC-compatible terminals
+// use to report the mouse instead of the xterm protocols enabled by
+// WithMouseCellMotion and WithMouseAllMotion. Button presses and releases are
+// delivered to Update as regular mouse events. The locator doesn't report
+// motion or the wheel, and it doesn't report modifiers.
+//
+// Most terminals don't support the DEC locator, so only use this if you're
+// targeting one that does. It's disabled when the program exits.
+func WithDECLocator() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withDECLocator
+	}
+}
+
+// WithMouseHighlight enables highlight tracking (mode 1001), in which the
+// terminal highlights text dragged over with the left mouse button itself, and
+// reports what was highlighted in a MouseHighlightMsg once the button is
+// released. This suits tools that want the terminal's own selection rather
+// than managing one in the view. Presses are still reported as mouse events.
+//
+// Highlight tracking is another mouse tracking mode, so it replaces cell or
+// all motion tracking: don't combine it with WithMouseCellMotion or
+// WithMouseAllMotion. Few terminals other than xterm support it; the others
+// ignore it and report no mouse events at all. It's disabled when the program
+// exits.
+func WithMouseHighlight() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withMouseHighlight
+	}
+}
+
+// WithReportScroll makes sure mouse wheel events are reported as
+// MouseWheelUp and MouseWheelDown events, rather than, say, being translated
+// to arrow keys by the terminal while in the altscreen.
+//
+// As terminals only report the wheel while mouse tracking is on, this enables
+// the mouse in "cell motion" mode unless WithMouseCellMotion or
+// WithMouseAllMotion is also set.
+//
+// Wheel events also carry the number of notches scrolled in their Delta
+// field. When the terminal reports several notches at once they are merged
+// into a single event, which is handy for accumulating scroll velocity. When
+// it reports one event per notch Delta is 1.
+func WithReportScroll() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withReportScroll
+	}
+}
+
 // WithoutRenderer disables the renderer. When this is set output and log
 // statements will be plainly sent to stdout (or another output if one is set)
 // without any rendering and redrawing logic. In other words, printing and
@@ -141,6 +284,208 @@ func WithoutRenderer() ProgramOption {
 	}
 }
 
+// WithoutANSI makes the program write plain text without any escape
+// sequences, for outputs that don't understand them, such as log files. Each
+// frame is written after the previous one with styling stripped, rather than
+// redrawn in place, and commands controlling the terminal, such as entering
+// the alternate screen or enabling the mouse, do nothing.
+//
+// This mode is used automatically when the output is a terminal and TERM is
+// set to "dumb". Outputs that aren't terminals aren't detected, as they often
+// end up in one, so use this option to get plain text there.
+func WithoutANSI() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withoutANSI
+	}
+}
+
+// WithManualFlush stops the renderer from rendering frames on its own. Instead,
+// frames are only rendered when the Flush command is run, and once more when
+// the program quits. This gives programs that build up their state over
+// several updates precise control over when it's shown.
+//
+// Be careful: if your program forgets to flush, the screen will appear
+// frozen. That includes reacting to a WindowSizeMsg, after which nothing is
+// redrawn until the next Flush.
+func WithManualFlush() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withManualFlush
+	}
+}
+
+// WithShownCursor leaves the terminal cursor visible while the program runs,
+// rather than hiding it on startup. This suits programs that edit text and
+// want the real cursor where the editing happens: place it with
+// SetCursorPosition, or find out where it is with RequestCursorPosition. The
+// ShowCursor and HideCursor commands keep working at runtime.
+//
+// Keep in mind that the cursor is moved around to draw every frame, so a
+// visible cursor that isn't put back in a sensible place after rendering can
+// look janky.
+func WithShownCursor() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withShownCursor
+	}
+}
+
+// WithWindowSizeFunc sets the function used to find out the size of the
+// terminal, in columns and rows, instead of querying the output. This is for
+// programs whose output isn't the terminal being drawn to, or not directly,
+// such as when running behind a PTY wrapper or serving the program remotely:
+// the function can ask the PTY master or the remote client instead.
+//
+// The function is called on startup, and whenever the terminal is resized
+// (SIGWINCH, on systems that have it), or restored after ReleaseTerminal.
+// Each call results in a WindowSizeMsg, except that a size of zero is retried
+// a few times, like the size of a terminal starting up. An error stops the
+// program, as with any other failure to get the size.
+func WithWindowSizeFunc(fn func() (width, height int, err error)) ProgramOption {
+	return func(p *Program) {
+		p.windowSizeFunc = fn
+	}
+}
+
+// WithMousePixels asks the terminal to report mouse positions in pixels rather
+// than cells (SGR-Pixels, mode 1016), for programs that need finer precision,
+// such as ones drawing images. It only has an effect together with
+// WithMouseCellMotion or WithMouseAllMotion, and only in terminals supporting
+// it.
+//
+// In this mode the position of mouse events is reported in the PixelX and
+// PixelY fields of MouseMsg. To keep X and Y meaningful, the terminal is asked
+// for its cell size (see RequestCellSize) on startup and after every resize,
+// and X and Y are set to the cell containing the pixel. Until the terminal
+// replies, or if it doesn't support the query, X and Y are zero.
+func WithMousePixels() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withMousePixels
+	}
+}
+
+// WithMessageBufferSize sets how many messages can be queued for Update
+// before sending another one blocks. By default there's no buffer: Send, and
+// every other source of messages such as input and commands, waits until
+// Update is ready to take the message.
+//
+// Messages are never dropped. When the buffer is full, Send blocks until
+// Update catches up, so a producer that's faster than Update is slowed down
+// rather than using more and more memory. A buffer smooths out bursts of
+// messages, such as from a network stream, without changing that.
+func WithMessageBufferSize(n int) ProgramOption {
+	return func(p *Program) {
+		p.msgBufferSize = n
+	}
+}
+
+// WithStartPaused creates the program paused, so that Run doesn't start until
+// Resume is called. This helps coordinate startup with other parts of an
+// application, as the program can be fully created and running in its own
+// goroutine before it takes over the terminal.
+//
+// While paused, the terminal is left alone, nothing is rendered and Update
+// isn't called. Messages sent with Send are queued, without blocking, and
+// processed in order once the program is resumed, before any sent later.
+// Killing the program while it's paused makes Run return ErrProgramKilled,
+// after calling the functions registered with OnExit.
+func WithStartPaused() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withStartPaused
+	}
+}
+
+// WithViewport makes the program render to a region of the terminal rather
+// than the whole of it, for embedding it in a layout managed by something
+// else. The region's top left corner is at column x and row y, counting from
+// zero, and it's w cells wide and h cells high.
+//
+// Every frame is drawn inside the region: lines are clipped to its width,
+// lines beyond its height are dropped, and the rest is padded with blanks, so
+// that everything outside of it is left untouched. The cursor is restored to
+// where it was after each frame. The model is told the region's size in a
+// WindowSizeMsg instead of the terminal's, and isn't told about resizes of
+// the terminal. Lines printed with Println and Printf are discarded.
+//
+// Mouse events are reported relative to the region too, so that (0, 0) is
+// its top left corner, and events outside of it are dropped. Releases are the
+// exception: they're moved onto the region's nearest edge, so that a drag
+// leaving it still ends. Positions in pixels, see WithMousePixels, are left
+// relative to the terminal.
+//
+// A width or height of zero or less means the whole terminal is used, as
+// usual.
+func WithViewport(x, y, w, h int) ProgramOption {
+	return func(p *Program) {
+		if x < 0 {
+			x = 0
+		}
+		if y < 0 {
+			y = 0
+		}
+		p.viewport = region{x: x, y: y, width: w, height: h}
+	}
+}
+
+// WithMaxFrameSize limits the size of each frame the renderer writes to the
+// terminal to the given number of bytes. Views larger than that are cut off
+// at the last full line that fits and a warning is rendered in place of the
+// rest. This guards against a View bug producing a gigantic string, which
+// could otherwise flood the terminal and freeze it for a long time.
+//
+// By default frames are unlimited. A size of zero or less also means
+// unlimited.
+func WithMaxFrameSize(bytes int) ProgramOption {
+	return func(p *Program) {
+		p.maxFrameSize = bytes
+	}
+}
+
+// WithRenderMetrics sets a function that's called with statistics about every
+// frame the renderer writes to the terminal, such as how long it took and how
+// many bytes it wrote. Use it to find out whether rendering is what makes
+// your program sluggish.
+//
+// The function is called from the renderer while it holds its lock, so it
+// must return quickly and must not interact with the program. Without it no
+// statistics are gathered at all.
+func WithRenderMetrics(fn func(RenderStats)) ProgramOption {
+	return func(p *Program) {
+		p.renderMetrics = fn
+	}
+}
+
+// WithOutputTap sets a function that's called with every chunk of output the
+// renderer writes to the terminal, along with the time it was written. Along
+// with WithInputDebug, it's enough to record a session, asciinema-style.
+//
+// The function is called after the chunk has been written, so it can't alter
+// the output, and it's called from the renderer while it holds its lock, so
+// it must return quickly and must not interact with the program. p is only
+// valid during the call; copy it to keep it. Output written by commands run
+// with Exec doesn't go through the renderer, and isn't seen by the tap.
+func WithOutputTap(fn func(p []byte, t time.Time)) ProgramOption {
+	return func(p *Program) {
+		p.outputTap = fn
+	}
+}
+
+// WithRepeatCompression writes runs of the same character in the view, such
+// as a line of spaces or a box border, as the character followed by REP, a
+// sequence that tells the terminal to repeat it, rather than the character
+// over and over. This saves bandwidth on slow connections.
+//
+// REP isn't supported everywhere and there's no way to ask the terminal
+// about it directly, so the program asks the terminal for its name and
+// version at startup, and only uses REP for terminals known to support it:
+// xterm, kitty, WezTerm, foot and contour. The query is the program's own:
+// neither its reply nor its timeout is sent to Update, and it doesn't affect
+// RequestTerminalVersion. Only runs of at least 8 characters are compressed,
+// and only of printable ASCII, box drawing characters and block elements.
+func WithRepeatCompression() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withRepeatCompression
+	}
+}
+
 // WithANSICompressor removes redundant ANSI sequences to produce potentially
 // smaller output, at the cost of some processing overhead.
 //
@@ -151,3 +496,210 @@ func WithANSICompressor() ProgramOption {
 		p.startupOptions |= withANSICompressor
 	}
 }
+
+// WithUnknownSequenceHandler sets a function that's called with the raw bytes
+// of every input escape sequence Bubble Tea doesn't recognize, such as
+// replies to queries it doesn't know about or keys reported by uncommon
+// terminals. The message it returns is sent to Update. If it returns nil the
+// sequence is dropped, which is also what happens when no handler is set.
+//
+// The handler is called from the goroutine reading input, so it should return
+// quickly and must not block.
+func WithUnknownSequenceHandler(handler func([]byte) Msg) ProgramOption {
+	return func(p *Program) {
+		p.unknownSequenceHandler = handler
+	}
+}
+
+// WithInputDebug writes a hex dump of every raw buffer read from the input to
+// the given writer, before Bubble Tea parses it into messages. This is handy
+// when figuring out why a key or mouse event isn't recognized: the dump shows
+// exactly what the terminal sent.
+//
+// The dump is only a copy; the input itself is parsed as usual. Writes happen
+// on the goroutine reading input and errors are ignored, so use something
+// fast such as a file.
+func WithInputDebug(w io.Writer) ProgramOption {
+	return func(p *Program) {
+		p.inputDebug = w
+	}
+}
+
+// WithInputReadTimeout sets how long to wait for the rest of an escape
+// sequence when input stops in the middle of one. Terminals send keys like the
+// arrows and mouse events as escape sequences, which can arrive split across
+// reads, for instance over a slow SSH connection. Without a timeout each read
+// is parsed on its own, so the pieces of a split sequence come out as bogus
+// keypresses, and a sequence's leading ESC can be mistaken for the Escape key.
+//
+// With a timeout, a read that ends with an unfinished sequence, including a
+// lone ESC, waits up to d for more input to complete it. If nothing arrives
+// in time, what's there is parsed as is, so pressing Escape on its own is
+// reported as the Escape key after d.
+//
+// This is a tradeoff: a longer timeout assembles sequences more reliably on
+// slow connections, but delays the Escape key by as much. Something like 50
+// milliseconds suits most local terminals.
+func WithInputReadTimeout(d time.Duration) ProgramOption {
+	return func(p *Program) {
+		p.inputReadTimeout = d
+	}
+}
+
+// WithIdleTimeout sends an IdleMsg to Update once the user hasn't typed or
+// used the mouse for d, and again every d after that until they do. It's meant
+// for things like screensavers, auto-saving and logging out inactive users,
+// without having to keep a ticker and the time of the last input yourself.
+//
+// Only input from the user counts: keys, mouse events, and the BulkInputMsg
+// and RawMsg carrying them. Other messages, such as window resizes, replies
+// to terminal queries, ticks, and whatever commands return, don't keep the
+// program from going idle.
+func WithIdleTimeout(d time.Duration) ProgramOption {
+	return func(p *Program) {
+		p.idleTimeout = d
+	}
+}
+
+// WithBulkInputDetection sends text that arrives all at once in a single
+// BulkInputMsg instead of a KeyMsg per character. Without bracketed paste, a
+// paste arrives as a flood of keys, each of which could trigger a keybinding;
+// this lets you handle it as text instead.
+//
+// It's a heuristic: input is considered bulk when a single read from the
+// terminal holds at least 32 characters, all of them printable, spaces, tabs
+// or line breaks. Fast typing never gets there, but short pastes don't
+// either, and arrive as keys as usual. Long pastes are split over several
+// reads, so they arrive as several BulkInputMsgs, possibly followed by keys
+// for the tail. Leave it off if you want to handle every key.
+func WithBulkInputDetection() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withBulkInputDetection
+	}
+}
+
+// WithPasteNormalization turns the line endings of pasted text into LF, the
+// line ending most text inputs expect. Terminals send line breaks in pastes
+// as CR, CRLF or LF, depending on the terminal and where the text was copied
+// from, and a single paste can mix them.
+//
+// Paste detection isn't supported, so this relies on WithBulkInputDetection's
+// guess at what was pasted, and only affects the text of BulkInputMsg; keys,
+// including enter, arrive as they are. A CRLF
+// split between two BulkInputMsgs still becomes a single LF. Without it, the
+// line breaks are left as they were received.
+func WithPasteNormalization() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withPasteNormalization
+	}
+}
+
+// WithRawInput sends the raw bytes of every read from the input to Update in a
+// RawMsg. It comes before the key and mouse messages parsed from those bytes,
+// which are sent as usual.
+func WithRawInput() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withRawInput
+	}
+}
+
+// WithRawInputOnly sends the raw bytes of every read from the input to Update
+// in a RawMsg, instead of the key and mouse messages parsed from them. This is
+// the lowest level of input handling, leaving all of the parsing to you. Note
+// that this means there's no KeyMsg for ctrl+c either, so make sure your
+// program can still be quit.
+func WithRawInputOnly() ProgramOption {
+	return func(p *Program) {
+		p.startupOptions |= withRawInputOnly
+	}
+}
+
+// WithMiddleware wraps the model with the given middleware when the program
+// starts. Middleware returns a model that stands in for the one it's given,
+// and can intercept its Init, Update and View, to do things like logging
+// messages, remapping keys, or adding to views and commands, without
+// changing the model itself:
+//
+//	type logger struct{ tea.Model }
+//
+//	func (l logger) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+//	    log.Printf("%#v", msg)
+//	    var cmd tea.Cmd
+//	    l.Model, cmd = l.Model.Update(msg)
+//	    return l, cmd
+//	}
+//
+//	func (l logger) Unwrap() tea.Model { return l.Model }
+//
+//	p := tea.NewProgram(model, tea.WithMiddleware(func(m tea.Model) tea.Model {
+//	    return logger{m}
+//	}))
+//
+// Note that Update has to return the middleware, wrapping the updated model,
+// rather than the model itself, or the middleware is gone from then on.
+//
+// The option can be given several times. The first middleware given is the
+// outermost, so it's the first to see each message on its way to Update, and
+// the last to see the commands and views on their way back. Run returns the
+// model unwrapped, provided the middleware implements an Unwrap method
+// returning the model it wraps, as above. Optional interfaces of the model,
+// such as Renderable, are only used if the middleware implements them too.
+func WithMiddleware(mw func(Model) Model) ProgramOption {
+	return func(p *Program) {
+		p.middleware = append(p.middleware, mw)
+	}
+}
+
+// WithMouseFilter drops the mouse events for which accept returns false before
+// they reach Update, to cut down on the events a program has no use for. For
+// example, a program ignoring the middle button entirely could use:
+//
+//	tea.WithMouseFilter(func(e tea.MouseEvent) bool {
+//		return e.Type != tea.MouseMiddle
+//	})
+//
+// A release is never dropped when the press of the same button got through,
+// so that the program doesn't think the button is stuck down. The filter
+// sees events after their position is made relative to the viewport, if any,
+// and the released button is set on releases.
+func WithMouseFilter(accept func(MouseEvent) bool) ProgramOption {
+	return func(p *Program) {
+		p.mouseFilter = newMouseFilter(accept)
+	}
+}
+
+// WithMouseMotionThrottle limits the rate at which mouse motion events are
+// delivered to Update to at most one per the given interval. This is useful
+// with WithMouseAllMotion, where hovering can produce a flood of motion
+// events.
+//
+// Motion events arriving too soon after the last delivered one are dropped,
+// except for the most recent of them, which is delivered once the interval
+// has elapsed so the latest pointer position is never lost. Clicks, releases
+// and wheel events are never throttled.
+func WithMouseMotionThrottle(d time.Duration) ProgramOption {
+	return func(p *Program) {
+		p.mouseMotionThrottle = newMouseMotionThrottle(d, p.Send)
+	}
+}
+
+// WithScrollGrouping groups bursts of mouse wheel events into scroll gestures,
+// each sent to Update as a single MouseScrollMsg in place of the individual
+// MouseWheelUp and MouseWheelDown events. Trackpads in particular report a
+// flick as a quick burst of wheel events, and with this option a view can
+// scroll by the gesture's Lines, or faster for a high Momentum, rather than
+// by a line per event.
+//
+// Wheel events in the same direction less than 100 milliseconds apart belong
+// to the same gesture. A change of direction ends the gesture and starts a new
+// one, as does any other message in between, such as a keypress or a click.
+// As a gesture is only complete once it's over, MouseScrollMsg arrives up to
+// 100 milliseconds after its last wheel event.
+//
+// Wheel events are only reported while the mouse is enabled, see
+// WithReportScroll.
+func WithScrollGrouping() ProgramOption {
+	return func(p *Program) {
+		p.scrollGrouper = newScrollGrouper(scrollGestureWindow, p.Send)
+	}
+}
@@ -3,6 +3,7 @@ package tea
 import (
 	"bytes"
 	"testing"
+	"time"
 )
 
 func TestOptions(t *testing.T) {
@@ -35,6 +36,90 @@ func TestOptions(t *testing.T) {
 		}
 	})
 
+	t.Run("mouse motion throttle", func(t *testing.T) {
+		p := NewProgram(nil, WithMouseMotionThrottle(time.Second))
+		if p.mouseMotionThrottle == nil || p.mouseMotionThrottle.interval != time.Second {
+			t.Errorf("expected mouse motion throttle to be set, got %v", p.mouseMotionThrottle)
+		}
+	})
+
+	t.Run("mouse filter", func(t *testing.T) {
+		p := NewProgram(nil, WithMouseFilter(func(MouseEvent) bool { return false }))
+		if p.mouseFilter == nil || p.mouseFilter.accept(MouseEvent{}) {
+			t.Errorf("expected mouse filter to be set, got %v", p.mouseFilter)
+		}
+	})
+
+	t.Run("scroll grouping", func(t *testing.T) {
+		p := NewProgram(nil, WithScrollGrouping())
+		if p.scrollGrouper == nil || p.scrollGrouper.window != scrollGestureWindow {
+			t.Errorf("expected scroll grouping to be set, got %v", p.scrollGrouper)
+		}
+	})
+
+	t.Run("max frame size", func(t *testing.T) {
+		p := NewProgram(nil, WithMaxFrameSize(1024))
+		if p.maxFrameSize != 1024 {
+			t.Errorf("expected max frame size to be 1024, got %d", p.maxFrameSize)
+		}
+	})
+
+	t.Run("render metrics", func(t *testing.T) {
+		p := NewProgram(nil, WithRenderMetrics(func(RenderStats) {}))
+		if p.renderMetrics == nil {
+			t.Errorf("expected render metrics function to be set")
+		}
+	})
+
+	t.Run("output tap", func(t *testing.T) {
+		p := NewProgram(nil, WithOutputTap(func([]byte, time.Time) {}))
+		if p.outputTap == nil {
+			t.Errorf("expected output tap to be set")
+		}
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		mw := func(m Model) Model { return m }
+		p := NewProgram(nil, WithMiddleware(mw), WithMiddleware(mw))
+		if len(p.middleware) != 2 {
+			t.Errorf("expected 2 middleware, got %d", len(p.middleware))
+		}
+	})
+
+	t.Run("message buffer size", func(t *testing.T) {
+		p := NewProgram(nil, WithMessageBufferSize(16))
+		if cap(p.msgs) != 16 {
+			t.Errorf("expected message buffer size 16, got %d", cap(p.msgs))
+		}
+
+		p = NewProgram(nil)
+		if cap(p.msgs) != 0 {
+			t.Errorf("expected no message buffer by default, got %d", cap(p.msgs))
+		}
+	})
+
+	t.Run("input debug", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgram(nil, WithInputDebug(&buf))
+		if p.inputDebug != &buf {
+			t.Errorf("expected input debug writer to be set")
+		}
+	})
+
+	t.Run("input read timeout", func(t *testing.T) {
+		p := NewProgram(nil, WithInputReadTimeout(50*time.Millisecond))
+		if p.inputReadTimeout != 50*time.Millisecond {
+			t.Errorf("expected input read timeout 50ms, got %v", p.inputReadTimeout)
+		}
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		p := NewProgram(nil, WithIdleTimeout(time.Minute))
+		if p.idleTimeout != time.Minute {
+			t.Errorf("expected idle timeout 1m, got %v", p.idleTimeout)
+		}
+	})
+
 	t.Run("startup options", func(t *testing.T) {
 		exercise := func(t *testing.T, opt ProgramOption, expect startupOptions) {
 			p := NewProgram(nil, opt)
@@ -63,6 +148,74 @@ func TestOptions(t *testing.T) {
 			exercise(t, WithoutSignalHandler(), withoutSignalHandler)
 		})
 
+		t.Run("without ctrl+c quit", func(t *testing.T) {
+			exercise(t, WithoutCtrlCQuit(), withoutCtrlCQuit)
+		})
+
+		t.Run("manual flush", func(t *testing.T) {
+			exercise(t, WithManualFlush(), withManualFlush)
+		})
+
+		t.Run("mouse utf-8", func(t *testing.T) {
+			exercise(t, WithMouseUTF8(), withMouseUTF8)
+		})
+
+		t.Run("dec locator", func(t *testing.T) {
+			exercise(t, WithDECLocator(), withDECLocator)
+		})
+
+		t.Run("bulk input detection", func(t *testing.T) {
+			exercise(t, WithBulkInputDetection(), withBulkInputDetection)
+		})
+
+		t.Run("paste normalization", func(t *testing.T) {
+			exercise(t, WithPasteNormalization(), withPasteNormalization)
+		})
+
+		t.Run("repeat compression", func(t *testing.T) {
+			exercise(t, WithRepeatCompression(), withRepeatCompression)
+		})
+
+		t.Run("alt screen if supported", func(t *testing.T) {
+			exercise(t, WithAltScreenIfSupported(), withAltScreenIfSupported)
+		})
+
+		t.Run("in-band resize", func(t *testing.T) {
+			exercise(t, WithInBandResize(), withInBandResize)
+		})
+
+		t.Run("mouse highlight", func(t *testing.T) {
+			exercise(t, WithMouseHighlight(), withMouseHighlight)
+		})
+
+		t.Run("mouse pixels", func(t *testing.T) {
+			exercise(t, WithMousePixels(), withMousePixels)
+		})
+
+		t.Run("raw input", func(t *testing.T) {
+			exercise(t, WithRawInput(), withRawInput)
+		})
+
+		t.Run("raw input only", func(t *testing.T) {
+			exercise(t, WithRawInputOnly(), withRawInputOnly)
+		})
+
+		t.Run("start paused", func(t *testing.T) {
+			exercise(t, WithStartPaused(), withStartPaused)
+		})
+
+		t.Run("shown cursor", func(t *testing.T) {
+			exercise(t, WithShownCursor(), withShownCursor)
+		})
+
+		t.Run("without ansi", func(t *testing.T) {
+			exercise(t, WithoutANSI(), withoutANSI)
+		})
+
+		t.Run("report scroll", func(t *testing.T) {
+			exercise(t, WithReportScroll(), withReportScroll)
+		})
+
 		t.Run("mouse cell motion", func(t *testing.T) {
 			p := NewProgram(nil, WithMouseAllMotion(), WithMouseCellMotion())
 			if !p.startupOptions.has(withMouseCellMotion) {
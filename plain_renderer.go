@@ -0,0 +1,165 @@
+package tea
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// plainRenderer writes frames as plain text, with any control sequences
+// stripped, one after another. It's used for dumb terminals and outputs that
+// don't understand escape sequences, such as log files. As it can't move the
+// cursor it doesn't redraw frames in place, and all commands controlling the
+// terminal are no-ops.
+type plainRenderer struct {
+	nilRenderer
+
+	out       io.Writer
+	mtx       sync.Mutex
+	framerate time.Duration
+	done      chan struct{}
+	once      sync.Once
+
+	frame      string
+	lastRender string
+
+	// called once after the next flush
+	afterFlushFuncs []func()
+}
+
+// newPlainRenderer creates a new renderer writing plain text to the given
+// writer.
+func newPlainRenderer(out io.Writer) renderer {
+	return &plainRenderer{
+		out:       out,
+		framerate: defaultFramerate,
+	}
+}
+
+func (r *plainRenderer) start() {
+	r.done = make(chan struct{})
+	r.once = sync.Once{}
+	go r.listen(time.NewTicker(r.framerate), r.done)
+}
+
+// stop permanently halts the renderer, writing the final frame.
+func (r *plainRenderer) stop() {
+	r.flush()
+	r.kill()
+}
+
+// kill halts the renderer without writing the final frame.
+func (r *plainRenderer) kill() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
+
+func (r *plainRenderer) listen(ticker *time.Ticker, done chan struct{}) {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.flush()
+		case <-done:
+			return
+		}
+	}
+}
+
+// afterFlush calls fn once the next flush is done.
+func (r *plainRenderer) afterFlush(fn func()) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.afterFlushFuncs = append(r.afterFlushFuncs, fn)
+}
+
+// write sets the frame to be written on the next flush.
+func (r *plainRenderer) write(s string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.frame = stripANSI(s)
+}
+
+// writeBytes sets the frame to be written on the next flush.
+func (r *plainRenderer) writeBytes(b []byte) {
+	r.write(string(b))
+}
+
+// flush writes the frame, unless it's empty or unchanged since the last one.
+func (r *plainRenderer) flush() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	defer func() {
+		fns := r.afterFlushFuncs
+		r.afterFlushFuncs = nil
+		for _, fn := range fns {
+			fn()
+		}
+	}()
+
+	if r.frame == "" || r.frame == r.lastRender {
+		return
+	}
+
+	s := r.frame
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	_, _ = io.WriteString(r.out, s)
+	r.lastRender = r.frame
+}
+
+// stripANSI removes escape sequences from s: control sequences (CSI),
+// operating system commands (OSC) and other escapes, along with carriage
+// returns. It's deliberately simple, as it only needs to handle what views
+// are made of, which is mostly styled text.
+func stripANSI(s string) string {
+	if !strings.ContainsAny(s, "\x1b\r") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\r':
+			continue
+		case '\x1b':
+		default:
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		if i >= len(s) {
+			break
+		}
+		switch s[i] {
+		case '[':
+			// CSI: parameter and intermediate bytes up to a final byte.
+			for i++; i < len(s) && (s[i] < 0x40 || s[i] > 0x7e); i++ {
+			}
+		case ']':
+			// OSC: up to BEL or ST (ESC \).
+			for i++; i < len(s); i++ {
+				if s[i] == '\a' {
+					break
+				}
+				if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+					i++
+					break
+				}
+			}
+		}
+		// Any other escape is a single character after ESC, which the loop
+		// skips.
+	}
+	return b.String()
+}
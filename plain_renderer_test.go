@@ -0,0 +1,67 @@
+package tea
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	tt := []struct {
+		name     string
+		in       string
+		expected string
+	}{
+		{"plain", "hello\nworld", "hello\nworld"},
+		{"sgr", "\x1b[1;38;5;205mhello\x1b[0m", "hello"},
+		{"cursor", "\x1b[2K\x1b[1Ahello\r\n", "hello\n"},
+		{"private mode", "\x1b[?25lhello", "hello"},
+		{"osc bel", "\x1b]8;;https://example.com\ahello\x1b]8;;\a", "hello"},
+		{"osc st", "\x1b]0;title\x1b\\hello", "hello"},
+		{"short escape", "\x1b7hello\x1b8", "hello"},
+		{"trailing escape", "hello\x1b", "hello"},
+		{"unicode", "\x1b[31mhéllo ✓\x1b[0m", "héllo ✓"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if s := stripANSI(tc.in); s != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, s)
+			}
+		})
+	}
+}
+
+func TestWithoutANSI(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithoutANSI(),
+		WithAltScreen(), WithMouseAllMotion())
+
+	go p.Send(sequenceMsg{EnterAltScreen, HideCursor, SetReverseVideo(true), Quit})
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if out := buf.String(); out != "success\n" {
+		t.Fatalf("expected plain output, got %q", out)
+	}
+}
+
+func TestPlainRendererFrames(t *testing.T) {
+	var buf bytes.Buffer
+	r := newPlainRenderer(&buf)
+
+	r.write("\x1b[1mone\x1b[0m")
+	r.flush()
+	r.write("one")
+	r.flush()
+	r.write("two\n")
+	r.flush()
+
+	if out := buf.String(); out != "one\ntwo\n" {
+		t.Fatalf("expected each changed frame on its own line, got %q", out)
+	}
+}
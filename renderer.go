@@ -1,5 +1,10 @@
 package tea
 
+import (
+	"io"
+	"time"
+)
+
 // renderer is the interface for Bubble Tea renderers.
 type renderer interface {
 	// Start the renderer.
@@ -15,14 +20,49 @@ type renderer interface {
 	// output at its discretion.
 	write(string)
 
+	// Like write, but for a frame in a byte slice, which the renderer copies
+	// rather than keeping.
+	writeBytes([]byte)
+
+	// Render the frame in the buffer right away, rather than waiting for the
+	// next tick.
+	flush()
+
+	// Call a function that changes modes, writing the sequences of all of
+	// its changes together, with no frame in between.
+	batch(func())
+
+	// Call a function once the next flush is done, whether or not it had a
+	// new frame to write. The function is called with the renderer's lock
+	// held, so it mustn't block.
+	afterFlush(func())
+
 	// Request a full re-render. Note that this will not trigger a render
 	// immediately. Rather, this method causes the next render to be a full
 	// repaint. Because of this, it's safe to call this method multiple times
-	// in succession.
+	// in succession: the calls are coalesced into a single full repaint.
 	repaint()
 
 	// Clears the terminal.
 	clearScreen()
+	// Clears the terminal from the cursor to the end of the screen.
+	clearToEndOfScreen()
+	// Clears the line from the cursor to the end of the line.
+	clearToEndOfLine()
+	// Clears the line the cursor is on.
+	clearLine()
+
+	// Scrolls the contents of the scrolling region up by a number of lines.
+	scrollUp(int)
+	// Scrolls the contents of the scrolling region down by a number of lines.
+	scrollDown(int)
+
+	// Soft reset the terminal and re-apply the modes in effect.
+	resetTerminal()
+
+	// Write a control sequence directly to the output, bypassing the frame
+	// buffer. This is used for things like terminal queries.
+	execute(string)
 
 	// Whether or not the alternate screen buffer is enabled.
 	altScreen() bool
@@ -31,10 +71,41 @@ type renderer interface {
 	// Disable the alternate screen buffer.
 	exitAltScreen()
 
+	// Whether or not reverse video (DECSCNM) is enabled.
+	reverseVideo() bool
+	// Enable or disable reverse video.
+	setReverseVideo(bool)
+
+	// Whether or not alternate scroll mode (1007) is enabled.
+	alternateScroll() bool
+	// Enable or disable alternate scroll mode.
+	setAlternateScroll(bool)
+
+	// Whether or not insert mode (IRM) is enabled.
+	insertMode() bool
+	// Enable or disable insert mode.
+	setInsertMode(bool)
+
+	// The style of the cursor, as last set.
+	cursorStyle() cursorStyle
+	// Set the style of the cursor.
+	setCursorStyle(cursorStyle)
+
 	// Show the cursor.
 	showCursor()
 	// Hide the cursor.
 	hideCursor()
+	// Move the cursor to a cell and keep it there after every frame, or stop
+	// doing so if a coordinate is negative.
+	setCursorPosition(x, y int)
+	// Show the cursor at a cell, or hide it, writing only what changed since
+	// the cursor was last set.
+	syncCursor(visible bool, x, y int)
+
+	// Whether or not mouse cell motion tracking is enabled.
+	mouseCellMotionEnabled() bool
+	// Whether or not mouse all motion tracking is enabled.
+	mouseAllMotionEnabled() bool
 
 	// enableMouseCellMotion enables mouse click, release, wheel and motion
 	// events if a mouse button is pressed (i.e., drag events).
@@ -52,5 +123,120 @@ type renderer interface {
 	disableMouseAllMotion()
 }
 
-// repaintMsg forces a full repaint.
+// RenderStats describes a frame the renderer wrote to the terminal. It's
+// passed to the function set with WithRenderMetrics.
+type RenderStats struct {
+	// Duration is how long it took to compute the changes to the last frame
+	// and write them to the terminal. It doesn't include the time spent in
+	// View.
+	Duration time.Duration
+
+	// Bytes is the number of bytes written to the terminal.
+	Bytes int
+
+	// FullRepaint reports whether the whole frame was redrawn, as opposed to
+	// only the lines that changed since the last frame.
+	FullRepaint bool
+
+	// CoalescedRepaints is the number of redundant requests for a full
+	// repaint this frame answered. Requests made between two frames are
+	// coalesced into a single full repaint; the first one isn't counted.
+	CoalescedRepaints int
+}
+
+// Repaint is a special command that makes the next render a full repaint,
+// redrawing every line rather than only those that changed. Use it when
+// something other than the renderer has written to the terminal, leaving the
+// screen out of sync with what the renderer believes is on it.
+//
+// Like regular renders, the repaint happens on the next tick. Use ForceRender
+// to repaint right away.
+func Repaint() Msg {
+	return repaintMsg{}
+}
+
+// repaintMsg forces a full repaint. You can send a repaintMsg with Repaint.
 type repaintMsg struct{}
+
+// Flush is a special command that renders the current frame right away,
+// bypassing the framerate limit. It's mostly useful with the WithManualFlush
+// ProgramOption, where it's the only way to get frames onto the screen, but it
+// can also be used to push out an important frame without waiting for the
+// next tick.
+//
+// The frame rendered is the view as of the Update call handling this
+// command's message.
+func Flush() Msg {
+	return flushMsg{}
+}
+
+// flushMsg is an internal message that signals to render the current frame
+// right away. You can send a flushMsg with Flush.
+type flushMsg struct{}
+
+// ForceRender returns a command that redraws the current view in full, right
+// away, without waiting for the next tick and without changing the framerate.
+// It works the same with WithManualFlush and without it. Where Flush only
+// writes the lines that changed since the last frame and Repaint only makes
+// the next render a full one, ForceRender does both in one go: every line is
+// redrawn, immediately.
+//
+// As for ordering: the view rendered is the one returned by View right after
+// Update handled the command's message, so it reflects every message handled
+// before it. The renderer only keeps the latest frame, so any earlier frame
+// that hadn't been rendered yet is superseded by it, while lines queued with
+// Println are printed along with it.
+func ForceRender() Cmd {
+	return func() Msg {
+		return forceRenderMsg{}
+	}
+}
+
+// forceRenderMsg is an internal message that signals to redraw the current
+// frame in full right away. You can send a forceRenderMsg with ForceRender.
+type forceRenderMsg struct{}
+
+// tapWriter passes everything written to it on to w, and then to tap along
+// with the time it was written. See WithOutputTap.
+type tapWriter struct {
+	w   io.Writer
+	tap func([]byte, time.Time)
+}
+
+func (t *tapWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if n > 0 {
+		t.tap(p[:n], time.Now())
+	}
+	return n, err
+}
+
+// AfterRender returns a command that calls fn once the view has been rendered
+// to the terminal, and sends the message it returns to Update. Use it to do
+// something once a frame is actually on screen, such as starting the next
+// stage of an animation, measuring how long a transition took, or logging
+// that a view was shown.
+//
+// The view in question is the one returned by View after the Update that
+// returned the command; fn is called once the renderer has flushed it, on
+// the next tick, or the next Flush with WithManualFlush. If the view didn't
+// change, there's nothing new to write, and fn is called after that flush
+// all the same, since what's on screen is up to date. Without a renderer,
+// such as with WithoutRenderer, fn is called right away. If the program
+// exits first, fn is called when the final frame is rendered, but its
+// message is dropped.
+func AfterRender(fn func() Msg) Cmd {
+	if fn == nil {
+		return nil
+	}
+	return func() Msg {
+		return afterRenderMsg{fn: fn}
+	}
+}
+
+// afterRenderMsg is an internal message that schedules a function to be
+// called once the current view has been rendered. You can send an
+// afterRenderMsg with AfterRender.
+type afterRenderMsg struct {
+	fn func() Msg
+}
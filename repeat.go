@@ -0,0 +1,103 @@
+package tea
+
+import (
+	"strconv"
+	"strings"
+)
+
+// repeatThreshold is the length from which runs of the same character are
+// written with REP. Below it, the sequence saves too little to be worth it.
+const repeatThreshold = 8
+
+// repeatTerminals are the terminals known to support REP, by the name they
+// report to XTVERSION, lowercased. Each entry is preceded by an example of
+// the terminal's reply. Only terminals known to implement REP are listed:
+// one that doesn't would drop the repeated characters.
+var repeatTerminals = map[string]bool{
+	// XTerm(388), the reference implementation of both REP and XTVERSION
+	"xterm": true,
+	// kitty(0.26.5)
+	"kitty": true,
+	// WezTerm 20230712-072601-f4abf8fd
+	"wezterm": true,
+	// foot(1.16.2)
+	"foot": true,
+	// contour 0.3.12
+	"contour": true,
+}
+
+// supportsRepeat reports whether the terminal with the given XTVERSION name
+// supports REP.
+func supportsRepeat(name string) bool {
+	return repeatTerminals[strings.ToLower(name)]
+}
+
+// repeatable reports whether runs of the given rune can be written with REP.
+// Only printable ASCII, box drawing characters and block elements qualify:
+// they're what uniform regions of a frame are made of, and they never combine
+// with the characters around them.
+func repeatable(r rune) bool {
+	return (r >= ' ' && r <= '~') || (r >= '─' && r <= '▟')
+}
+
+// compressRuns replaces the runs of at least repeatThreshold of the same
+// character in a line with the character followed by REP (CSI n b), which
+// repeats it n more times. Escape sequences are left alone, and break runs.
+func compressRuns(line string) string {
+	var b strings.Builder
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if r == '\x1b' {
+			// Copy the escape sequence as is.
+			n := escapeSequenceLen(runes[i:])
+			b.WriteString(string(runes[i : i+n]))
+			i += n
+			continue
+		}
+
+		n := 1
+		for i+n < len(runes) && runes[i+n] == r {
+			n++
+		}
+		b.WriteRune(r)
+		if n >= repeatThreshold && repeatable(r) {
+			b.WriteString("\x1b[" + strconv.Itoa(n-1) + "b")
+		} else {
+			for j := 1; j < n; j++ {
+				b.WriteRune(r)
+			}
+		}
+		i += n
+	}
+	return b.String()
+}
+
+// escapeSequenceLen returns the length of the escape sequence at the start of
+// runes: a CSI sequence up to its final byte, an OSC sequence up to its
+// terminator, or else ESC and the character after it.
+func escapeSequenceLen(runes []rune) int {
+	if len(runes) < 2 {
+		return len(runes)
+	}
+	switch runes[1] {
+	case '[':
+		for i := 2; i < len(runes); i++ {
+			if runes[i] >= 0x40 && runes[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']':
+		for i := 2; i < len(runes); i++ {
+			if runes[i] == '\a' {
+				return i + 1
+			}
+			if runes[i] == '\x1b' && i+1 < len(runes) && runes[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	default:
+		return 2
+	}
+	return len(runes)
+}
@@ -0,0 +1,235 @@
+package tea
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func TestCompressRuns(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"short runs", "aaaaaaa bb", "aaaaaaa bb"},
+		{"spaces", "a" + strings.Repeat(" ", 20) + "b", "a \x1b[19bb"},
+		{"threshold", strings.Repeat("=", 8), "=\x1b[7b"},
+		{"box drawing", "┌" + strings.Repeat("─", 10) + "┐", "┌─\x1b[9b┐"},
+		{"not repeatable", strings.Repeat("日", 10), strings.Repeat("日", 10)},
+		{
+			"styles break runs",
+			"\x1b[31m" + strings.Repeat("x", 5) + "\x1b[0m" + strings.Repeat("x", 10),
+			"\x1b[31mxxxxx\x1b[0mx\x1b[9b",
+		},
+		{
+			"escape sequences left alone",
+			"\x1b[38;5;111m" + strings.Repeat("-", 9) + "\x1b]8;;http://aaaaaaaaaaaa\x1b\\",
+			"\x1b[38;5;111m-\x1b[8b\x1b]8;;http://aaaaaaaaaaaa\x1b\\",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := compressRuns(tc.in); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSupportsRepeat(t *testing.T) {
+	tests := []struct {
+		reply  string
+		repeat bool
+	}{
+		{"\x1bP>|XTerm(388)\x1b\\", true},
+		{"\x1bP>|kitty(0.26.5)\x1b\\", true},
+		{"\x1bP>|WezTerm 20230712-072601-f4abf8fd\x1b\\", true},
+		{"\x1bP>|foot(1.16.2)\x1b\\", true},
+		{"\x1bP>|contour 0.3.12\x1b\\", true},
+		{"\x1bP>|tmux 3.3a\x1b\\", false},
+		{"\x1bP>|iTerm2 3.4.19\x1b\\", false},
+		{"\x1bP>|xterm-kitty\x1b\\", false},
+	}
+
+	covered := make(map[string]bool)
+	for _, tc := range tests {
+		msg, ok := parseXTVersion(tc.reply)
+		if !ok {
+			t.Fatalf("couldn't parse %q", tc.reply)
+		}
+		if got := supportsRepeat(msg.Name); got != tc.repeat {
+			t.Errorf("expected REP support for %q to be %v, got %v", msg.Name, tc.repeat, got)
+		}
+		covered[strings.ToLower(msg.Name)] = true
+	}
+	for name := range repeatTerminals {
+		if !covered[name] {
+			t.Errorf("no test for %q", name)
+		}
+	}
+}
+
+// repeatDoneMsg tells a repeatModel the terminal has had time to reply.
+type repeatDoneMsg struct{}
+
+// repeatModel shows a box border, which changes once the terminal has
+// replied, so that it's rendered again.
+type repeatModel struct {
+	name string
+	msgs []Msg
+}
+
+func (m *repeatModel) Init() Cmd {
+	return nil
+}
+
+func (m *repeatModel) Update(msg Msg) (Model, Cmd) {
+	m.msgs = append(m.msgs, msg)
+	if _, ok := msg.(repeatDoneMsg); ok {
+		m.name = "done"
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m *repeatModel) View() string {
+	return m.name + strings.Repeat("─", 20) + "\n"
+}
+
+func TestRepeatCompression(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		repeat bool
+	}{
+		{"XTerm", true},
+		{"tmux", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var in bytes.Buffer
+
+			m := &repeatModel{}
+			p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithRepeatCompression())
+			go func() {
+				p.Send(TerminalVersionMsg{Name: tc.name})
+				p.Send(repeatDoneMsg{})
+			}()
+			if _, err := p.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			out := buf.String()
+			if !strings.Contains(out, requestTerminalVersionSeq) {
+				t.Errorf("expected the terminal version to be queried, got %q", out)
+			}
+			if got := strings.Contains(out, "done─\x1b[19b"); got != tc.repeat {
+				t.Errorf("expected REP to be used: %v, got %q", tc.repeat, out)
+			}
+			for _, msg := range m.msgs {
+				if _, ok := msg.(TerminalVersionMsg); ok {
+					t.Errorf("the reply to the program's own query should not reach Update")
+				}
+			}
+		})
+	}
+}
+
+func TestRepeatCompressionQuery(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &repeatModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithRepeatCompression())
+
+	// The program's own query is sent first, so the first reply is for it
+	// and the second for the program's. Neither timeout is passed on: the
+	// first query is internal, the second was answered.
+	go p.Send(sequenceMsg{
+		RequestTerminalVersion,
+		func() Msg { return TerminalVersionMsg{Name: "xterm"} },
+		func() Msg { return TerminalVersionMsg{Name: "xterm", Version: "388"} },
+		func() Msg { return terminalVersionTimeoutMsg{id: 1, internal: true} },
+		func() Msg { return terminalVersionTimeoutMsg{id: 2} },
+		func() Msg { return repeatDoneMsg{} },
+	})
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var replies []Msg
+	for _, msg := range m.msgs {
+		if _, ok := msg.(TerminalVersionMsg); ok {
+			replies = append(replies, msg)
+		}
+	}
+	expected := []Msg{TerminalVersionMsg{Name: "xterm", Version: "388"}}
+	if !reflect.DeepEqual(replies, expected) {
+		t.Errorf("expected %#v, got %#v", expected, replies)
+	}
+}
+
+func TestRepeatCompressionQueryTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &repeatModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithRepeatCompression())
+
+	// The terminal never replies, which isn't reported to Update, and a late
+	// reply is dropped.
+	go p.Send(sequenceMsg{
+		func() Msg { return terminalVersionTimeoutMsg{id: 1, internal: true} },
+		func() Msg { return TerminalVersionMsg{Name: "xterm"} },
+		func() Msg { return repeatDoneMsg{} },
+	})
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range m.msgs {
+		if _, ok := msg.(TerminalVersionMsg); ok {
+			t.Errorf("expected no terminal version, got %#v", msg)
+		}
+	}
+	if strings.Contains(buf.String(), "done─\x1b[19b") {
+		t.Errorf("expected REP not to be used after a late reply, got %q", buf.String())
+	}
+}
+
+func BenchmarkRepeatCompression(b *testing.B) {
+	// A full window with a border, mostly blank.
+	var view strings.Builder
+	view.WriteString("┌" + strings.Repeat("─", 118) + "┐\n")
+	for i := 0; i < 38; i++ {
+		view.WriteString("│" + strings.Repeat(" ", 118) + "│\n")
+	}
+	view.WriteString("└" + strings.Repeat("─", 118) + "┘")
+
+	for _, repeat := range []bool{false, true} {
+		name := "plain"
+		if repeat {
+			name = "rep"
+		}
+		b.Run(name, func(b *testing.B) {
+			var buf bytes.Buffer
+			r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+			r.handleMessages(WindowSizeMsg{Width: 120, Height: 40})
+			r.setRepeat(repeat)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				r.repaint()
+				r.write(view.String())
+				r.flush()
+			}
+			b.ReportMetric(float64(buf.Len()), "bytes/frame")
+		})
+	}
+}
@@ -0,0 +1,461 @@
+package tea
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CellSizeMsg reports the size of a single terminal cell in pixels. It's sent
+// to Update in response to a RequestCellSize command if the terminal supports
+// reporting it.
+type CellSizeMsg struct {
+	Width  int
+	Height int
+}
+
+// WindowPixelSizeMsg reports the size of the terminal's text area in pixels.
+// It's sent to Update in response to a RequestWindowPixelSize command if the
+// terminal supports reporting it.
+type WindowPixelSizeMsg struct {
+	Width  int
+	Height int
+}
+
+// RequestCellSize is a special command that asks the terminal to report the
+// size of a single cell in pixels (XTWINOPS 16). If the terminal supports it,
+// the reply is delivered to Update as a CellSizeMsg.
+//
+// Not all terminals support this query. Those that don't will simply not
+// reply, so don't block on receiving a CellSizeMsg.
+func RequestCellSize() Msg {
+	return requestCellSizeMsg{}
+}
+
+// requestCellSizeMsg is an internal message that signals to query the
+// terminal for its cell size. You can send a requestCellSizeMsg with
+// RequestCellSize.
+type requestCellSizeMsg struct{}
+
+// RequestWindowPixelSize is a special command that asks the terminal to
+// report the size of its text area in pixels (XTWINOPS 14). If the terminal
+// supports it, the reply is delivered to Update as a WindowPixelSizeMsg.
+//
+// Not all terminals support this query. Those that don't will simply not
+// reply, so don't block on receiving a WindowPixelSizeMsg.
+func RequestWindowPixelSize() Msg {
+	return requestWindowPixelSizeMsg{}
+}
+
+// requestWindowPixelSizeMsg is an internal message that signals to query the
+// terminal for its size in pixels. You can send a requestWindowPixelSizeMsg
+// with RequestWindowPixelSize.
+type requestWindowPixelSizeMsg struct{}
+
+// CursorPositionMsg reports the position of the cursor. It's sent to Update in
+// response to a RequestCursorPosition command. Row and Column are zero-based,
+// like the coordinates of a MouseEvent.
+type CursorPositionMsg struct {
+	Row    int
+	Column int
+}
+
+// RequestCursorPosition is a special command that asks the terminal to report
+// the position of the cursor (DSR 6). The reply is delivered to Update as a
+// CursorPositionMsg.
+//
+// The cursor is wherever the renderer left it after drawing the last frame,
+// which is usually the start of the last line of the view. Use this to find
+// out which column output ended up in, for example when aligning on tab
+// stops.
+//
+// Note that some terminals report a few function keys with modifiers, such as
+// shift+F3, using the same sequence as a cursor position in the first row.
+// Such replies are reported as key presses.
+func RequestCursorPosition() Msg {
+	return requestCursorPositionMsg{}
+}
+
+// requestCursorPositionMsg is an internal message that signals to query the
+// terminal for the cursor position. You can send a requestCursorPositionMsg
+// with RequestCursorPosition.
+type requestCursorPositionMsg struct{}
+
+// DeviceAttributesMsg reports the terminal's primary device attributes (DA1).
+// It's sent to Update in response to a RequestDeviceAttributes command.
+//
+// The first parameter usually identifies the terminal's conformance level and
+// the remaining ones list supported features. For example, 4 indicates sixel
+// graphics support.
+//
+// If the terminal doesn't reply within a short timeout a DeviceAttributesMsg
+// with no Params is sent instead, so programs waiting on the reply can carry
+// on. Should the reply still arrive after that, it's dropped.
+type DeviceAttributesMsg struct {
+	Params []int
+}
+
+// RequestDeviceAttributes is a special command that asks the terminal to
+// report its primary device attributes (DA1). The reply is delivered to
+// Update as a DeviceAttributesMsg.
+//
+// Practically all terminals reply to this query, which makes it useful for
+// detecting capabilities.
+func RequestDeviceAttributes() Msg {
+	return requestDeviceAttributesMsg{}
+}
+
+// requestDeviceAttributesMsg is an internal message that signals to query the
+// terminal for its primary device attributes. You can send a
+// requestDeviceAttributesMsg with RequestDeviceAttributes.
+type requestDeviceAttributesMsg struct{}
+
+// ForegroundColorMsg reports the terminal's default foreground color, the
+// color of text that isn't styled. It's sent to Update in response to a
+// RequestForegroundColor command if the terminal supports reporting it.
+type ForegroundColorMsg struct {
+	R, G, B uint8
+}
+
+// RequestForegroundColor is a special command that asks the terminal to
+// report its default foreground color (OSC 10). If the terminal supports it,
+// the reply is delivered to Update as a ForegroundColorMsg. Knowing it helps
+// adapt a theme to the terminal's, for instance to avoid drawing text in a
+// color that barely stands out from the default one.
+//
+// Not all terminals support this query. Those that don't will simply not
+// reply, so don't block on receiving a ForegroundColorMsg.
+func RequestForegroundColor() Msg {
+	return requestForegroundColorMsg{}
+}
+
+// requestForegroundColorMsg is an internal message that signals to query the
+// terminal for its foreground color. You can send a requestForegroundColorMsg
+// with RequestForegroundColor.
+type requestForegroundColorMsg struct{}
+
+// TerminalVersionMsg reports the name and version of the terminal. It's sent
+// to Update in response to a RequestTerminalVersion command.
+//
+// The terminal reports them as a single string, such as "xterm(388)" or
+// "iTerm2 3.4.19", which is split into Name and Version. Terminals that
+// report something else have it all in Name.
+//
+// If the terminal doesn't reply within a short timeout, which is what
+// terminals that don't support the query do, a TerminalVersionMsg with an
+// empty Name is sent instead. Should the reply still arrive after that, it's
+// dropped.
+type TerminalVersionMsg struct {
+	Name    string
+	Version string
+}
+
+// RequestTerminalVersion is a special command that asks the terminal for its
+// name and version (XTVERSION). The reply is delivered to Update as a
+// TerminalVersionMsg. Use it to work around the bugs of particular terminals,
+// or to enable features known to work in them.
+//
+// Note that terminal multiplexers such as tmux reply with their own name and
+// version, rather than those of the terminal they're running in.
+func RequestTerminalVersion() Msg {
+	return requestTerminalVersionMsg{}
+}
+
+// requestTerminalVersionMsg is an internal message that signals to query the
+// terminal for its name and version. You can send a requestTerminalVersionMsg
+// with RequestTerminalVersion.
+type requestTerminalVersionMsg struct{}
+
+// deviceAttributesTimeoutMsg is an internal message sent when a device
+// attributes query may have gone unanswered.
+type deviceAttributesTimeoutMsg struct {
+	id int
+}
+
+// terminalVersionTimeoutMsg is an internal message sent when a terminal
+// version query may have gone unanswered.
+type terminalVersionTimeoutMsg struct {
+	id       int
+	internal bool
+}
+
+// deviceAttributesTimeout is how long we wait for the terminal to reply to a
+// device attributes or terminal version query.
+const deviceAttributesTimeout = time.Second
+
+// queryTracker keeps track of the queries of one kind sent to the terminal,
+// so that the ones it doesn't reply to in time can be timed out. Queries are
+// numbered in the order they're sent, and the terminal replies in that order.
+type queryTracker struct {
+	// ids of the last query sent and answered, and the number of timed out
+	// queries whose replies are yet to be dropped
+	requested int
+	answered  int
+	late      int
+
+	// ids of the queries the program sent on its own behalf rather than for
+	// Update, whose replies aren't passed on
+	internal map[int]bool
+}
+
+// request records a query being sent and returns its id.
+func (q *queryTracker) request() int {
+	q.requested++
+	return q.requested
+}
+
+// requestInternal records a query the program sends on its own behalf and
+// returns its id.
+func (q *queryTracker) requestInternal() int {
+	id := q.request()
+	if q.internal == nil {
+		q.internal = make(map[int]bool)
+	}
+	q.internal[id] = true
+	return id
+}
+
+// nextIsInternal reports whether the next reply answers a query the program
+// sent on its own behalf. Replies arrive in order, so that's the oldest
+// outstanding query, unless a reply to a timed out query is still due.
+func (q *queryTracker) nextIsInternal() bool {
+	return q.late == 0 && q.internal[q.answered+1]
+}
+
+// reply records a reply and reports whether to pass it on. Replies to
+// queries that already timed out are dropped.
+func (q *queryTracker) reply() bool {
+	if q.late > 0 {
+		q.late--
+		return false
+	}
+	// Replies arrive in order, so this answers any outstanding queries.
+	q.answered = q.requested
+	q.forgetAnswered()
+	return true
+}
+
+// timeout records the timeout of the query with the given id and reports
+// whether it was still unanswered. If so, it and the queries before it are
+// marked answered, so the replies are dropped should they still arrive, and
+// the program should be told so it doesn't wait forever.
+func (q *queryTracker) timeout(id int) bool {
+	if id <= q.answered {
+		return false
+	}
+	q.late += id - q.answered
+	q.answered = id
+	q.forgetAnswered()
+	return true
+}
+
+// forgetAnswered forgets which of the answered queries were internal.
+func (q *queryTracker) forgetAnswered() {
+	for id := range q.internal {
+		if id <= q.answered {
+			delete(q.internal, id)
+		}
+	}
+}
+
+// Control sequences used to query the terminal.
+const (
+	requestCursorPositionSeq   = "\x1b[6n"
+	requestCellSizeSeq         = "\x1b[16t"
+	enableInBandResizeSeq      = "\x1b[?2048h"
+	disableInBandResizeSeq     = "\x1b[?2048l"
+	requestWindowPixelSizeSeq  = "\x1b[14t"
+	requestDeviceAttributesSeq = "\x1b[c"
+	requestForegroundColorSeq  = "\x1b]10;?\a"
+	requestTerminalVersionSeq  = "\x1b[>0q"
+)
+
+// requestDeviceAttributes queries the terminal for its device attributes and
+// schedules a timeout in case it doesn't reply.
+func (p *Program) requestDeviceAttributes() {
+	p.renderer.execute(requestDeviceAttributesSeq)
+
+	id := p.deviceAttributes.request()
+	time.AfterFunc(deviceAttributesTimeout, func() {
+		p.Send(deviceAttributesTimeoutMsg{id: id})
+	})
+}
+
+// requestTerminalVersion queries the terminal for its name and version and
+// schedules a timeout in case it doesn't reply. Internal queries are made for
+// the program's own use, and neither their reply nor their timeout reaches
+// Update.
+func (p *Program) requestTerminalVersion(internal bool) {
+	p.renderer.execute(requestTerminalVersionSeq)
+
+	var id int
+	if internal {
+		id = p.terminalVersion.requestInternal()
+	} else {
+		id = p.terminalVersion.request()
+	}
+	time.AfterFunc(deviceAttributesTimeout, func() {
+		p.Send(terminalVersionTimeoutMsg{id: id, internal: internal})
+	})
+}
+
+// parseReport parses a report sent by the terminal in response to one of our
+// queries. It returns false if the given sequence is not a report we know
+// about.
+func parseReport(seq string) (Msg, bool) {
+	if v, ok := parseXTVersion(seq); ok {
+		return v, true
+	}
+
+	if index, r, g, b, ok := parseOSCColor(seq); ok {
+		// OSC color replies carry the index of the color queried. Replies
+		// for other colors aren't ours, but they're still consumed so they
+		// don't turn into keypresses; the unknown sequence handler can pick
+		// them up.
+		if index == 10 {
+			return ForegroundColorMsg{R: r, G: g, B: b}, true
+		}
+		return unknownSequenceMsg(seq), true
+	}
+
+	marker, params, final, ok := parseCSI(seq)
+	if !ok {
+		return nil, false
+	}
+
+	switch {
+	case marker == '?' && final == 'c':
+		// Primary device attributes look like CSI ? Ps ; ... c.
+		return DeviceAttributesMsg{Params: params}, true
+
+	case (marker == 0 || marker == '?') && final == 'R':
+		// Cursor position reports look like CSI row ; column R. Some
+		// terminals prefix them with a question mark and append the page
+		// number (DECXCPR).
+		if len(params) < 2 || params[0] < 1 || params[1] < 1 {
+			return nil, false
+		}
+		return CursorPositionMsg{Row: params[0] - 1, Column: params[1] - 1}, true
+
+	case marker == 0 && final == 't':
+		// XTWINOPS replies look like CSI Ps ; height ; width t. Note that
+		// the final byte sets these apart from cursor position reports,
+		// which end in R.
+		if len(params) >= 3 && params[0] == 48 {
+			// In-band resize notifications (mode 2048) look the same, with
+			// the size in pixels appended, which we don't need.
+			return WindowSizeMsg{Width: params[2], Height: params[1]}, true
+		}
+		if len(params) != 3 {
+			return nil, false
+		}
+		switch params[0] {
+		case 4:
+			return WindowPixelSizeMsg{Width: params[2], Height: params[1]}, true
+		case 6:
+			return CellSizeMsg{Width: params[2], Height: params[1]}, true
+		}
+	}
+
+	return nil, false
+}
+
+// parseCSI splits a CSI sequence with numeric parameters into its private
+// marker (if any), its parameters and its final byte. For example,
+// "\x1b[?1;2c" yields '?', [1 2] and 'c'.
+func parseCSI(seq string) (marker byte, params []int, final byte, ok bool) {
+	const csi = "\x1b["
+	if !strings.HasPrefix(seq, csi) || len(seq) < len(csi)+1 {
+		return 0, nil, 0, false
+	}
+
+	final = seq[len(seq)-1]
+	if final < 0x40 || final > 0x7e {
+		return 0, nil, 0, false
+	}
+
+	body := seq[len(csi) : len(seq)-1]
+	if body != "" && strings.IndexByte("<=>?", body[0]) >= 0 {
+		marker = body[0]
+		body = body[1:]
+	}
+	if body == "" {
+		return marker, nil, final, true
+	}
+	for _, p := range strings.Split(body, ";") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, nil, 0, false
+		}
+		params = append(params, n)
+	}
+
+	return marker, params, final, true
+}
+
+// parseXTVersion parses the reply to an XTVERSION query, a DCS sequence such
+// as "\x1bP>|xterm(388)\x1b\\".
+func parseXTVersion(seq string) (TerminalVersionMsg, bool) {
+	const prefix, st = "\x1bP>|", "\x1b\\"
+	if !strings.HasPrefix(seq, prefix) || !strings.HasSuffix(seq, st) ||
+		len(seq) < len(prefix)+len(st) {
+		return TerminalVersionMsg{}, false
+	}
+	s := seq[len(prefix) : len(seq)-len(st)]
+
+	// Terminals report either "name(version)" or "name version".
+	if i := strings.IndexByte(s, '('); i > 0 && strings.HasSuffix(s, ")") {
+		return TerminalVersionMsg{Name: s[:i], Version: s[i+1 : len(s)-1]}, true
+	}
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		return TerminalVersionMsg{Name: s[:i], Version: strings.TrimSpace(s[i+1:])}, true
+	}
+	return TerminalVersionMsg{Name: s}, true
+}
+
+// parseOSCColor parses the reply to an OSC color query, such as
+// "\x1b]10;rgb:ffff/8080/0000\a", into the index of the color queried and its
+// components, scaled to 8 bits. The reply may be terminated by BEL or ST.
+func parseOSCColor(seq string) (index int, r, g, b uint8, ok bool) {
+	const osc = "\x1b]"
+	if !strings.HasPrefix(seq, osc) {
+		return 0, 0, 0, 0, false
+	}
+	switch {
+	case strings.HasSuffix(seq, "\a"):
+		seq = seq[len(osc) : len(seq)-1]
+	case strings.HasSuffix(seq, "\x1b\\"):
+		seq = seq[len(osc) : len(seq)-2]
+	default:
+		return 0, 0, 0, 0, false
+	}
+
+	i := strings.IndexByte(seq, ';')
+	if i < 0 || !strings.HasPrefix(seq[i+1:], "rgb:") {
+		return 0, 0, 0, 0, false
+	}
+	index, err := strconv.Atoi(seq[:i])
+	if err != nil {
+		return 0, 0, 0, 0, false
+	}
+
+	parts := strings.Split(seq[i+1+len("rgb:"):], "/")
+	if len(parts) != 3 {
+		return 0, 0, 0, 0, false
+	}
+	var c [3]uint8
+	for j, p := range parts {
+		// Components have one to four hex digits, which are scaled rather
+		// than truncated, so that "f" is as bright as "ffff".
+		if len(p) < 1 || len(p) > 4 {
+			return 0, 0, 0, 0, false
+		}
+		v, err := strconv.ParseUint(p, 16, 16)
+		if err != nil {
+			return 0, 0, 0, 0, false
+		}
+		c[j] = uint8(v * 255 / (1<<(4*uint(len(p))) - 1))
+	}
+
+	return index, c[0], c[1], c[2], true
+}
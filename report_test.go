@@ -0,0 +1,363 @@
+package tea
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testReportModel struct {
+	init Cmd
+	msgs []Msg
+	done func(Msg) bool
+	quit bool
+}
+
+func (m *testReportModel) Init() Cmd {
+	return m.init
+}
+
+func (m *testReportModel) Update(msg Msg) (Model, Cmd) {
+	// Messages sent at startup, such as the color profile, can still arrive
+	// after we're done, so we stop recording them.
+	if m.quit {
+		return m, nil
+	}
+	m.msgs = append(m.msgs, msg)
+	if m.done(msg) {
+		m.quit = true
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m *testReportModel) View() string {
+	return "success\n"
+}
+
+func TestParseReport(t *testing.T) {
+	tt := []struct {
+		name     string
+		seq      string
+		expected Msg
+		ok       bool
+	}{
+		{
+			name:     "cell size",
+			seq:      "\x1b[6;20;10t",
+			expected: CellSizeMsg{Width: 10, Height: 20},
+			ok:       true,
+		},
+		{
+			name:     "in-band resize",
+			seq:      "\x1b[48;30;100;600;1000t",
+			expected: WindowSizeMsg{Width: 100, Height: 30},
+			ok:       true,
+		},
+		{
+			name:     "in-band resize without pixels",
+			seq:      "\x1b[48;30;100t",
+			expected: WindowSizeMsg{Width: 100, Height: 30},
+			ok:       true,
+		},
+		{
+			name:     "window pixel size",
+			seq:      "\x1b[4;600;800t",
+			expected: WindowPixelSizeMsg{Width: 800, Height: 600},
+			ok:       true,
+		},
+		{
+			name:     "device attributes",
+			seq:      "\x1b[?62;4;22c",
+			expected: DeviceAttributesMsg{Params: []int{62, 4, 22}},
+			ok:       true,
+		},
+		{
+			name:     "device attributes without params",
+			seq:      "\x1b[?c",
+			expected: DeviceAttributesMsg{},
+			ok:       true,
+		},
+		{
+			name: "device attributes request",
+			seq:  "\x1b[c",
+		},
+		{
+			name:     "cursor position report",
+			seq:      "\x1b[6;20R",
+			expected: CursorPositionMsg{Row: 5, Column: 19},
+			ok:       true,
+		},
+		{
+			name:     "extended cursor position report",
+			seq:      "\x1b[?6;20;1R",
+			expected: CursorPositionMsg{Row: 5, Column: 19},
+			ok:       true,
+		},
+		{
+			name: "cursor position report without column",
+			seq:  "\x1b[6R",
+		},
+		{
+			name: "unknown window op",
+			seq:  "\x1b[8;24;80t",
+		},
+		{
+			name: "missing params",
+			seq:  "\x1b[6;20t",
+		},
+		{
+			name:     "foreground color",
+			seq:      "\x1b]10;rgb:ffff/8080/0000\a",
+			expected: ForegroundColorMsg{R: 255, G: 128, B: 0},
+			ok:       true,
+		},
+		{
+			name:     "foreground color terminated by st",
+			seq:      "\x1b]10;rgb:ff/80/00\x1b\\",
+			expected: ForegroundColorMsg{R: 255, G: 128, B: 0},
+			ok:       true,
+		},
+		{
+			name:     "foreground color with short components",
+			seq:      "\x1b]10;rgb:f/8/0\a",
+			expected: ForegroundColorMsg{R: 255, G: 136, B: 0},
+			ok:       true,
+		},
+		{
+			name:     "background color",
+			seq:      "\x1b]11;rgb:0000/0000/0000\a",
+			expected: unknownSequenceMsg("\x1b]11;rgb:0000/0000/0000\a"),
+			ok:       true,
+		},
+		{
+			name: "color without terminator",
+			seq:  "\x1b]10;rgb:ffff/ffff/ffff",
+		},
+		{
+			name: "malformed color",
+			seq:  "\x1b]10;rgb:ffff/ffff\a",
+		},
+		{
+			name:     "terminal version in parentheses",
+			seq:      "\x1bP>|xterm(388)\x1b\\",
+			expected: TerminalVersionMsg{Name: "xterm", Version: "388"},
+			ok:       true,
+		},
+		{
+			name:     "terminal version after a space",
+			seq:      "\x1bP>|iTerm2 3.4\x1b\\",
+			expected: TerminalVersionMsg{Name: "iTerm2", Version: "3.4"},
+			ok:       true,
+		},
+		{
+			name:     "terminal name only",
+			seq:      "\x1bP>|foot\x1b\\",
+			expected: TerminalVersionMsg{Name: "foot"},
+			ok:       true,
+		},
+		{
+			name: "terminal version without terminator",
+			seq:  "\x1bP>|xterm(388)",
+		},
+		{
+			name: "not a csi sequence",
+			seq:  "abc",
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			msg, ok := parseReport(tc.seq)
+			if ok != tc.ok {
+				t.Fatalf("expected ok to be %v, got %v", tc.ok, ok)
+			}
+			if !reflect.DeepEqual(msg, tc.expected) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, msg)
+			}
+		})
+	}
+}
+
+func TestReadInputsReport(t *testing.T) {
+	msgs, err := readInputs(bytes.NewReader([]byte("\x1b[6;18;9t")), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Msg{CellSizeMsg{Width: 9, Height: 18}}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, msgs)
+	}
+}
+
+func TestReadInputsColorReport(t *testing.T) {
+	for _, in := range []string{
+		"\x1b]10;rgb:ffff/ffff/ffff\a",
+		"\x1b]10;rgb:ffff/ffff/ffff\x1b\\",
+	} {
+		msgs, err := readInputs(bytes.NewReader([]byte(in)), false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []Msg{ForegroundColorMsg{R: 255, G: 255, B: 255}}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v for %q, got %#v", expected, in, msgs)
+		}
+	}
+}
+
+func TestReadInputsOtherColorReport(t *testing.T) {
+	// Replies to queries for colors other than the foreground must not leak
+	// into the program as keypresses.
+	for _, in := range []string{
+		"\x1b]11;rgb:0000/0000/0000\a",
+		"\x1b]11;rgb:0000/0000/0000\x1b\\",
+	} {
+		msgs, err := readInputs(bytes.NewReader([]byte(in)), false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []Msg{unknownSequenceMsg(in)}
+		if !reflect.DeepEqual(msgs, expected) {
+			t.Fatalf("expected %#v for %q, got %#v", expected, in, msgs)
+		}
+	}
+}
+
+func TestReadInputsTerminalVersion(t *testing.T) {
+	msgs, err := readInputs(bytes.NewReader([]byte("\x1bP>|WezTerm 20230712\x1b\\a")), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Msg{
+		TerminalVersionMsg{Name: "WezTerm", Version: "20230712"},
+		KeyMsg{Type: KeyRunes, Runes: []rune{'a'}},
+	}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, msgs)
+	}
+}
+
+func TestTerminalVersionTimeout(t *testing.T) {
+	type doneMsg struct{}
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(doneMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	// Simulate the timeout firing before the terminal replied, then the
+	// reply arriving late, and a second query being answered.
+	go p.Send(sequenceMsg{
+		RequestTerminalVersion,
+		func() Msg { return terminalVersionTimeoutMsg{id: 1} },
+		func() Msg { return TerminalVersionMsg{Name: "late"} },
+		RequestTerminalVersion,
+		func() Msg { return TerminalVersionMsg{Name: "xterm", Version: "388"} },
+		func() Msg { return terminalVersionTimeoutMsg{id: 2} },
+		func() Msg { return doneMsg{} },
+	})
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(buf.String(), requestTerminalVersionSeq); n != 2 {
+		t.Errorf("expected the query to be written twice, got %q", buf.String())
+	}
+
+	var replies []Msg
+	for _, msg := range m.msgs {
+		switch msg.(type) {
+		case TerminalVersionMsg:
+			replies = append(replies, msg)
+		case terminalVersionTimeoutMsg:
+			t.Errorf("internal timeout message should not reach Update")
+		}
+	}
+	expected := []Msg{TerminalVersionMsg{}, TerminalVersionMsg{Name: "xterm", Version: "388"}}
+	if !reflect.DeepEqual(replies, expected) {
+		t.Errorf("expected %#v, got %#v", expected, replies)
+	}
+}
+
+func TestDeviceAttributesTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(DeviceAttributesMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	// Simulate the timeout firing before the terminal replied.
+	go p.Send(sequenceMsg{RequestDeviceAttributes, func() Msg {
+		return deviceAttributesTimeoutMsg{id: 1}
+	}})
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte(requestDeviceAttributesSeq)) {
+		t.Errorf("expected the query to be written, got %q", buf.String())
+	}
+
+	last := m.msgs[len(m.msgs)-1]
+	if !reflect.DeepEqual(last, DeviceAttributesMsg{}) {
+		t.Errorf("expected an empty DeviceAttributesMsg, got %#v", last)
+	}
+	for _, msg := range m.msgs {
+		if _, ok := msg.(deviceAttributesTimeoutMsg); ok {
+			t.Errorf("internal timeout message should not reach Update")
+		}
+	}
+}
+
+func TestDeviceAttributesLateReply(t *testing.T) {
+	type doneMsg struct{}
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(doneMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	// Simulate the terminal replying only after the timeout fired.
+	go p.Send(sequenceMsg{
+		RequestDeviceAttributes,
+		func() Msg { return deviceAttributesTimeoutMsg{id: 1} },
+		func() Msg { return DeviceAttributesMsg{Params: []int{62}} },
+		func() Msg { return doneMsg{} },
+	})
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var replies []Msg
+	for _, msg := range m.msgs {
+		if _, ok := msg.(DeviceAttributesMsg); ok {
+			replies = append(replies, msg)
+		}
+	}
+	expected := []Msg{DeviceAttributesMsg{}}
+	if !reflect.DeepEqual(replies, expected) {
+		t.Errorf("expected %#v, got %#v", expected, replies)
+	}
+}
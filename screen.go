@@ -1,9 +1,30 @@
 package tea
 
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/muesli/termenv"
+)
+
 // WindowSizeMsg is used to report the terminal size. It's sent to Update once
 // initially and then on every terminal resize. Note that Windows does not
 // have support for reporting when resizes occur as it does not support the
-// SIGWINCH signal.
+// SIGWINCH signal, unless the terminal reports them itself; see
+// WithInBandResize.
+//
+// Width and Height are always greater than zero. Some terminals briefly
+// report a size of zero while starting up or resizing; no WindowSizeMsg is
+// sent until they report a valid size. Likewise, a WindowSizeMsg without an
+// area sent with Program.Send is dropped.
+//
+// A WindowSizeMsg sent with Program.Send is treated exactly like a real
+// resize: the renderer adopts the new dimensions and repaints. This makes it
+// possible to drive layout deterministically in tests using WithInput and
+// WithOutput.
 type WindowSizeMsg struct {
 	Width  int
 	Height int
@@ -23,12 +44,168 @@ func ClearScreen() Msg {
 // You can send a clearScreenMsg with ClearScreen.
 type clearScreenMsg struct{}
 
+// ClearToEndOfScreen is a special command that clears the terminal from the
+// cursor to the end of the screen. Like ClearScreen, it's an advanced tool for
+// programs managing parts of the layout themselves, typically when not using
+// the alt screen. It's never necessary for regular redraws.
+//
+// The next frame will be fully repainted, so the program's own output is
+// restored after clearing.
+func ClearToEndOfScreen() Msg {
+	return clearToEndOfScreenMsg{}
+}
+
+// clearToEndOfScreenMsg is an internal message that signals to clear the
+// screen from the cursor onwards. You can send a clearToEndOfScreenMsg with
+// ClearToEndOfScreen.
+type clearToEndOfScreenMsg struct{}
+
+// ClearToEndOfLine is a special command that clears the line the cursor is on
+// from the cursor to the end of the line. Like ClearScreen, it's an advanced
+// tool that the standard render loop doesn't need.
+//
+// The next frame will be fully repainted, so the program's own output is
+// restored after clearing.
+func ClearToEndOfLine() Msg {
+	return clearToEndOfLineMsg{}
+}
+
+// clearToEndOfLineMsg is an internal message that signals to clear the line
+// from the cursor onwards. You can send a clearToEndOfLineMsg with
+// ClearToEndOfLine.
+type clearToEndOfLineMsg struct{}
+
+// ClearLine is a special command that clears the entire line the cursor is
+// on. Like ClearScreen, it's an advanced tool that the standard render loop
+// doesn't need.
+//
+// The next frame will be fully repainted, so the program's own output is
+// restored after clearing.
+func ClearLine() Msg {
+	return clearLineMsg{}
+}
+
+// clearLineMsg is an internal message that signals to clear the line the
+// cursor is on. You can send a clearLineMsg with ClearLine.
+type clearLineMsg struct{}
+
+// ScrollUpBy returns a special command that scrolls the contents of the
+// terminal's scrolling region up by n lines (SU), adding blank lines at the
+// bottom. Lines scrolled off the top of the screen go into the terminal's
+// scrollback buffer, unless the alternate screen buffer is active.
+//
+// The scrolling region is the whole screen unless it has been narrowed, so
+// results depend on the region in effect. Note that the high-performance
+// scroll commands, such as SyncScrollArea, reset it to the whole screen after
+// use.
+//
+// As this moves the last frame, the next frame is fully repainted. If n is
+// zero or less the command does nothing.
+func ScrollUpBy(n int) Cmd {
+	if n <= 0 {
+		return nil
+	}
+	return func() Msg {
+		return scrollUpByMsg(n)
+	}
+}
+
+// scrollUpByMsg is an internal message that signals to scroll the terminal up
+// by a number of lines. You can send a scrollUpByMsg with ScrollUpBy.
+type scrollUpByMsg int
+
+// ScrollDownBy returns a special command that scrolls the contents of the
+// terminal's scrolling region down by n lines (SD), adding blank lines at the
+// top. Lines scrolled off the bottom are discarded.
+//
+// Like ScrollUpBy, it acts on the scrolling region in effect, fully repaints
+// the next frame and does nothing if n is zero or less.
+func ScrollDownBy(n int) Cmd {
+	if n <= 0 {
+		return nil
+	}
+	return func() Msg {
+		return scrollDownByMsg(n)
+	}
+}
+
+// scrollDownByMsg is an internal message that signals to scroll the terminal
+// down by a number of lines. You can send a scrollDownByMsg with ScrollDownBy.
+type scrollDownByMsg int
+
+// BatchModeChange is a special command that applies several changes to the
+// terminal's modes at once, such as entering the alternate screen, enabling
+// the mouse and hiding the cursor. Applied one by one, a frame can be
+// rendered in between, which shows as a glitch during the transition; in a
+// batch, their sequences are written together, before the next frame.
+//
+//	return m, tea.BatchModeChange(
+//	    tea.EnterAltScreen,
+//	    tea.EnableMouseCellMotion,
+//	    tea.HideCursor,
+//	    tea.SetReverseVideo(true),
+//	)
+//
+// The changes are applied in a fixed order, whatever the order they're given
+// in: first the alternate screen, then the other screen modes, such as
+// reverse video, alternate scroll and insert mode, then the mouse, and last
+// the cursor, since entering or exiting the alternate screen can affect the
+// cursor. Changes in the same group keep their order.
+//
+// The commands are expected to be the special commands of this package that
+// change modes, which return right away. The messages of any other commands
+// are sent to Update as usual after the batch. If the batch enters or exits
+// the alternate screen, Update gets an AltScreenMsg, as it would for
+// EnterAltScreen and ExitAltScreen.
+func BatchModeChange(cmds ...Cmd) Cmd {
+	return func() Msg {
+		var msgs batchModeChangeMsg
+		for _, cmd := range cmds {
+			if cmd != nil {
+				msgs = append(msgs, cmd())
+			}
+		}
+		return msgs
+	}
+}
+
+// batchModeChangeMsg is an internal message that applies several mode changes
+// at once. You can send a batchModeChangeMsg with BatchModeChange.
+type batchModeChangeMsg []Msg
+
+// The groups of mode changes, in the order BatchModeChange applies them.
+const (
+	modeGroupNone = iota
+	modeGroupAltScreen
+	modeGroupScreen
+	modeGroupMouse
+	modeGroupCursor
+)
+
+// modeGroup returns the group of the mode change msg makes, or modeGroupNone
+// if it isn't a mode change.
+func modeGroup(msg Msg) int {
+	switch msg.(type) {
+	case enterAltScreenMsg, exitAltScreenMsg:
+		return modeGroupAltScreen
+	case setReverseVideoMsg, setAlternateScrollMsg, setInsertModeMsg:
+		return modeGroupScreen
+	case enableMouseCellMotionMsg, enableMouseAllMotionMsg, disableMouseMsg:
+		return modeGroupMouse
+	case showCursorMsg, hideCursorMsg, setCursorPositionMsg, setCursorBlinkMsg:
+		return modeGroupCursor
+	}
+	return modeGroupNone
+}
+
 // EnterAltScreen is a special command that tells the Bubble Tea program to
 // enter the alternate screen buffer.
 //
-// Because commands run asynchronously, this command should not be used in your
-// model's Init function. To initialize your program with the altscreen enabled
-// use the WithAltScreen ProgramOption instead.
+// It's fine to return this command from your model's Init function: the switch
+// is applied by the event loop once the renderer has started. Note, however,
+// that the first frame may already have been drawn to the main screen by
+// then. To draw the very first frame in the altscreen use the WithAltScreen
+// ProgramOption instead.
 func EnterAltScreen() Msg {
 	return enterAltScreenMsg{}
 }
@@ -52,12 +229,31 @@ func ExitAltScreen() Msg {
 // alternate screen buffer. You can send a exitAltScreenMsg with ExitAltScreen.
 type exitAltScreenMsg struct{}
 
+// AltScreenMsg is sent to Update once the renderer has entered or exited the
+// alternate screen buffer in response to an EnterAltScreen or ExitAltScreen
+// command. Active reports whether the alternate screen buffer is now in use.
+//
+// By the time Update receives this message the switch has been written to
+// the terminal, so it's safe to rely on the new screen state, for example to
+// clear the screen and draw on it.
+type AltScreenMsg struct {
+	Active bool
+}
+
+// AltScreenUnavailableMsg is sent to Update when the program was started with
+// WithAltScreenIfSupported, but the terminal doesn't support the alternate
+// screen buffer, so the program runs inline instead.
+type AltScreenUnavailableMsg struct{}
+
 // EnableMouseCellMotion is a special command that enables mouse click,
 // release, and wheel events. Mouse movement events are also captured if
-// a mouse button is pressed (i.e., drag events).
+// a mouse button is pressed (i.e., drag events). If all motion tracking was
+// enabled, it's switched off: only one of the two is enabled at a time.
+// Coordinates are requested in the SGR encoding, as with WithMouseCellMotion.
 //
-// Because commands run asynchronously, this command should not be used in your
-// model's Init function. Use the WithMouseCellMotion ProgramOption instead.
+// This command may be returned from your model's Init function, in which case
+// the mouse is enabled as soon as the program has started. The
+// WithMouseCellMotion ProgramOption does the same before the first frame.
 func EnableMouseCellMotion() Msg {
 	return enableMouseCellMotionMsg{}
 }
@@ -72,10 +268,12 @@ type enableMouseCellMotionMsg struct{}
 // button is pressed, effectively enabling support for hover interactions.
 //
 // Many modern terminals support this, but not all. If in doubt, use
-// EnableMouseCellMotion instead.
+// EnableMouseCellMotion instead. If cell motion tracking was enabled, it's
+// switched off: only one of the two is enabled at a time.
 //
-// Because commands run asynchronously, this command should not be used in your
-// model's Init function. Use the WithMouseAllMotion ProgramOption instead.
+// This command may be returned from your model's Init function, in which case
+// the mouse is enabled as soon as the program has started. The
+// WithMouseAllMotion ProgramOption does the same before the first frame.
 func EnableMouseAllMotion() Msg {
 	return enableMouseAllMotionMsg{}
 }
@@ -85,6 +283,17 @@ func EnableMouseAllMotion() Msg {
 // enableMouseAllMotionMsg, use the EnableMouseAllMotion command.
 type enableMouseAllMotionMsg struct{}
 
+const (
+	enableMouseSGRSeq  = "\x1b[?1006h"
+	disableMouseSGRSeq = "\x1b[?1006l"
+
+	enableMouseUTF8Seq  = "\x1b[?1005h"
+	disableMouseUTF8Seq = "\x1b[?1005l"
+
+	enableMousePixelsSeq  = "\x1b[?1016h"
+	disableMousePixelsSeq = "\x1b[?1016l"
+)
+
 // DisableMouse is a special command that stops listening for mouse events.
 func DisableMouse() Msg {
 	return disableMouseMsg{}
@@ -116,6 +325,276 @@ func ShowCursor() Msg {
 // this message with ShowCursor.
 type showCursorMsg struct{}
 
+// SetCursorPosition is a special command that moves the cursor to the given
+// column and row of the terminal, counting from zero. The renderer moves the
+// cursor around to draw each frame, so it puts it back there after every
+// frame rather than leaving it wherever drawing ended. That makes it useful
+// together with WithShownCursor, to show the cursor where text is being
+// edited.
+//
+// A negative coordinate stops placing the cursor, leaving it wherever frames
+// leave it again.
+func SetCursorPosition(x, y int) Cmd {
+	return func() Msg {
+		return setCursorPositionMsg{x: x, y: y}
+	}
+}
+
+// setCursorPositionMsg is an internal message that signals to move the cursor
+// to a cell. You can send a setCursorPositionMsg with SetCursorPosition.
+type setCursorPositionMsg struct {
+	x, y int
+}
+
+// RequestWindowSize is a special command that queries the size of the
+// terminal and delivers it to Update as a WindowSizeMsg, like the ones sent at
+// startup and when the terminal is resized. Use it when the size may have
+// changed while the program wasn't watching, such as after running another
+// program with Exec.
+//
+// If the size can't be queried, for example because the output isn't a
+// terminal, the last size sent is sent again, if any.
+func RequestWindowSize() Msg {
+	return requestWindowSizeMsg{}
+}
+
+// requestWindowSizeMsg is an internal message that signals to query the size
+// of the terminal. You can send a requestWindowSizeMsg with RequestWindowSize.
+type requestWindowSizeMsg struct{}
+
+// SetReverseVideo is a special command that inverts the colors of the whole
+// screen (DECSCNM), swapping the default foreground and background. This is
+// a screen-wide effect, distinct from the reverse attribute used to style
+// individual cells, and is useful for things like accessibility toggles and
+// visual bells.
+//
+// Support varies across terminals. The sequence is sent regardless, as it's
+// harmless on terminals that don't support it.
+//
+// Reverse video will be automatically disabled when the program exits.
+func SetReverseVideo(enabled bool) Cmd {
+	return func() Msg {
+		return setReverseVideoMsg(enabled)
+	}
+}
+
+// setReverseVideoMsg is an internal message that signals to enable or disable
+// reverse video. You can send a setReverseVideoMsg with SetReverseVideo.
+type setReverseVideoMsg bool
+
+const (
+	enableReverseVideoSeq  = "\x1b[?5h"
+	disableReverseVideoSeq = "\x1b[?5l"
+)
+
+// SetAlternateScroll is a special command that enables or disables the
+// terminal's alternate scroll mode (1007). In this mode, scrolling the wheel
+// while the alternate screen is active sends up and down arrow keys instead
+// of scrolling the terminal's scrollback, so the program gets KeyUp and
+// KeyDown messages without having to enable the mouse. Outside of the
+// alternate screen it has no effect.
+//
+// Mouse tracking takes precedence: while it's enabled, with
+// EnableMouseCellMotion or EnableMouseAllMotion, the wheel is reported as
+// mouse events as usual, whether or not alternate scroll mode is on. So use
+// one or the other, depending on whether you'd rather get the wheel as keys
+// or as mouse events.
+//
+// Terminals that don't support alternate scroll mode ignore it; many enable
+// it by default. It will be automatically disabled when the program exits.
+func SetAlternateScroll(enabled bool) Cmd {
+	return func() Msg {
+		return setAlternateScrollMsg(enabled)
+	}
+}
+
+// setAlternateScrollMsg is an internal message that signals to enable or
+// disable alternate scroll mode. You can send a setAlternateScrollMsg with
+// SetAlternateScroll.
+type setAlternateScrollMsg bool
+
+const (
+	enableAlternateScrollSeq  = "\x1b[?1007h"
+	disableAlternateScrollSeq = "\x1b[?1007l"
+)
+
+// SetInsertMode is a special command that enables or disables the terminal's
+// insert mode (IRM). In insert mode, text written to the terminal shifts the
+// rest of the line to the right rather than overwriting it, which is what
+// programs editing a line in place, outside of the alternate screen, may
+// want.
+//
+// Most programs don't need this: the renderer redraws the lines of the view
+// that changed, which works the same either way, as long as nothing else
+// writes to the terminal. It's here for programs doing their own low-level
+// line editing. Insert mode will be automatically disabled when the program
+// exits.
+func SetInsertMode(enabled bool) Cmd {
+	return func() Msg {
+		return setInsertModeMsg(enabled)
+	}
+}
+
+// setInsertModeMsg is an internal message that signals to enable or disable
+// insert mode. You can send a setInsertModeMsg with SetInsertMode.
+type setInsertModeMsg bool
+
+const (
+	enableInsertModeSeq  = "\x1b[4h"
+	disableInsertModeSeq = "\x1b[4l"
+)
+
+// SetCursorBlink is a special command that makes the cursor blink or hold
+// steady, which is useful for screen recordings, for instance. It keeps the
+// cursor's shape, so it composes with other cursor style settings.
+//
+// Not all terminals support it, and some ignore it in favor of the user's
+// configuration. The cursor style is reset to the terminal's default when the
+// program exits.
+func SetCursorBlink(blink bool) Cmd {
+	return func() Msg {
+		return setCursorBlinkMsg(blink)
+	}
+}
+
+// setCursorBlinkMsg is an internal message that signals to make the cursor
+// blink or not. You can send a setCursorBlinkMsg with SetCursorBlink.
+type setCursorBlinkMsg bool
+
+// cursorStyle is a cursor style as set with DECSCUSR. Odd styles blink and
+// even ones are steady, pairwise per shape: 1 and 2 are a block, 3 and 4 an
+// underline and 5 and 6 a bar. Zero is the terminal's default.
+type cursorStyle int
+
+const cursorStyleDefault cursorStyle = 0
+
+// withBlink returns the style with the same shape as s that blinks or not.
+// The default style is taken to be a block.
+func (s cursorStyle) withBlink(blink bool) cursorStyle {
+	shape := 0
+	if s > cursorStyleDefault {
+		shape = (int(s) - 1) / 2
+	}
+	if blink {
+		return cursorStyle(shape*2 + 1)
+	}
+	return cursorStyle(shape*2 + 2)
+}
+
+// sequence returns the control sequence setting the style.
+func (s cursorStyle) sequence() string {
+	return fmt.Sprintf(termenv.CSI+"%d q", int(s))
+}
+
+// SetCWD returns a special command that tells the terminal the current working
+// directory (OSC 7), which it uses for things like opening new tabs in the same
+// directory. This is useful for programs that navigate the file system, such
+// as file managers.
+//
+// The path must be absolute, otherwise the command does nothing. It's sent
+// along with the local hostname, so the terminal can tell whether the path is
+// on the same machine.
+func SetCWD(path string) Cmd {
+	if !filepath.IsAbs(path) {
+		return nil
+	}
+	return func() Msg {
+		host, _ := os.Hostname()
+		return setCWDMsg(cwdURL(host, path))
+	}
+}
+
+// setCWDMsg is an internal message holding the URL of a working directory to
+// report to the terminal. You can send a setCWDMsg with SetCWD.
+type setCWDMsg string
+
+// cwdURL returns the file URL of the given absolute path on the given host,
+// with the path escaped as needed.
+func cwdURL(host, path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths start with a drive letter.
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Host: host, Path: path}
+	return u.String()
+}
+
+// setCWDSeq is the control sequence reporting the working directory, taking
+// its URL.
+const setCWDSeq = "\x1b]7;%s\a"
+
+// EmitRawSequence is a command that writes the given control sequence to the
+// terminal as is. It's an escape hatch for trying out terminal features Bubble
+// Tea doesn't support yet. The sequence is written between frames, never in
+// the middle of one.
+//
+// To keep text from being written by accident, the sequence must start with
+// ESC; otherwise EmitRawSequence returns nil. Beyond that it's up to you:
+// Bubble Tea doesn't know what the sequence does, so sequences that move the
+// cursor, change the screen or switch modes can corrupt the display, and
+// modes you turn on aren't turned off when the program exits.
+func EmitRawSequence(seq string) Cmd {
+	if !strings.HasPrefix(seq, "\x1b") {
+		return nil
+	}
+	return func() Msg {
+		return rawSequenceMsg(seq)
+	}
+}
+
+// rawSequenceMsg is an internal message holding a control sequence to write
+// as is. You can send a rawSequenceMsg with EmitRawSequence.
+type rawSequenceMsg string
+
+// ResetTerminal is a special command that brings the terminal back to a known
+// good state, such as after running a program that left it in a mess. It
+// performs a soft reset (DECSTR), which resets things like text attributes,
+// the scrolling region and keypad modes, and then re-applies the modes Bubble
+// Tea is using: the alternate screen, mouse tracking, cursor visibility and so
+// on. The screen is repainted afterwards.
+//
+// Unlike a hard reset (RIS), a soft reset keeps the screen contents and the
+// scrollback buffer.
+func ResetTerminal() Msg {
+	return resetTerminalMsg{}
+}
+
+// resetTerminalMsg is an internal message that signals to reset the terminal.
+// You can send a resetTerminalMsg with ResetTerminal.
+type resetTerminalMsg struct{}
+
+const softResetSeq = "\x1b[!p"
+
+// SetTabStop is a special command that sets a horizontal tab stop at the
+// column the cursor is in (HTS). Tab characters in the view will then advance
+// to it.
+//
+// The cursor sits wherever the renderer left it after drawing the last frame.
+// Use RequestCursorPosition to find out which column that is.
+func SetTabStop() Msg {
+	return setTabStopMsg{}
+}
+
+// setTabStopMsg is an internal message that signals to set a tab stop at the
+// cursor column. You can send a setTabStopMsg with SetTabStop.
+type setTabStopMsg struct{}
+
+// ClearTabStops is a special command that clears all horizontal tab stops
+// (TBC), including the terminal's default ones every eight columns.
+func ClearTabStops() Msg {
+	return clearTabStopsMsg{}
+}
+
+// clearTabStopsMsg is an internal message that signals to clear all tab
+// stops. You can send a clearTabStopsMsg with ClearTabStops.
+type clearTabStopsMsg struct{}
+
+const (
+	setTabStopSeq    = "\x1bH"
+	clearTabStopsSeq = "\x1b[3g"
+)
+
 // EnterAltScreen enters the alternate screen buffer, which consumes the entire
 // terminal window. ExitAltScreen will return the terminal to its former state.
 //
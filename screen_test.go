@@ -2,7 +2,13 @@ package tea
 
 import (
 	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestClearMsg(t *testing.T) {
@@ -16,6 +22,41 @@ func TestClearMsg(t *testing.T) {
 			cmds:     []Cmd{ClearScreen},
 			expected: "\x1b[?25l\x1b[2J\x1b[1;1H\x1b[1;1Hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
 		},
+		{
+			name:     "clear_to_end_of_screen",
+			cmds:     []Cmd{ClearToEndOfScreen},
+			expected: "\x1b[?25l\x1b[0Jsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "clear_to_end_of_line",
+			cmds:     []Cmd{ClearToEndOfLine},
+			expected: "\x1b[?25l\x1b[0Ksuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "clear_line",
+			cmds:     []Cmd{ClearLine},
+			expected: "\x1b[?25l\x1b[2Ksuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "scroll_up_by",
+			cmds:     []Cmd{ScrollUpBy(3)},
+			expected: "\x1b[?25l\x1b[3Ssuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "scroll_down_by",
+			cmds:     []Cmd{ScrollDownBy(2)},
+			expected: "\x1b[?25l\x1b[2Tsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "tab_stops",
+			cmds:     []Cmd{ClearTabStops, SetTabStop},
+			expected: "\x1b[?25l\x1b[3g\x1bHsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "request_cursor_position",
+			cmds:     []Cmd{RequestCursorPosition},
+			expected: "\x1b[?25l\x1b[6nsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
 		{
 			name:     "altscreen",
 			cmds:     []Cmd{EnterAltScreen, ExitAltScreen},
@@ -29,23 +70,103 @@ func TestClearMsg(t *testing.T) {
 		{
 			name:     "mouse_cellmotion",
 			cmds:     []Cmd{EnableMouseCellMotion},
-			expected: "\x1b[?25l\x1b[?1002hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+			expected: "\x1b[?25l\x1b[?1002h\x1b[?1006hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1006l\x1b[?1003l",
 		},
 		{
 			name:     "mouse_allmotion",
 			cmds:     []Cmd{EnableMouseAllMotion},
-			expected: "\x1b[?25l\x1b[?1003hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+			expected: "\x1b[?25l\x1b[?1003h\x1b[?1006hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+		},
+		{
+			name:     "mouse_cell_to_all_motion",
+			cmds:     []Cmd{EnableMouseCellMotion, EnableMouseAllMotion},
+			expected: "\x1b[?25l\x1b[?1002h\x1b[?1006h\x1b[?1002l\x1b[?1003h\x1b[?1006hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l",
+		},
+		{
+			name:     "mouse_all_to_cell_motion",
+			cmds:     []Cmd{EnableMouseAllMotion, EnableMouseCellMotion},
+			expected: "\x1b[?25l\x1b[?1003h\x1b[?1006h\x1b[?1003l\x1b[?1002h\x1b[?1006hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1006l\x1b[?1003l",
 		},
 		{
 			name:     "mouse_disable",
 			cmds:     []Cmd{EnableMouseAllMotion, DisableMouse},
-			expected: "\x1b[?25l\x1b[?1003h\x1b[?1002l\x1b[?1003lsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+			expected: "\x1b[?25l\x1b[?1003h\x1b[?1006h\x1b[?1002l\x1b[?1003l\x1b[?1006lsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
 		},
 		{
 			name:     "cursor_hide",
 			cmds:     []Cmd{HideCursor},
 			expected: "\x1b[?25l\x1b[?25lsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
 		},
+		{
+			name:     "request_cell_size",
+			cmds:     []Cmd{RequestCellSize, RequestWindowPixelSize},
+			expected: "\x1b[?25l\x1b[16t\x1b[14tsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "request_foreground_color",
+			cmds:     []Cmd{RequestForegroundColor},
+			expected: "\x1b[?25l\x1b]10;?\asuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "reverse_video",
+			cmds:     []Cmd{SetReverseVideo(true)},
+			expected: "\x1b[?25l\x1b[?5hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?5l",
+		},
+		{
+			name:     "raw_sequence",
+			cmds:     []Cmd{EmitRawSequence("\x1b]2;title\a")},
+			expected: "\x1b[?25l\x1b]2;title\asuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "alternate_scroll",
+			cmds:     []Cmd{SetAlternateScroll(true)},
+			expected: "\x1b[?25l\x1b[?1007hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1007l",
+		},
+		{
+			name:     "alternate_scroll_toggle",
+			cmds:     []Cmd{SetAlternateScroll(true), SetAlternateScroll(false)},
+			expected: "\x1b[?25l\x1b[?1007h\x1b[?1007lsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "insert_mode",
+			cmds:     []Cmd{SetInsertMode(true)},
+			expected: "\x1b[?25l\x1b[4hsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[4l",
+		},
+		{
+			name:     "insert_mode_toggle",
+			cmds:     []Cmd{SetInsertMode(true), SetInsertMode(false)},
+			expected: "\x1b[?25l\x1b[4h\x1b[4lsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "batch_mode_change",
+			cmds:     []Cmd{BatchModeChange(HideCursor, EnableMouseCellMotion, SetReverseVideo(true), EnterAltScreen)},
+			expected: "\x1b[?25l\x1b[?1049h\x1b[2J\x1b[1;1H\x1b[1;1H\x1b[?25l\x1b[?5h\x1b[?1002h\x1b[?1006h\x1b[?25lsuccess\r\n\x1b[2;0H\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1006l\x1b[?1003l\x1b[?5l\x1b[?1049l\x1b[?25h",
+		},
+		{
+			name:     "reset_terminal",
+			cmds:     []Cmd{ResetTerminal},
+			expected: "\x1b[?25l\x1b[!p\x1b[?25lsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
+		{
+			name:     "reset_terminal_modes",
+			cmds:     []Cmd{EnableMouseAllMotion, SetReverseVideo(true), ResetTerminal},
+			expected: "\x1b[?25l\x1b[?1003h\x1b[?1006h\x1b[?5h\x1b[!p\x1b[?1003h\x1b[?1006h\x1b[?5h\x1b[?25lsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[?1006l\x1b[?5l",
+		},
+		{
+			name:     "cursor_steady",
+			cmds:     []Cmd{SetCursorBlink(false)},
+			expected: "\x1b[?25l\x1b[2 qsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[0 q",
+		},
+		{
+			name:     "cursor_blink_toggle",
+			cmds:     []Cmd{SetCursorBlink(false), SetCursorBlink(true)},
+			expected: "\x1b[?25l\x1b[2 q\x1b[1 qsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l\x1b[0 q",
+		},
+		{
+			name:     "reverse_video_toggle",
+			cmds:     []Cmd{SetReverseVideo(true), SetReverseVideo(false)},
+			expected: "\x1b[?25l\x1b[?5h\x1b[?5lsuccess\r\n\x1b[0D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l",
+		},
 		{
 			name:     "cursor_hideshow",
 			cmds:     []Cmd{HideCursor, ShowCursor},
@@ -74,3 +195,496 @@ func TestClearMsg(t *testing.T) {
 		})
 	}
 }
+
+func TestMouseUTF8(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithMouseCellMotion(), WithMouseUTF8())
+
+	go p.Send(Quit())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\x1b[?25l\x1b[?1002h\x1b[?1005h") {
+		t.Errorf("expected utf-8 mouse mode to be enabled with the mouse, got %q", out)
+	}
+	if !strings.HasSuffix(out, "\x1b[?1002l\x1b[?1005l\x1b[?1003l") {
+		t.Errorf("expected utf-8 mouse mode to be disabled on teardown, got %q", out)
+	}
+}
+
+func TestMousePixels(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithMouseAllMotion(), WithMousePixels())
+
+	go p.Send(Quit())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\x1b[?25l\x1b[?1003h\x1b[?1006h\x1b[?1016h"+requestCellSizeSeq) {
+		t.Errorf("expected pixel mouse mode to be enabled with the mouse and the cell size queried, got %q", out)
+	}
+	if !strings.HasSuffix(out, "\x1b[?1002l\x1b[?1003l\x1b[?1006l\x1b[?1016l") {
+		t.Errorf("expected pixel mouse mode to be disabled on teardown, got %q", out)
+	}
+}
+
+func TestDECLocator(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithDECLocator())
+
+	go p.Send(Quit())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\x1b[?25l"+enableDECLocatorSeq) {
+		t.Errorf("expected the locator to be enabled, got %q", out)
+	}
+	if !strings.HasSuffix(out, "\x1b[?1002l\x1b[?1003l"+disableDECLocatorSeq) {
+		t.Errorf("expected the locator to be disabled on teardown, got %q", out)
+	}
+}
+
+func TestMouseHighlight(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithMouseHighlight())
+
+	go p.Send(Quit())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\x1b[?25l"+enableMouseHighlightSeq) {
+		t.Errorf("expected highlight tracking to be enabled, got %q", out)
+	}
+	if !strings.HasSuffix(out, "\x1b[?1002l\x1b[?1003l"+disableMouseHighlightSeq) {
+		t.Errorf("expected highlight tracking to be disabled on teardown, got %q", out)
+	}
+}
+
+func TestInBandResize(t *testing.T) {
+	var buf bytes.Buffer
+	in := bytes.NewBufferString("\x1b[48;30;100;600;1000t")
+
+	m := &testReportModel{done: func(msg Msg) bool {
+		return msg == WindowSizeMsg{Width: 100, Height: 30}
+	}}
+	p := NewProgram(m, WithInput(in), WithOutput(&buf), WithInBandResize())
+	go func() {
+		time.Sleep(2 * time.Second)
+		p.Quit()
+	}()
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.quit {
+		t.Fatalf("expected the report as a WindowSizeMsg, got %#v", m.msgs)
+	}
+	r := p.renderer.(*standardRenderer)
+	if r.width != 100 || r.height != 30 {
+		t.Errorf("expected renderer to be 100x30, got %dx%d", r.width, r.height)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "\x1b[?25l"+enableInBandResizeSeq) {
+		t.Errorf("expected in-band resize to be enabled, got %q", out)
+	}
+	if !strings.HasSuffix(out, disableInBandResizeSeq) {
+		t.Errorf("expected in-band resize to be disabled on teardown, got %q", out)
+	}
+
+	// A viewport's size is fixed, so resizes aren't asked for.
+	buf.Reset()
+	p = NewProgram(&testModel{}, WithInput(&bytes.Buffer{}), WithOutput(&buf), WithInBandResize(), WithViewport(0, 0, 10, 5))
+	go p.Send(Quit())
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), enableInBandResizeSeq) {
+		t.Errorf("expected in-band resize not to be enabled in a viewport, got %q", buf.String())
+	}
+}
+
+func TestSimulatedResize(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	go p.Send(sequenceMsg{
+		func() Msg { return WindowSizeMsg{Width: 3, Height: 10} },
+		Quit,
+	})
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := p.renderer.(*standardRenderer)
+	if r.width != 3 || r.height != 10 {
+		t.Errorf("expected renderer to be 3x10, got %dx%d", r.width, r.height)
+	}
+
+	// The final frame is truncated to the simulated width.
+	if !strings.HasSuffix(buf.String(), "suc\r\n\x1b[3D\x1b[2K\x1b[?25h\x1b[?1002l\x1b[?1003l") {
+		t.Errorf("expected frame truncated to the new width, got %q", buf.String())
+	}
+}
+
+func TestAltScreenMsg(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{done: func(msg Msg) bool {
+		return msg == AltScreenMsg{Active: false}
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	go p.Send(sequenceMsg{EnterAltScreen, ExitAltScreen})
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Msg
+	for _, msg := range m.msgs {
+		switch msg.(type) {
+		case AltScreenMsg:
+			got = append(got, msg)
+		case enterAltScreenMsg, exitAltScreenMsg:
+			t.Errorf("internal message %T should not reach Update", msg)
+		}
+	}
+
+	expected := []Msg{AltScreenMsg{Active: true}, AltScreenMsg{Active: false}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, got)
+	}
+}
+
+func TestScrollByNonPositive(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		if ScrollUpBy(n) != nil {
+			t.Errorf("expected ScrollUpBy(%d) to return nil", n)
+		}
+		if ScrollDownBy(n) != nil {
+			t.Errorf("expected ScrollDownBy(%d) to return nil", n)
+		}
+	}
+}
+
+func TestSendWindowSize(t *testing.T) {
+	t.Run("zero size is retried", func(t *testing.T) {
+		p := NewProgram(nil)
+		sizes := [][2]int{{0, 0}, {80, 0}, {80, 24}}
+
+		go p.sendWindowSize(func() (int, int, error) {
+			size := sizes[0]
+			sizes = sizes[1:]
+			return size[0], size[1], nil
+		})
+
+		msg := <-p.msgs
+		if msg != (WindowSizeMsg{Width: 80, Height: 24}) {
+			t.Errorf("expected the first valid size, got %#v", msg)
+		}
+	})
+
+	t.Run("zero size is never sent", func(t *testing.T) {
+		p := NewProgram(nil)
+		calls := 0
+
+		// Nothing reads the program's messages, so this would block if it
+		// tried to send one.
+		p.sendWindowSize(func() (int, int, error) {
+			calls++
+			return 0, 0, nil
+		})
+
+		if calls != windowSizeRetries+1 {
+			t.Errorf("expected %d queries, got %d", windowSizeRetries+1, calls)
+		}
+	})
+
+	t.Run("zero size sent by the program is dropped", func(t *testing.T) {
+		var buf bytes.Buffer
+		var in bytes.Buffer
+
+		m := &testReportModel{done: func(msg Msg) bool {
+			_, ok := msg.(WindowSizeMsg)
+			return ok
+		}}
+		p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+		go func() {
+			p.Send(WindowSizeMsg{})
+			p.Send(WindowSizeMsg{Width: 80})
+			p.Send(WindowSizeMsg{Width: 80, Height: 24})
+		}()
+
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		var sizes []Msg
+		for _, msg := range m.msgs {
+			if _, ok := msg.(WindowSizeMsg); ok {
+				sizes = append(sizes, msg)
+			}
+		}
+		expected := []Msg{WindowSizeMsg{Width: 80, Height: 24}}
+		if !reflect.DeepEqual(sizes, expected) {
+			t.Errorf("expected %#v, got %#v", expected, sizes)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		p := NewProgram(nil)
+		p.errs = make(chan error, 1)
+
+		p.sendWindowSize(func() (int, int, error) {
+			return 0, 0, errors.New("no size")
+		})
+
+		if err := <-p.errs; err == nil || err.Error() != "no size" {
+			t.Errorf("expected the error to be reported, got %v", err)
+		}
+	})
+
+	t.Run("custom size func", func(t *testing.T) {
+		var buf bytes.Buffer
+		var in bytes.Buffer
+
+		// The output isn't a terminal, so its size is unknown, but the
+		// function knows better.
+		m := &testReportModel{done: func(msg Msg) bool {
+			_, ok := msg.(WindowSizeMsg)
+			return ok
+		}}
+		p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithWindowSizeFunc(func() (int, int, error) {
+			return 100, 40, nil
+		}))
+
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		last := m.msgs[len(m.msgs)-1]
+		if last != (WindowSizeMsg{Width: 100, Height: 40}) {
+			t.Errorf("expected the size from the function, got %#v", last)
+		}
+	})
+}
+
+func TestCursorStyleWithBlink(t *testing.T) {
+	tt := []struct {
+		style    cursorStyle
+		blink    bool
+		expected cursorStyle
+	}{
+		{cursorStyleDefault, true, 1},
+		{cursorStyleDefault, false, 2},
+		{1, false, 2},
+		{2, true, 1},
+		{3, false, 4},
+		{4, true, 3},
+		{5, false, 6},
+		{6, true, 5},
+		{6, false, 6},
+	}
+
+	for _, tc := range tt {
+		if s := tc.style.withBlink(tc.blink); s != tc.expected {
+			t.Errorf("expected style %d with blink %v to be %d, got %d", tc.style, tc.blink, tc.expected, s)
+		}
+	}
+}
+
+func TestSetCWD(t *testing.T) {
+	if cmd := SetCWD("relative/path"); cmd != nil {
+		t.Fatalf("expected no command for a relative path")
+	}
+
+	host, _ := os.Hostname()
+	msg := SetCWD("/tmp/my files")()
+	if expected := setCWDMsg(cwdURL(host, "/tmp/my files")); msg != expected {
+		t.Fatalf("expected %q, got %q", expected, msg)
+	}
+}
+
+func TestEmitRawSequence(t *testing.T) {
+	for _, seq := range []string{"", "text", "text\x1b[1m"} {
+		if cmd := EmitRawSequence(seq); cmd != nil {
+			t.Errorf("expected no command for %q", seq)
+		}
+	}
+}
+
+func TestCWDURL(t *testing.T) {
+	tt := []struct {
+		host     string
+		path     string
+		expected string
+	}{
+		{"box", "/home/user", "file://box/home/user"},
+		{"box", "/home/user/my files", "file://box/home/user/my%20files"},
+		{"box", "/tmp/100%/#1?", "file://box/tmp/100%25/%231%3F"},
+		{"box", "/srv/café", "file://box/srv/caf%C3%A9"},
+		{"", "/", "file:///"},
+	}
+
+	for _, tc := range tt {
+		if u := cwdURL(tc.host, tc.path); u != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, u)
+		}
+	}
+}
+
+func TestRequestWindowSize(t *testing.T) {
+	sizes := func(m *testReportModel) []Msg {
+		var r []Msg
+		for _, msg := range m.msgs {
+			if _, ok := msg.(WindowSizeMsg); ok {
+				r = append(r, msg)
+			}
+		}
+		return r
+	}
+	secondSize := func() func(Msg) bool {
+		n := 0
+		return func(msg Msg) bool {
+			if _, ok := msg.(WindowSizeMsg); ok {
+				n++
+			}
+			return n == 2
+		}
+	}
+
+	t.Run("queried", func(t *testing.T) {
+		var buf bytes.Buffer
+		var in bytes.Buffer
+
+		// Each query finds the terminal wider: once at startup, and once on
+		// request.
+		var mtx sync.Mutex
+		width := 70
+		m := &testReportModel{done: secondSize()}
+		p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithWindowSizeFunc(func() (int, int, error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			width += 10
+			return width, 24, nil
+		}))
+
+		go p.Send(RequestWindowSize())
+
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		got := sizes(m)
+		if len(got) != 2 || got[0] == got[1] {
+			t.Errorf("expected two different sizes, got %#v", got)
+		}
+	})
+
+	t.Run("last known size", func(t *testing.T) {
+		var buf bytes.Buffer
+		var in bytes.Buffer
+
+		// The output isn't a terminal, so the size can't be queried.
+		m := &testReportModel{done: secondSize()}
+		p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+		go p.Send(sequenceMsg{
+			func() Msg { return WindowSizeMsg{Width: 80, Height: 24} },
+			RequestWindowSize,
+		})
+
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+		expected := []Msg{WindowSizeMsg{Width: 80, Height: 24}, WindowSizeMsg{Width: 80, Height: 24}}
+		if got := sizes(m); !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %#v, got %#v", expected, got)
+		}
+	})
+}
+
+func TestAltScreenIfSupported(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	// A buffer isn't a terminal, so it has no alternate screen.
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(AltScreenUnavailableMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithAltScreenIfSupported())
+	go func() {
+		time.Sleep(2 * time.Second)
+		p.Quit()
+	}()
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.quit {
+		t.Fatalf("expected AltScreenUnavailableMsg, got %#v", m.msgs)
+	}
+	if strings.Contains(buf.String(), "\x1b[?1049h") {
+		t.Errorf("expected the program to run inline, got %q", buf.String())
+	}
+}
+
+func TestBatchModeChange(t *testing.T) {
+	type otherMsg struct{}
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(otherMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+	go p.Send(BatchModeChange(
+		func() Msg { return otherMsg{} },
+		EnterAltScreen,
+		nil,
+	)())
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Msg
+	for _, msg := range m.msgs {
+		switch msg.(type) {
+		case AltScreenMsg, otherMsg:
+			got = append(got, msg)
+		}
+	}
+	expected := []Msg{AltScreenMsg{Active: true}, otherMsg{}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, got %#v", expected, got)
+	}
+}
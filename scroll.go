@@ -0,0 +1,138 @@
+package tea
+
+import (
+	"sync"
+	"time"
+)
+
+// scrollGestureWindow is how long after a wheel event the next one in the same
+// direction still counts as part of the same scroll gesture.
+const scrollGestureWindow = 100 * time.Millisecond
+
+// MouseScrollMsg summarizes a scroll gesture: a burst of wheel events in the
+// same direction, such as from a flick on a trackpad. It's sent instead of the
+// individual wheel events when WithScrollGrouping is set, so that a view can
+// scroll in proportion to the gesture rather than a line per event.
+type MouseScrollMsg struct {
+	// Direction is either MouseWheelUp or MouseWheelDown.
+	Direction MouseEventType
+
+	// Lines is the number of wheel events in the gesture, counting each of
+	// the notches of merged events (see WithReportScroll).
+	Lines int
+
+	// Momentum is how fast the gesture was, in lines per second, from its
+	// first wheel event to its last. It's zero for gestures whose events
+	// all arrived at once, such as a single notch of the wheel.
+	Momentum int
+
+	// X and Y are the position of the pointer at the last wheel event.
+	X int
+	Y int
+}
+
+// scrollGrouper groups consecutive wheel events into scroll gestures. A
+// gesture ends when no wheel event has arrived for the length of the window,
+// when the wheel changes direction, which starts a new gesture, or when any
+// other message arrives, which is delivered after the gesture so that ordering
+// is preserved.
+type scrollGrouper struct {
+	mtx    sync.Mutex
+	window time.Duration
+	send   func(Msg)
+
+	// the gesture being grouped, if any, and when its first and last wheel
+	// events arrived
+	pending     *MouseScrollMsg
+	first, last time.Time
+
+	// the timer ending the gesture, and its generation, so that a timer
+	// firing after it was stopped doesn't deliver anything
+	timer *time.Timer
+	gen   int
+}
+
+func newScrollGrouper(window time.Duration, send func(Msg)) *scrollGrouper {
+	return &scrollGrouper{
+		window: window,
+		send:   send,
+	}
+}
+
+// filter returns the messages that should be delivered right away, replacing
+// wheel events with the gestures they make up. The gesture still going on is
+// held back, to be delivered once it ends.
+func (g *scrollGrouper) filter(msgs []Msg, now time.Time) []Msg {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	out := make([]Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		m, ok := msg.(MouseMsg)
+		if !ok || (m.Type != MouseWheelUp && m.Type != MouseWheelDown) {
+			if g.pending != nil {
+				out = append(out, g.take())
+			}
+			out = append(out, msg)
+			continue
+		}
+
+		if g.pending != nil && (g.pending.Direction != m.Type || now.Sub(g.last) > g.window) {
+			out = append(out, g.take())
+		}
+		if g.pending == nil {
+			g.pending = &MouseScrollMsg{Direction: m.Type}
+			g.first = now
+		}
+		lines := m.Delta
+		if lines < 1 {
+			lines = 1
+		}
+		g.pending.Lines += lines
+		g.pending.X, g.pending.Y = m.X, m.Y
+		g.last = now
+	}
+
+	if g.pending != nil {
+		g.stopTimer()
+		gen := g.gen
+		g.timer = time.AfterFunc(g.window, func() {
+			g.flush(gen)
+		})
+	}
+
+	return out
+}
+
+// take ends the pending gesture and returns it. The mutex must be held.
+func (g *scrollGrouper) take() MouseScrollMsg {
+	g.stopTimer()
+	m := *g.pending
+	g.pending = nil
+	if d := g.last.Sub(g.first); d > 0 {
+		m.Momentum = int(float64(m.Lines) / d.Seconds())
+	}
+	return m
+}
+
+// flush delivers the pending gesture, if any.
+func (g *scrollGrouper) flush(gen int) {
+	g.mtx.Lock()
+	if gen != g.gen || g.pending == nil {
+		g.mtx.Unlock()
+		return
+	}
+	m := g.take()
+	g.mtx.Unlock()
+
+	g.send(m)
+}
+
+// stopTimer cancels the end of the pending gesture. The mutex must be held.
+func (g *scrollGrouper) stopTimer() {
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+		g.gen++
+	}
+}
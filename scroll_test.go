@@ -0,0 +1,81 @@
+package tea
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScrollGrouper(t *testing.T) {
+	up := MouseMsg{X: 1, Y: 2, Type: MouseWheelUp}
+	down := MouseMsg{X: 1, Y: 2, Type: MouseWheelDown}
+
+	t.Run("wheel events are grouped", func(t *testing.T) {
+		g := newScrollGrouper(time.Hour, func(Msg) {})
+		now := time.Now()
+
+		if out := g.filter([]Msg{up, up}, now); len(out) != 0 {
+			t.Fatalf("expected the gesture to be held back, got %v", out)
+		}
+		merged := up
+		merged.Delta = 3
+		if out := g.filter([]Msg{merged}, now.Add(100*time.Millisecond)); len(out) != 0 {
+			t.Fatalf("expected the gesture to be held back, got %v", out)
+		}
+
+		// Other messages end the gesture, and come after it.
+		out := g.filter([]Msg{KeyMsg{Type: KeyEnter}}, now)
+		expected := []Msg{
+			MouseScrollMsg{Direction: MouseWheelUp, Lines: 5, Momentum: 50, X: 1, Y: 2},
+			KeyMsg{Type: KeyEnter},
+		}
+		if !reflect.DeepEqual(out, expected) {
+			t.Fatalf("expected %v, got %v", expected, out)
+		}
+	})
+
+	t.Run("direction changes split gestures", func(t *testing.T) {
+		g := newScrollGrouper(time.Hour, func(Msg) {})
+		now := time.Now()
+
+		out := g.filter([]Msg{up, up, down, up}, now)
+		expected := []Msg{
+			MouseScrollMsg{Direction: MouseWheelUp, Lines: 2, X: 1, Y: 2},
+			MouseScrollMsg{Direction: MouseWheelDown, Lines: 1, X: 1, Y: 2},
+		}
+		if !reflect.DeepEqual(out, expected) {
+			t.Fatalf("expected %v, got %v", expected, out)
+		}
+	})
+
+	t.Run("pauses split gestures", func(t *testing.T) {
+		g := newScrollGrouper(time.Second, func(Msg) {})
+		now := time.Now()
+
+		g.filter([]Msg{up}, now)
+		out := g.filter([]Msg{up}, now.Add(2*time.Second))
+		expected := []Msg{MouseScrollMsg{Direction: MouseWheelUp, Lines: 1, X: 1, Y: 2}}
+		if !reflect.DeepEqual(out, expected) {
+			t.Fatalf("expected %v, got %v", expected, out)
+		}
+	})
+
+	t.Run("gesture is delivered after the window", func(t *testing.T) {
+		sent := make(chan Msg, 1)
+		g := newScrollGrouper(10*time.Millisecond, func(msg Msg) {
+			sent <- msg
+		})
+
+		g.filter([]Msg{down, down, down}, time.Now())
+
+		select {
+		case msg := <-sent:
+			expected := MouseScrollMsg{Direction: MouseWheelDown, Lines: 3, X: 1, Y: 2}
+			if msg != expected {
+				t.Fatalf("expected %v, got %v", expected, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the gesture to be delivered")
+		}
+	})
+}
@@ -6,6 +6,7 @@ package tea
 import (
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 )
 
@@ -31,3 +32,48 @@ func (p *Program) listenForResize(done chan struct{}) {
 		p.checkResize()
 	}
 }
+
+// suspendSupported reports whether the program can be suspended.
+const suspendSupported = true
+
+// suspendProcess stops the process group, as the shell's ctrl+z would, and
+// waits for it to be continued. It's a variable so that tests can replace
+// it.
+var suspendProcess = func() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGCONT)
+	defer signal.Stop(sig)
+
+	_ = syscall.Kill(0, syscall.SIGSTOP)
+	<-sig
+}
+
+// listenForSuspend suspends the program on SIGTSTP. Catching the signal
+// keeps it from stopping the process right away, with the terminal still set
+// up for the program.
+func (p *Program) listenForSuspend() chan struct{} {
+	ch := make(chan struct{})
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTSTP)
+		defer func() {
+			signal.Stop(sig)
+			close(ch)
+		}()
+
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-sig:
+				if atomic.LoadUint32(&p.ignoreSignals) != 0 {
+					continue
+				}
+				p.Send(suspendMsg{})
+			}
+		}
+	}()
+
+	return ch
+}
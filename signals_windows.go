@@ -3,6 +3,20 @@
 
 package tea
 
+// suspendSupported reports whether the program can be suspended.
+const suspendSupported = false
+
+// suspendProcess is not available on windows, which has no job control.
+var suspendProcess = func() {}
+
+// listenForSuspend is not available on windows because windows does not
+// implement syscall.SIGTSTP.
+func (p *Program) listenForSuspend() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
 // listenForResize is not available on windows because windows does not
 // implement syscall.SIGWINCH.
 func (p *Program) listenForResize(done chan struct{}) {
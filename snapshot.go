@@ -0,0 +1,44 @@
+package tea
+
+// Snapshotter is implemented by models that can save and restore their state,
+// so that PushSnapshot and PopSnapshot can be used to undo changes.
+type Snapshotter interface {
+	// Snapshot returns a copy of the model's current state. It mustn't share
+	// anything mutable with the model, such as slices or maps, or changes to
+	// the model would change the snapshot too.
+	Snapshot() interface{}
+
+	// Restore returns the model with the state of a snapshot taken with
+	// Snapshot.
+	Restore(snapshot interface{}) Model
+}
+
+// PushSnapshot is a special command that takes a snapshot of the model and
+// pushes it onto a stack kept by the program. The model must implement
+// Snapshotter, otherwise the command does nothing. The snapshot reflects the
+// model as of when the command's message is handled, so it includes the
+// changes made while handling every message before it.
+//
+// Every snapshot on the stack is kept in memory until it's popped, so a deep
+// stack of snapshots of a big model can use a lot of it. Keep snapshots small,
+// for instance by only capturing the state that can be undone, or pop the
+// ones no longer needed.
+func PushSnapshot() Msg {
+	return pushSnapshotMsg{}
+}
+
+// pushSnapshotMsg is an internal message that signals to push a snapshot of
+// the model. You can send a pushSnapshotMsg with PushSnapshot.
+type pushSnapshotMsg struct{}
+
+// PopSnapshot is a special command that pops the latest snapshot taken with
+// PushSnapshot off the stack and restores the model to it, replacing the
+// active model with the one returned by Restore. The screen is then repainted
+// in full. If the stack is empty, the command does nothing.
+func PopSnapshot() Msg {
+	return popSnapshotMsg{}
+}
+
+// popSnapshotMsg is an internal message that signals to restore the model to
+// the latest snapshot. You can send a popSnapshotMsg with PopSnapshot.
+type popSnapshotMsg struct{}
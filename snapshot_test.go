@@ -0,0 +1,82 @@
+package tea
+
+import (
+	"bytes"
+	"testing"
+)
+
+type snapshotModel struct {
+	n    int
+	init Cmd
+}
+
+func (m snapshotModel) Init() Cmd {
+	return m.init
+}
+
+func (m snapshotModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(incrementMsg); ok {
+		m.n++
+	}
+	return m, nil
+}
+
+func (m snapshotModel) View() string {
+	return "view\n"
+}
+
+func (m snapshotModel) Snapshot() interface{} {
+	return m.n
+}
+
+func (m snapshotModel) Restore(snapshot interface{}) Model {
+	m.n = snapshot.(int)
+	return m
+}
+
+func TestSnapshots(t *testing.T) {
+	increment := func() Msg { return incrementMsg{} }
+
+	run := func(t *testing.T, m Model) Model {
+		var buf bytes.Buffer
+		var in bytes.Buffer
+
+		p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+		m, err := p.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	t.Run("push and pop", func(t *testing.T) {
+		m := run(t, snapshotModel{init: Sequence(
+			increment,
+			PushSnapshot,
+			increment,
+			PushSnapshot,
+			increment,
+			PopSnapshot,
+			PopSnapshot,
+			Quit,
+		)})
+		if n := m.(snapshotModel).n; n != 1 {
+			t.Errorf("expected the model to be restored to 1, got %d", n)
+		}
+	})
+
+	t.Run("empty stack", func(t *testing.T) {
+		m := run(t, snapshotModel{init: Sequence(increment, PopSnapshot, Quit)})
+		if n := m.(snapshotModel).n; n != 1 {
+			t.Errorf("expected the model to be left alone, got %d", n)
+		}
+	})
+
+	t.Run("not a snapshotter", func(t *testing.T) {
+		// Nothing to snapshot, nor to restore.
+		run(t, &testReportModel{
+			init: Sequence(PushSnapshot, PopSnapshot, Quit),
+			done: func(Msg) bool { return false },
+		})
+	})
+}
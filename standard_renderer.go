@@ -31,23 +31,74 @@ type standardRenderer struct {
 	buf                bytes.Buffer
 	queuedMessageLines []string
 	framerate          time.Duration
-	ticker             *time.Ticker
 	done               chan struct{}
 	lastRender         string
 	linesRendered      int
 	useANSICompressor  bool
 	once               sync.Once
 
+	// whether frames are only rendered on request, rather than on every tick
+	manualFlush bool
+
+	// the maximum size of a frame in bytes, if greater than zero
+	maxFrameSize int
+
+	// called after each frame is rendered, if set
+	onRender func(RenderStats)
+
+	// called once after the next flush
+	afterFlushFuncs []func()
+
+	// whether mode changes are being batched, during which the output is
+	// held back and frames aren't rendered
+	batching bool
+
+	// whether the next frame is a full repaint that was requested, and how
+	// many more requests it answers
+	repaintPending    bool
+	coalescedRepaints int
+
 	// cursor visibility state
 	cursorHidden bool
 
+	// where the cursor is placed after each frame, if cursorPositioned, and
+	// whether it's been moved there from where the last frame left it
+	cursorPositioned bool
+	cursorX, cursorY int
+	cursorMoved      bool
+
 	// essentially whether or not we're using the full size of the terminal
 	altScreenActive bool
 
+	// whether or not the screen colors are inverted
+	reverseVideoActive bool
+	cursorStyleCurrent cursorStyle
+
+	// whether or not the wheel sends arrow keys in the alternate screen
+	alternateScrollActive bool
+
+	// whether or not text written shifts the rest of the line right
+	insertModeActive bool
+
+	// whether runs of the same character are written with REP, which the
+	// terminal is known to support
+	repeat bool
+
+	// mouse tracking state, and the encodings requested whenever tracking is
+	// on: SGR (mode 1006), UTF-8 (mode 1005) and pixels (mode 1016)
+	mouseCellMotionActive bool
+	mouseAllMotionActive  bool
+	mouseSGR              bool
+	mouseUTF8             bool
+	mousePixels           bool
+
 	// renderer dimensions; usually the size of the window
 	width  int
 	height int
 
+	// the region of the terminal to render to, if it's not the whole of it
+	viewport region
+
 	// lines explicitly set not to render
 	ignoreLines map[int]struct{}
 }
@@ -61,6 +112,7 @@ func newRenderer(out *termenv.Output, useANSICompressor bool) renderer {
 		framerate:          defaultFramerate,
 		useANSICompressor:  useANSICompressor,
 		queuedMessageLines: []string{},
+		mouseSGR:           true,
 	}
 	if r.useANSICompressor {
 		r.out = termenv.NewOutput(&compressor.Writer{Forward: out})
@@ -68,13 +120,12 @@ func newRenderer(out *termenv.Output, useANSICompressor bool) renderer {
 	return r
 }
 
-// start starts the renderer.
+// start starts the renderer. A renderer that has been killed can be started
+// again.
 func (r *standardRenderer) start() {
-	if r.ticker == nil {
-		r.ticker = time.NewTicker(r.framerate)
-	}
 	r.done = make(chan struct{})
-	go r.listen()
+	r.once = sync.Once{}
+	go r.listen(time.NewTicker(r.framerate), r.done)
 }
 
 // stop permanently halts the renderer, rendering the final frame.
@@ -85,7 +136,10 @@ func (r *standardRenderer) stop() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	r.out.ClearLine()
+	r.returnCursor(r.out)
+	if !r.viewport.valid() {
+		r.out.ClearLine()
+	}
 	r.once.Do(func() {
 		close(r.done)
 	})
@@ -102,41 +156,107 @@ func (r *standardRenderer) kill() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
-	r.out.ClearLine()
+	r.returnCursor(r.out)
+	if !r.viewport.valid() {
+		r.out.ClearLine()
+	}
 	r.once.Do(func() {
 		close(r.done)
 	})
 }
 
-// listen waits for ticks on the ticker, or a signal to stop the renderer.
-func (r *standardRenderer) listen() {
+// listen waits for ticks on the ticker, or a signal to stop the renderer. Each
+// run of the renderer gets its own ticker and done channel, so a restarted
+// renderer can't be stopped by a listener that's still winding down.
+func (r *standardRenderer) listen(ticker *time.Ticker, done chan struct{}) {
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-r.ticker.C:
-			if r.ticker != nil {
+		case <-ticker.C:
+			if !r.manualFlush {
 				r.flush()
 			}
-		case <-r.done:
-			r.ticker.Stop()
-			r.ticker = nil
+		case <-done:
 			return
 		}
 	}
 }
 
+// batch calls fn, holding back what it writes to the output, and the frames
+// that would be rendered meanwhile, to write it all at once afterwards.
+func (r *standardRenderer) batch(fn func()) {
+	r.mtx.Lock()
+	out := r.out
+	var buf bytes.Buffer
+	r.out = termenv.NewOutput(&buf, termenv.WithProfile(out.Profile))
+	r.batching = true
+	r.mtx.Unlock()
+
+	fn()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.out = out
+	r.batching = false
+	_, _ = r.out.Write(buf.Bytes())
+}
+
+// afterFlush calls fn once the next flush is done.
+func (r *standardRenderer) afterFlush(fn func()) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.afterFlushFuncs = append(r.afterFlushFuncs, fn)
+}
+
+// runAfterFlush calls the functions waiting for a flush. The mutex must be
+// held.
+func (r *standardRenderer) runAfterFlush() {
+	fns := r.afterFlushFuncs
+	r.afterFlushFuncs = nil
+	for _, fn := range fns {
+		fn()
+	}
+}
+
 // flush renders the buffer.
 func (r *standardRenderer) flush() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	if r.batching {
+		// The frame is rendered on the next flush, after the batch.
+		return
+	}
+	defer r.runAfterFlush()
+
 	if r.buf.Len() == 0 || r.buf.String() == r.lastRender {
 		// Nothing to do
 		return
 	}
 
+	var start time.Time
+	if r.onRender != nil {
+		start = time.Now()
+	}
+	fullRepaint := r.lastRender == ""
+
 	// Output buffer
 	buf := &bytes.Buffer{}
 	out := termenv.NewOutput(buf)
+	r.returnCursor(out)
+
+	if r.viewport.valid() {
+		// There's nowhere to print lines above the program, as the
+		// viewport's surroundings aren't ours.
+		r.queuedMessageLines = nil
+
+		r.renderViewport(out)
+		r.placeCursor(out)
+		r.finishFlush(buf, start, fullRepaint)
+		return
+	}
 
 	newLines := strings.Split(r.buf.String(), "\n")
 
@@ -217,6 +337,9 @@ func (r *standardRenderer) flush() {
 			if r.width > 0 {
 				line = truncate.String(line, uint(r.width))
 			}
+			if r.repeat {
+				line = compressRuns(line)
+			}
 
 			_, _ = out.WriteString(line)
 
@@ -237,10 +360,120 @@ func (r *standardRenderer) flush() {
 	} else {
 		out.CursorBack(r.width)
 	}
+	r.placeCursor(out)
+
+	r.finishFlush(buf, start, fullRepaint)
+}
+
+// placeCursor moves the cursor to the position set with setCursorPosition, if
+// any, saving where the frame left it so that returnCursor can move it back.
+// In a viewport there's no need, as it's positioned absolutely. The mutex must
+// be held.
+func (r *standardRenderer) placeCursor(out *termenv.Output) {
+	if !r.cursorPositioned {
+		return
+	}
+	if !r.cursorMoved && !r.viewport.valid() {
+		_, _ = out.WriteString(saveCursorSeq)
+		r.cursorMoved = true
+	}
+	out.MoveCursor(r.cursorY+1, r.cursorX+1)
+}
+
+// returnCursor moves the cursor back to where the last frame left it, if
+// placeCursor moved it, as rendering and clearing depend on it being there.
+// The mutex must be held.
+func (r *standardRenderer) returnCursor(out *termenv.Output) {
+	if r.cursorMoved {
+		_, _ = out.WriteString(restoreCursorSeq)
+		r.cursorMoved = false
+	}
+}
 
+// finishFlush writes a rendered frame to the output and records it. The mutex
+// must be held.
+func (r *standardRenderer) finishFlush(buf *bytes.Buffer, start time.Time, fullRepaint bool) {
 	_, _ = r.out.Write(buf.Bytes())
 	r.lastRender = r.buf.String()
 	r.buf.Reset()
+
+	coalesced := r.coalescedRepaints
+	r.repaintPending = false
+	r.coalescedRepaints = 0
+
+	if r.onRender != nil {
+		r.onRender(RenderStats{
+			Duration:          time.Since(start),
+			Bytes:             buf.Len(),
+			FullRepaint:       fullRepaint,
+			CoalescedRepaints: coalesced,
+		})
+	}
+}
+
+// region is a rectangular part of the terminal, in cells. x and y are the
+// column and row of its top left corner, starting at zero.
+type region struct {
+	x, y          int
+	width, height int
+}
+
+// Control sequences saving and restoring the cursor position (DECSC and
+// DECRC).
+const (
+	saveCursorSeq    = "\x1b7"
+	restoreCursorSeq = "\x1b8"
+)
+
+// valid reports whether the region has an area.
+func (v region) valid() bool {
+	return v.width > 0 && v.height > 0
+}
+
+// renderViewport renders the buffer into the viewport. Each line is positioned
+// absolutely and padded to the viewport's width, so that everything outside
+// of it is left alone. Lines that haven't changed since the last frame are
+// skipped. The cursor is saved and restored, as it may be used by whoever
+// manages the rest of the screen. The mutex must be held.
+func (r *standardRenderer) renderViewport(out *termenv.Output) {
+	v := r.viewport
+	newLines := strings.Split(r.buf.String(), "\n")
+	var oldLines []string
+	if r.lastRender != "" {
+		oldLines = strings.Split(r.lastRender, "\n")
+	}
+
+	// The line at the given index of lines, or an empty one past the end.
+	lineAt := func(lines []string, i int) string {
+		if i < len(lines) {
+			return lines[i]
+		}
+		return ""
+	}
+
+	_, _ = out.WriteString(saveCursorSeq)
+	for i := 0; i < v.height; i++ {
+		line := lineAt(newLines, i)
+		if oldLines != nil && line == lineAt(oldLines, i) {
+			continue
+		}
+
+		line = truncate.String(line, uint(v.width))
+		if pad := v.width - CellWidth(line); pad > 0 {
+			if strings.Contains(line, "\x1b") {
+				// Don't let the line's styling bleed into the padding.
+				line += termenv.CSI + termenv.ResetSeq + "m"
+			}
+			line += strings.Repeat(" ", pad)
+		}
+		if r.repeat {
+			line = compressRuns(line)
+		}
+
+		out.MoveCursor(v.y+i+1, v.x+1)
+		_, _ = out.WriteString(line)
+	}
+	_, _ = out.WriteString(restoreCursorSeq)
 }
 
 // write writes to the internal buffer. The buffer will be outputted via the
@@ -258,10 +491,49 @@ func (r *standardRenderer) write(s string) {
 		s = " "
 	}
 
+	if r.maxFrameSize > 0 && len(s) > r.maxFrameSize {
+		s = truncateFrame(s, r.maxFrameSize)
+	}
+
 	_, _ = r.buf.WriteString(s)
 }
 
+// writeBytes is like write, but copies the frame from a byte slice into the
+// buffer, which saves allocating a string for it.
+func (r *standardRenderer) writeBytes(b []byte) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.buf.Reset()
+
+	switch {
+	case len(b) == 0:
+		_ = r.buf.WriteByte(' ')
+	case r.maxFrameSize > 0 && len(b) > r.maxFrameSize:
+		_, _ = r.buf.WriteString(truncateFrame(string(b), r.maxFrameSize))
+	default:
+		_, _ = r.buf.Write(b)
+	}
+}
+
+// truncateFrame cuts a frame exceeding the maximum frame size down to the
+// lines that fit and replaces the rest with a warning. Cutting at a line break
+// ensures we never cut through a rune or an escape sequence.
+func truncateFrame(s string, max int) string {
+	warning := fmt.Sprintf("[frame truncated: %d bytes exceeds the limit of %d]", len(s), max)
+	if i := strings.LastIndexByte(s[:max], '\n'); i >= 0 {
+		return s[:i+1] + warning
+	}
+	return warning
+}
+
+// repaint makes the next frame a full repaint. Requests made before that
+// frame is rendered are coalesced into it, so there's only ever one full
+// repaint per frame. The mutex must be held.
 func (r *standardRenderer) repaint() {
+	if r.repaintPending {
+		r.coalescedRepaints++
+	}
+	r.repaintPending = true
 	r.lastRender = ""
 }
 
@@ -269,12 +541,84 @@ func (r *standardRenderer) clearScreen() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	r.returnCursor(r.out)
+
 	r.out.ClearScreen()
 	r.out.MoveCursor(1, 1)
 
 	r.repaint()
 }
 
+// clearToEndOfScreen erases everything from the cursor to the end of the
+// screen. As this erases part of the last frame, the next frame is fully
+// repainted.
+func (r *standardRenderer) clearToEndOfScreen() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.returnCursor(r.out)
+
+	_, _ = r.out.WriteString(termenv.CSI + fmt.Sprintf(termenv.EraseDisplaySeq, 0))
+	r.repaint()
+}
+
+// clearToEndOfLine erases everything from the cursor to the end of the line.
+// As this erases part of the last frame, the next frame is fully repainted.
+func (r *standardRenderer) clearToEndOfLine() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.returnCursor(r.out)
+
+	r.out.ClearLineRight()
+	r.repaint()
+}
+
+// clearLine erases the line the cursor is on. As this erases part of the last
+// frame, the next frame is fully repainted.
+func (r *standardRenderer) clearLine() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.returnCursor(r.out)
+
+	r.out.ClearLine()
+	r.repaint()
+}
+
+// scrollUp scrolls the contents of the scrolling region up by n lines. As
+// this moves the last frame, the next frame is fully repainted.
+func (r *standardRenderer) scrollUp(n int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.returnCursor(r.out)
+
+	_, _ = r.out.WriteString(termenv.CSI + fmt.Sprintf(termenv.ScrollUpSeq, n))
+	r.repaint()
+}
+
+// scrollDown scrolls the contents of the scrolling region down by n lines. As
+// this moves the last frame, the next frame is fully repainted.
+func (r *standardRenderer) scrollDown(n int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.returnCursor(r.out)
+
+	_, _ = r.out.WriteString(termenv.CSI + fmt.Sprintf(termenv.ScrollDownSeq, n))
+	r.repaint()
+}
+
+// execute writes a sequence directly to the output. The mutex is held so the
+// sequence can't be interleaved with a frame.
+func (r *standardRenderer) execute(seq string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	_, _ = r.out.WriteString(seq)
+}
+
 func (r *standardRenderer) altScreen() bool {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -282,6 +626,49 @@ func (r *standardRenderer) altScreen() bool {
 	return r.altScreenActive
 }
 
+// resetTerminal soft resets the terminal, then re-applies the modes we track
+// and repaints.
+func (r *standardRenderer) resetTerminal() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.returnCursor(r.out)
+
+	_, _ = r.out.WriteString(softResetSeq)
+
+	if r.altScreenActive {
+		r.out.AltScreen()
+	}
+	if r.mouseCellMotionActive {
+		r.out.EnableMouseCellMotion()
+	}
+	if r.mouseAllMotionActive {
+		r.out.EnableMouseAllMotion()
+	}
+	if r.mouseCellMotionActive || r.mouseAllMotionActive {
+		r.setMouseEncoding(true)
+	}
+	if r.reverseVideoActive {
+		_, _ = r.out.WriteString(enableReverseVideoSeq)
+	}
+	if r.alternateScrollActive {
+		_, _ = r.out.WriteString(enableAlternateScrollSeq)
+	}
+	if r.insertModeActive {
+		_, _ = r.out.WriteString(enableInsertModeSeq)
+	}
+	if r.cursorStyleCurrent != cursorStyleDefault {
+		_, _ = r.out.WriteString(r.cursorStyleCurrent.sequence())
+	}
+	if r.cursorHidden {
+		r.out.HideCursor()
+	} else {
+		r.out.ShowCursor()
+	}
+
+	r.repaint()
+}
+
 func (r *standardRenderer) enterAltScreen() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -289,6 +676,7 @@ func (r *standardRenderer) enterAltScreen() {
 	if r.altScreenActive {
 		return
 	}
+	r.returnCursor(r.out)
 
 	r.altScreenActive = true
 	r.out.AltScreen()
@@ -321,6 +709,7 @@ func (r *standardRenderer) exitAltScreen() {
 	if !r.altScreenActive {
 		return
 	}
+	r.returnCursor(r.out)
 
 	r.altScreenActive = false
 	r.out.ExitAltScreen()
@@ -337,6 +726,86 @@ func (r *standardRenderer) exitAltScreen() {
 	r.repaint()
 }
 
+func (r *standardRenderer) reverseVideo() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.reverseVideoActive
+}
+
+func (r *standardRenderer) setReverseVideo(v bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.reverseVideoActive = v
+	if v {
+		_, _ = r.out.WriteString(enableReverseVideoSeq)
+	} else {
+		_, _ = r.out.WriteString(disableReverseVideoSeq)
+	}
+}
+
+func (r *standardRenderer) alternateScroll() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.alternateScrollActive
+}
+
+func (r *standardRenderer) setAlternateScroll(v bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.alternateScrollActive = v
+	if v {
+		_, _ = r.out.WriteString(enableAlternateScrollSeq)
+	} else {
+		_, _ = r.out.WriteString(disableAlternateScrollSeq)
+	}
+}
+
+// setRepeat sets whether runs of the same character are written with REP.
+func (r *standardRenderer) setRepeat(v bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.repeat = v
+}
+
+func (r *standardRenderer) insertMode() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.insertModeActive
+}
+
+func (r *standardRenderer) setInsertMode(v bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.insertModeActive = v
+	if v {
+		_, _ = r.out.WriteString(enableInsertModeSeq)
+	} else {
+		_, _ = r.out.WriteString(disableInsertModeSeq)
+	}
+}
+
+func (r *standardRenderer) cursorStyle() cursorStyle {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.cursorStyleCurrent
+}
+
+func (r *standardRenderer) setCursorStyle(s cursorStyle) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.cursorStyleCurrent = s
+	_, _ = r.out.WriteString(s.sequence())
+}
+
 func (r *standardRenderer) showCursor() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -353,32 +822,147 @@ func (r *standardRenderer) hideCursor() {
 	r.out.HideCursor()
 }
 
+// setCursorPosition moves the cursor to the given cell and keeps it there
+// after every frame. A negative coordinate leaves the cursor where frames
+// leave it again.
+func (r *standardRenderer) setCursorPosition(x, y int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.positionCursor(x, y)
+}
+
+// syncCursor shows the cursor at the given cell, or hides it and leaves it
+// where frames leave it. Unlike showCursor, hideCursor and setCursorPosition,
+// it only writes what changed, so it can be called for every frame.
+func (r *standardRenderer) syncCursor(visible bool, x, y int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !visible {
+		x, y = -1, -1
+	}
+	positioned := x >= 0 && y >= 0
+	if positioned != r.cursorPositioned || positioned && (x != r.cursorX || y != r.cursorY) {
+		r.positionCursor(x, y)
+	}
+
+	if visible == r.cursorHidden {
+		r.cursorHidden = !visible
+		if visible {
+			r.out.ShowCursor()
+		} else {
+			r.out.HideCursor()
+		}
+	}
+}
+
+// positionCursor implements setCursorPosition. The mutex must be held.
+func (r *standardRenderer) positionCursor(x, y int) {
+	if x < 0 || y < 0 {
+		r.returnCursor(r.out)
+		r.cursorPositioned = false
+		return
+	}
+
+	r.cursorPositioned = true
+	r.cursorX, r.cursorY = x, y
+	r.placeCursor(r.out)
+}
+
+func (r *standardRenderer) mouseCellMotionEnabled() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.mouseCellMotionActive
+}
+
+func (r *standardRenderer) mouseAllMotionEnabled() bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.mouseAllMotionActive
+}
+
 func (r *standardRenderer) enableMouseCellMotion() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	// The mouse modes are mutually exclusive. Switch off the other one first
+	// so the terminal doesn't end up with both enabled.
+	if r.mouseAllMotionActive {
+		r.mouseAllMotionActive = false
+		r.out.DisableMouseAllMotion()
+	}
+
+	r.mouseCellMotionActive = true
 	r.out.EnableMouseCellMotion()
+	r.setMouseEncoding(true)
 }
 
 func (r *standardRenderer) disableMouseCellMotion() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	wasActive := r.mouseCellMotionActive
+	r.mouseCellMotionActive = false
 	r.out.DisableMouseCellMotion()
+	if wasActive && !r.mouseAllMotionActive {
+		r.setMouseEncoding(false)
+	}
 }
 
 func (r *standardRenderer) enableMouseAllMotion() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	// See enableMouseCellMotion.
+	if r.mouseCellMotionActive {
+		r.mouseCellMotionActive = false
+		r.out.DisableMouseCellMotion()
+	}
+
+	r.mouseAllMotionActive = true
 	r.out.EnableMouseAllMotion()
+	r.setMouseEncoding(true)
 }
 
 func (r *standardRenderer) disableMouseAllMotion() {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
+	wasActive := r.mouseAllMotionActive
+	r.mouseAllMotionActive = false
 	r.out.DisableMouseAllMotion()
+	if wasActive && !r.mouseCellMotionActive {
+		r.setMouseEncoding(false)
+	}
+}
+
+// setMouseEncoding switches the requested encodings of mouse coordinates,
+// SGR, UTF-8 and pixels, on or off. The mutex must be held.
+func (r *standardRenderer) setMouseEncoding(on bool) {
+	if r.mouseSGR {
+		if on {
+			_, _ = r.out.WriteString(enableMouseSGRSeq)
+		} else {
+			_, _ = r.out.WriteString(disableMouseSGRSeq)
+		}
+	}
+	if r.mouseUTF8 {
+		if on {
+			_, _ = r.out.WriteString(enableMouseUTF8Seq)
+		} else {
+			_, _ = r.out.WriteString(disableMouseUTF8Seq)
+		}
+	}
+	if r.mousePixels {
+		if on {
+			_, _ = r.out.WriteString(enableMousePixelsSeq)
+		} else {
+			_, _ = r.out.WriteString(disableMousePixelsSeq)
+		}
+	}
 }
 
 // setIgnoredLines specifies lines not to be touched by the standard Bubble Tea
@@ -454,6 +1038,8 @@ func (r *standardRenderer) insertTop(lines []string, topBoundary, bottomBoundary
 
 	// Move cursor back to where the main rendering routine expects it to be
 	out.MoveCursor(r.linesRendered, 0)
+	r.cursorMoved = false
+	r.placeCursor(out)
 
 	_, _ = r.out.Write(buf.Bytes())
 }
@@ -481,6 +1067,8 @@ func (r *standardRenderer) insertBottom(lines []string, topBoundary, bottomBound
 
 	// Move cursor back to where the main rendering routine expects it to be
 	out.MoveCursor(r.linesRendered, 0)
+	r.cursorMoved = false
+	r.placeCursor(out)
 
 	_, _ = r.out.Write(buf.Bytes())
 }
@@ -488,9 +1076,9 @@ func (r *standardRenderer) insertBottom(lines []string, topBoundary, bottomBound
 // handleMessages handles internal messages for the renderer.
 func (r *standardRenderer) handleMessages(msg Msg) {
 	switch msg := msg.(type) {
-	case repaintMsg:
+	case repaintMsg, forceRenderMsg, popSnapshotMsg:
 		// Force a repaint by clearing the render cache as we slide into a
-		// render.
+		// render. Restoring a snapshot can change the whole view.
 		r.mtx.Lock()
 		r.repaint()
 		r.mtx.Unlock()
@@ -0,0 +1,471 @@
+package tea
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/muesli/termenv"
+)
+
+func TestStandardRendererManualFlush(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+	r.manualFlush = true
+	r.start()
+	defer r.kill()
+
+	r.write("first")
+	r.write("second")
+	time.Sleep(defaultFramerate * 3)
+
+	r.mtx.Lock()
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be rendered before flushing, got %q", buf.String())
+	}
+	r.mtx.Unlock()
+
+	r.flush()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if buf.String() != "second\x1b[0D" {
+		t.Errorf("expected only the last frame to be rendered, got %q", buf.String())
+	}
+}
+
+func TestStandardRendererRepaint(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+	r.write("frame")
+	r.flush()
+
+	// Rendering the same frame again is a no-op...
+	buf.Reset()
+	r.write("frame")
+	r.flush()
+	if buf.Len() != 0 {
+		t.Fatalf("expected unchanged frame not to be rendered, got %q", buf.String())
+	}
+
+	// ...unless a repaint was requested.
+	r.handleMessages(Repaint())
+	r.write("frame")
+	r.flush()
+	if !bytes.Contains(buf.Bytes(), []byte("frame")) {
+		t.Errorf("expected frame to be repainted, got %q", buf.String())
+	}
+}
+
+func TestStandardRendererMaxFrameSize(t *testing.T) {
+	tt := []struct {
+		name     string
+		frame    string
+		expected string
+	}{
+		{
+			name:     "within limit",
+			frame:    "one\ntwo",
+			expected: "one\ntwo",
+		},
+		{
+			name:     "cut at last line",
+			frame:    "one\ntwo\nthree",
+			expected: "one\ntwo\n[frame truncated: 13 bytes exceeds the limit of 10]",
+		},
+		{
+			name:     "single long line",
+			frame:    "onetwothree",
+			expected: "[frame truncated: 11 bytes exceeds the limit of 10]",
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+			r.maxFrameSize = 10
+			r.write(tc.frame)
+
+			if r.buf.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, r.buf.String())
+			}
+		})
+	}
+}
+
+func TestStandardRendererMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	var stats []RenderStats
+
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+	r.onRender = func(s RenderStats) {
+		stats = append(stats, s)
+	}
+
+	r.write("one\ntwo")
+	r.flush()
+	r.write("one\nthree")
+	r.flush()
+	r.write("one\nthree")
+	r.flush()
+
+	if len(stats) != 2 {
+		t.Fatalf("expected stats for 2 frames, got %d", len(stats))
+	}
+	if !stats[0].FullRepaint || stats[1].FullRepaint {
+		t.Errorf("expected only the first frame to be a full repaint, got %+v", stats)
+	}
+
+	var total int
+	for _, s := range stats {
+		total += s.Bytes
+	}
+	if total != buf.Len() {
+		t.Errorf("expected %d bytes to be reported, got %d", buf.Len(), total)
+	}
+}
+
+func TestStandardRendererCoalescedRepaints(t *testing.T) {
+	var buf bytes.Buffer
+	var stats []RenderStats
+
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+	r.onRender = func(s RenderStats) {
+		stats = append(stats, s)
+	}
+
+	r.write("one\ntwo")
+	r.flush()
+
+	// Three repaints requested before the next frame make a single full
+	// repaint.
+	for i := 0; i < 3; i++ {
+		r.handleMessages(Repaint())
+	}
+	buf.Reset()
+	r.write("one\ntwo")
+	r.flush()
+	if n := strings.Count(buf.String(), "one"); n != 1 {
+		t.Errorf("expected the frame to be repainted once, got %q", buf.String())
+	}
+
+	// The count starts over with the next frame.
+	r.handleMessages(Repaint())
+	r.write("one\ntwo")
+	r.flush()
+
+	if len(stats) != 3 {
+		t.Fatalf("expected stats for 3 frames, got %d", len(stats))
+	}
+	for i, expected := range []int{0, 2, 0} {
+		if !stats[i].FullRepaint {
+			t.Errorf("expected frame %d to be a full repaint", i)
+		}
+		if stats[i].CoalescedRepaints != expected {
+			t.Errorf("expected frame %d to coalesce %d repaints, got %d", i, expected, stats[i].CoalescedRepaints)
+		}
+	}
+}
+
+func TestForceRender(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{
+		init: Sequence(Flush, ForceRender(), Quit),
+		done: func(Msg) bool { return false },
+	}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithManualFlush())
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flushing an unchanged frame is a no-op, forcing a render isn't.
+	if n := bytes.Count(buf.Bytes(), []byte("success")); n != 2 {
+		t.Errorf("expected the frame to be rendered twice, got %d times in %q", n, buf.String())
+	}
+}
+
+func TestStandardRendererViewport(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+	r.viewport = region{x: 2, y: 1, width: 5, height: 2}
+
+	r.write("hello world\nab\ndropped")
+	r.flush()
+	if expected := "\x1b7\x1b[2;3Hhello\x1b[3;3Hab   \x1b8"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// Only lines that changed are redrawn.
+	buf.Reset()
+	r.write("hello world\n\x1b[1mcd\x1b[0m")
+	r.flush()
+	if expected := "\x1b7\x1b[3;3H\x1b[1mcd\x1b[0m\x1b[0m   \x1b8"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// Lines that are gone are blanked.
+	buf.Reset()
+	r.write("hello world")
+	r.flush()
+	if expected := "\x1b7\x1b[3;3H     \x1b8"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestViewportWindowSize(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(WindowSizeMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithViewport(10, 5, 30, 8))
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var size Msg
+	for _, msg := range m.msgs {
+		if _, ok := msg.(WindowSizeMsg); ok {
+			size = msg
+		}
+	}
+	if size != (WindowSizeMsg{Width: 30, Height: 8}) {
+		t.Fatalf("expected the viewport's size to be reported, got %#v", size)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("\x1b[6;11Hsuccess")) {
+		t.Fatalf("expected the view to be rendered in the viewport, got %q", buf.String())
+	}
+}
+
+func TestStandardRendererCursorPosition(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+	r.write("one\ntwo")
+	r.flush()
+
+	// The cursor is moved right away, saving where the frame left it...
+	buf.Reset()
+	r.setCursorPosition(2, 1)
+	if got := buf.String(); got != "\x1b7\x1b[2;3H" {
+		t.Errorf("expected the cursor to be saved and moved, got %q", got)
+	}
+
+	// ...and returned there to render the next frame, after which it's placed
+	// again.
+	buf.Reset()
+	r.write("one\nthree")
+	r.flush()
+	got := buf.String()
+	if !strings.HasPrefix(got, "\x1b8") {
+		t.Errorf("expected the cursor to be returned before rendering, got %q", got)
+	}
+	if !strings.HasSuffix(got, "\x1b7\x1b[2;3H") {
+		t.Errorf("expected the cursor to be placed after rendering, got %q", got)
+	}
+
+	// Moving it again doesn't save the position it was placed at.
+	buf.Reset()
+	r.setCursorPosition(4, 0)
+	if got := buf.String(); got != "\x1b[1;5H" {
+		t.Errorf("expected the cursor to be moved, got %q", got)
+	}
+
+	buf.Reset()
+	r.setCursorPosition(-1, -1)
+	r.write("one\nfour")
+	r.flush()
+	got = buf.String()
+	if !strings.HasPrefix(got, "\x1b8") || strings.Contains(got, "\x1b7") {
+		t.Errorf("expected the cursor to be returned and left alone, got %q", got)
+	}
+}
+
+func TestStandardRendererSyncCursor(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+	r.write("one\ntwo")
+	r.flush()
+
+	for _, step := range []struct {
+		visible  bool
+		x, y     int
+		expected string
+	}{
+		{true, 2, 1, "\x1b7\x1b[2;3H"},
+		{true, 2, 1, ""},
+		{false, 2, 1, "\x1b8\x1b[?25l"},
+		{false, 0, 0, ""},
+		{true, 1, 0, "\x1b7\x1b[1;2H\x1b[?25h"},
+		{true, 0, 0, "\x1b[1;1H"},
+	} {
+		buf.Reset()
+		r.syncCursor(step.visible, step.x, step.y)
+		if got := buf.String(); got != step.expected {
+			t.Errorf("syncCursor(%v, %d, %d): expected %q, got %q", step.visible, step.x, step.y, step.expected, got)
+		}
+	}
+}
+
+func TestStandardRendererTruncatesWideLines(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+	r.handleMessages(WindowSizeMsg{Width: 5, Height: 10})
+	r.write("\x1b[1mhello world\x1b[0m\nab\x1b[31mcdefgh\x1b[0m\n日本語テキスト\nshort")
+	r.flush()
+
+	// Styles are kept, and a wide rune that doesn't fit is dropped whole.
+	for _, line := range []string{
+		"\x1b[1mhello\x1b[0m\r\n",
+		"ab\x1b[31mcde\x1b[0m\r\n",
+		"日本\r\n",
+		"short",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("expected %q in the output, got %q", line, buf.String())
+		}
+	}
+}
+
+func TestStandardRendererAfterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+
+	var calls int
+	r.afterFlush(func() { calls++ })
+	r.write("hello")
+	if calls != 0 {
+		t.Fatalf("expected no call before flushing, got %d", calls)
+	}
+	r.flush()
+	if calls != 1 || !strings.Contains(buf.String(), "hello") {
+		t.Fatalf("expected a call once the frame was written, got %d with %q", calls, buf.String())
+	}
+
+	// An unchanged frame still counts, and each function is called once.
+	r.afterFlush(func() { calls++ })
+	r.flush()
+	r.flush()
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+// afterRenderModel shows a stage, and moves on to the next one each time the
+// last one has been rendered, recording what was on screen by then. The last
+// stage looks the same as the one before it.
+type afterRenderModel struct {
+	stage  int
+	screen func() string
+	seen   []string
+}
+
+type renderedMsg string
+
+func (m *afterRenderModel) rendered() Msg {
+	return renderedMsg(m.screen())
+}
+
+func (m *afterRenderModel) Init() Cmd {
+	return AfterRender(m.rendered)
+}
+
+func (m *afterRenderModel) Update(msg Msg) (Model, Cmd) {
+	if msg, ok := msg.(renderedMsg); ok {
+		m.seen = append(m.seen, string(msg))
+		switch m.stage {
+		case 0:
+			m.stage++
+			return m, AfterRender(m.rendered)
+		case 1:
+			m.stage++
+			return m, Batch(AfterRender(m.rendered), Barrier("done"))
+		}
+	}
+	if _, ok := msg.(BarrierMsg); ok {
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m *afterRenderModel) View() string {
+	if m.stage > 1 {
+		return "stage 1\n"
+	}
+	return fmt.Sprintf("stage %d\n", m.stage)
+}
+
+func TestAfterRender(t *testing.T) {
+	var mtx sync.Mutex
+	var screen bytes.Buffer
+	tap := func(p []byte, _ time.Time) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		screen.Write(p)
+	}
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	m := &afterRenderModel{screen: func() string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return screen.String()
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithOutputTap(tap))
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.seen) != 3 {
+		t.Fatalf("expected 3 renders, got %q", m.seen)
+	}
+	for i, want := range []string{"stage 0", "stage 1", "stage 1"} {
+		if !strings.Contains(m.seen[i], want) {
+			t.Errorf("expected %q on screen after render %d, got %q", want, i, m.seen[i])
+		}
+	}
+	if strings.Contains(m.seen[0], "stage 1") {
+		t.Errorf("expected stage 1 not to be on screen yet, got %q", m.seen[0])
+	}
+}
+
+func TestStandardRendererBatch(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRenderer(termenv.NewOutput(&buf), false).(*standardRenderer)
+
+	r.batch(func() {
+		r.hideCursor()
+		r.write("frame")
+		r.flush()
+		r.setReverseVideo(true)
+		if buf.Len() != 0 {
+			t.Errorf("expected nothing to be written during the batch, got %q", buf.String())
+		}
+	})
+	if buf.String() != "\x1b[?25l\x1b[?5h" {
+		t.Errorf("expected the mode changes to be written together, got %q", buf.String())
+	}
+
+	// The frame held back is rendered on the next flush.
+	r.flush()
+	if !strings.Contains(buf.String(), "frame") {
+		t.Errorf("expected the frame to be rendered after the batch, got %q", buf.String())
+	}
+}
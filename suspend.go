@@ -0,0 +1,50 @@
+package tea
+
+// suspendMsg is an internal message that suspends the program. You can send
+// a suspendMsg with Suspend, and the program sends one itself on SIGTSTP.
+type suspendMsg struct{}
+
+// SuspendMsg is sent to Update right before the program is suspended, so it
+// can save anything it needs to. The view it renders is the last one seen
+// before the suspension.
+type SuspendMsg struct{}
+
+// ResumeMsg is sent to Update once the program has been resumed, and the
+// terminal has been set up again and repainted.
+type ResumeMsg struct{}
+
+// Suspend is a command that suspends the program to the background, like
+// ctrl+z does in a shell. The terminal is restored beforehand, as it is when
+// quitting, so the shell is usable, and set up again when the program is
+// resumed with fg. Update gets a SuspendMsg before the suspension and a
+// ResumeMsg after it.
+//
+// The program also suspends this way on SIGTSTP, unless signal handling is
+// disabled with WithoutSignalHandler. Note that while the terminal is in raw
+// mode, ctrl+z doesn't send SIGTSTP but arrives as a key, so return Suspend
+// when you get it:
+//
+//	case tea.KeyMsg:
+//	    if msg.Type == tea.KeyCtrlZ {
+//	        return m, tea.Suspend
+//	    }
+//
+// Suspending isn't supported on Windows, where Suspend does nothing.
+func Suspend() Msg {
+	return suspendMsg{}
+}
+
+// suspend restores the terminal, suspends the process and, once it's
+// resumed, sets the terminal up again.
+func (p *Program) suspend() {
+	p.renderer.flush()
+	if err := p.ReleaseTerminal(); err != nil {
+		// If we can't release the terminal, we'd better not suspend.
+		return
+	}
+
+	suspendProcess()
+
+	_ = p.RestoreTerminal()
+	go p.Send(ResumeMsg{})
+}
@@ -0,0 +1,58 @@
+package tea
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuspend(t *testing.T) {
+	if !suspendSupported {
+		t.Skip("suspending isn't supported on this platform")
+	}
+
+	var suspended bool
+	defer func(f func()) { suspendProcess = f }(suspendProcess)
+	suspendProcess = func() { suspended = true }
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{
+		init: Suspend,
+		done: func(msg Msg) bool {
+			_, ok := msg.(ResumeMsg)
+			return ok
+		},
+	}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithAltScreen())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !suspended {
+		t.Fatal("expected the process to be suspended")
+	}
+
+	var msgs []Msg
+	for _, msg := range m.msgs {
+		switch msg.(type) {
+		case SuspendMsg, ResumeMsg:
+			msgs = append(msgs, msg)
+		}
+	}
+	if expected := []Msg{SuspendMsg{}, ResumeMsg{}}; !reflect.DeepEqual(msgs, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, msgs)
+	}
+
+	// The alt screen is left for the suspension and entered again after.
+	out := buf.String()
+	if n := strings.Count(out, "\x1b[?1049h"); n != 2 {
+		t.Errorf("expected the alt screen to be entered twice, got %d times in %q", n, out)
+	}
+	if n := strings.Count(out, "\x1b[?1049l"); n != 2 {
+		t.Errorf("expected the alt screen to be exited twice, got %d times in %q", n, out)
+	}
+}
@@ -10,6 +10,7 @@
 package tea
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -17,8 +18,11 @@ import (
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/containerd/console"
 	isatty "github.com/mattn/go-isatty"
@@ -29,6 +33,10 @@ import (
 // ErrProgramKilled is returned by [Program.Run] when the program got killed.
 var ErrProgramKilled = errors.New("program was killed")
 
+// ErrNilModel is returned by [Program.Run] when Update returns a nil model.
+// The model returned along with it is the last one that wasn't nil.
+var ErrNilModel = errors.New("update returned a nil model")
+
 // Msg contain data from the result of a IO operation. Msgs trigger the update
 // function and, henceforth, the UI.
 type Msg interface{}
@@ -37,6 +45,11 @@ type Msg interface{}
 type Model interface {
 	// Init is the first function that will be called. It returns an optional
 	// initial command. To not perform an initial command return nil.
+	//
+	// The initial command may include special commands such as
+	// EnterAltScreen or EnableMouseCellMotion. Like any other command's
+	// messages, they're only applied by the event loop, after the renderer has
+	// started.
 	Init() Cmd
 
 	// Update is called when a message is received. Use it to inspect messages
@@ -44,10 +57,34 @@ type Model interface {
 	Update(Msg) (Model, Cmd)
 
 	// View renders the program's UI, which is just a string. The view is
-	// rendered after every Update.
+	// rendered after every Update. Lines wider than the terminal are
+	// truncated to its width rather than wrapped, which would throw off
+	// rendering; styles and wide runes are taken into account.
 	View() string
 }
 
+// CursorModel is an optional interface for models that control the cursor,
+// such as text editors. After every update the program calls Cursor, and
+// shows the cursor at the column and row of the terminal it returns, counting
+// from zero, or hides it if visible is false. This is best used in the
+// alternate screen, where the view starts at the top left of the terminal.
+//
+// This keeps the cursor in sync with the view without commands like
+// ShowCursor and SetCursorPosition, which it overrides. Models that don't
+// implement it leave the cursor alone, which is hidden by default.
+type CursorModel interface {
+	Cursor() (visible bool, x, y int)
+}
+
+// Renderable is an optional interface for models with large views. Instead
+// of View, the program calls Render, which writes the view to the given
+// writer. This saves building a string for every frame, which for big views
+// adds up. The view written is the same as View's would be, and View is still
+// required to satisfy Model, though it's not called.
+type Renderable interface {
+	Render(w io.Writer)
+}
+
 // Cmd is an IO operation that returns a message when it's complete. If it's
 // nil it's considered a no-op. Use it for things like HTTP requests, timers,
 // saving and loading from disk, and so on.
@@ -63,7 +100,7 @@ type handlers []chan struct{}
 // generally set with ProgramOptions.
 //
 // The options here are treated as bits.
-type startupOptions byte
+type startupOptions uint32
 
 func (s startupOptions) has(option startupOptions) bool {
 	return s&option != 0
@@ -83,9 +120,35 @@ const (
 	// recover from panics, print the stack trace, and disable raw mode. This
 	// feature is on by default.
 	withoutCatchPanics
+
+	withReportScroll
+	withoutCtrlCQuit
+	withManualFlush
+	withMouseUTF8
+	withDECLocator
+	withMousePixels
+	withoutANSI
+	withStartPaused
+	withRawInput
+	withRawInputOnly
+	withShownCursor
+	withMouseHighlight
+	withBulkInputDetection
+	withPasteNormalization
+	withRepeatCompression
+	withAltScreenIfSupported
+	withInBandResize
 )
 
 // Program is a terminal user interface.
+//
+// Programs don't share any state, so several of them can run concurrently in
+// the same process, provided each one is given its own input and output via
+// WithInput and WithOutput. A terminal, however, can only be owned by a
+// single Program at a time: when running more than one Program, at most one
+// of them should use the default stdin and stdout (or WithInputTTY). Note
+// that signals such as SIGINT are delivered to the whole process, so every
+// running Program with a signal handler will react to them.
 type Program struct {
 	initialModel Model
 
@@ -99,20 +162,145 @@ type Program struct {
 	msgs chan Msg
 	errs chan error
 
+	// how many messages can be queued before Send blocks.
+	msgBufferSize int
+
 	// where to send output, this will usually be os.Stdout.
 	output        *termenv.Output
 	restoreOutput func() error
 	renderer      renderer
 
+	// guards setting the renderer in Run against MouseMode, which may be
+	// called from any goroutine.
+	rendererMtx sync.RWMutex
+
 	// where to read inputs from, this will usually be os.Stdin.
 	input        io.Reader
 	cancelReader cancelreader.CancelReader
 	readLoopDone chan struct{}
 	console      console.Console
 
+	// the environment to use instead of the process environment, if set.
+	environ environ
+
+	// the maximum size of a frame in bytes, if greater than zero.
+	maxFrameSize int
+
+	// the region of the terminal to render to, if not the whole of it.
+	viewport region
+
+	// queries the size of the terminal instead of the output, if set.
+	windowSizeFunc func() (int, int, error)
+
+	// turns input sequences we don't recognize into messages, if set.
+	unknownSequenceHandler func([]byte) Msg
+
+	// receives a dump of every raw input buffer, if set.
+	inputDebug io.Writer
+
+	// how long to wait for the rest of an escape sequence cut off at the end
+	// of a read, if greater than zero.
+	inputReadTimeout time.Duration
+
+	// called after each frame is rendered, if set.
+	renderMetrics func(RenderStats)
+
+	// called with everything the renderer writes, if set.
+	outputTap func([]byte, time.Time)
+
+	// drops the mouse events the program isn't interested in, if set.
+	mouseFilter *mouseFilter
+
+	// how long without input before an IdleMsg is sent, if greater than
+	// zero.
+	idleTimeout time.Duration
+
+	// wraps the model at startup, outermost first.
+	middleware []func(Model) Model
+
+	// why the terminal couldn't be put in raw mode by initInput, if it
+	// couldn't.
+	rawModeErr error
+
+	// the last size reported to Update, for RequestWindowSize to fall back
+	// on when the size can't be queried; only accessed by the event loop.
+	lastWindowSize WindowSizeMsg
+
+	// limits the rate of mouse motion events, if set.
+	mouseMotionThrottle *mouseMotionThrottle
+
+	// groups wheel events into scroll gestures, if set.
+	scrollGrouper *scrollGrouper
+
+	// tracks which hover region the mouse pointer is in.
+	hover hoverTracker
+
+	// closed once the program may start, see WithStartPaused.
+	resumed    chan struct{}
+	resumeOnce sync.Once
+
+	// messages sent while the program is paused, and until they've all been
+	// passed on to the event loop after resuming.
+	pausedMtx  sync.Mutex
+	paused     bool
+	pausedMsgs []Msg
+
+	// the number of commands run whose message hasn't been handled yet, and
+	// the tags of the barriers waiting for it to drop to zero. Only accessed
+	// by the event loop.
+	cmdsInFlight int
+	barriers     []string
+
+	// the tags of the cancellable commands running and not cancelled. Only
+	// accessed by the event loop.
+	cancellable map[CmdTag]struct{}
+
+	// the snapshots pushed with PushSnapshot, latest last. Only accessed by
+	// the event loop.
+	snapshots []interface{}
+
+	// when the renderer started, and when Run returned after that, if it did.
+	runTimeMtx sync.Mutex
+	startedAt  time.Time
+	stoppedAt  time.Time
+
+	// functions registered with OnExit, run in reverse order on teardown.
+	exitFuncsMtx sync.Mutex
+	exitFuncs    []func()
+
 	// was the altscreen active before releasing the terminal?
 	altScreenWasActive bool
-	ignoreSignals      bool
+
+	// which terminal modes were active before releasing the terminal, so
+	// they can be re-applied when it's restored.
+	mouseCellMotionWasActive bool
+	mouseAllMotionWasActive  bool
+	reverseVideoWasActive    bool
+	alternateScrollWasActive bool
+	insertModeWasActive      bool
+	cursorStyleWas           cursorStyle
+
+	// whether to ignore signals while the terminal is released; accessed
+	// atomically as it's read by the signal handler goroutine.
+	ignoreSignals uint32
+
+	// buffer for the views of models implementing Renderable, reused from
+	// frame to frame
+	frame bytes.Buffer
+
+	// whether the program was stopped with Kill, in which case the terminal
+	// modes aren't restored; accessed atomically.
+	killed uint32
+
+	// whether the input and the output are terminals, as detected when the
+	// program started; accessed atomically.
+	inputIsTTY  uint32
+	outputIsTTY uint32
+
+	// device attributes and terminal version queries in flight, so that
+	// queries unresponsive terminals never reply to can be timed out
+	deviceAttributes queryTracker
+	terminalVersion  queryTracker
 
 	// Stores the original reference to stdin for cases where input is not a
 	// TTY on windows and we've automatically opened CONIN$ to receive input.
@@ -138,7 +326,6 @@ func NewProgram(model Model, opts ...ProgramOption) *Program {
 	p := &Program{
 		initialModel: model,
 		input:        os.Stdin,
-		msgs:         make(chan Msg),
 	}
 
 	// Apply all options to the program.
@@ -146,6 +333,18 @@ func NewProgram(model Model, opts ...ProgramOption) *Program {
 		opt(p)
 	}
 
+	if p.msgBufferSize < 0 {
+		p.msgBufferSize = 0
+	}
+	p.msgs = make(chan Msg, p.msgBufferSize)
+
+	p.resumed = make(chan struct{})
+	if p.startupOptions.has(withStartPaused) {
+		p.paused = true
+	} else {
+		p.Resume()
+	}
+
 	// A context can be provided with a ProgramOption, but if none was provided
 	// we'll use the default background context.
 	if p.ctx == nil {
@@ -162,6 +361,21 @@ func NewProgram(model Model, opts ...ProgramOption) *Program {
 		termenv.WithColorCache(true)(p.output)
 	}
 
+	// If an environment was provided, detect the output's capabilities
+	// against it rather than against the process environment.
+	if p.environ != nil {
+		// Outputs which aren't files can't be terminals, so it's fine to
+		// wrap those rather than unwrapping them.
+		var w io.Writer = p.output
+		if f := p.output.TTY(); f != nil {
+			w = f
+		}
+		p.output = termenv.NewOutput(w,
+			termenv.WithEnvironment(p.environ),
+			termenv.WithColorCache(true),
+		)
+	}
+
 	p.restoreOutput, _ = termenv.EnableVirtualTerminalProcessing(p.output)
 
 	return p
@@ -175,7 +389,8 @@ func (p *Program) handleSignals() chan struct{} {
 	// In most cases ^C will not send an interrupt because the terminal will be
 	// in raw mode and ^C will be captured as a keystroke and sent along to
 	// Program.Update as a KeyMsg. When input is not a TTY, however, ^C will be
-	// caught here.
+	// caught here. With WithoutCtrlCQuit it's then forwarded to Update as a
+	// KeyMsg, too.
 	//
 	// SIGTERM is sent by unix utilities (like kill) to terminate a process.
 	go func() {
@@ -191,11 +406,17 @@ func (p *Program) handleSignals() chan struct{} {
 			case <-p.ctx.Done():
 				return
 
-			case <-sig:
-				if !p.ignoreSignals {
-					p.msgs <- quitMsg{}
-					return
+			case s := <-sig:
+				if atomic.LoadUint32(&p.ignoreSignals) != 0 {
+					continue
 				}
+				if s == syscall.SIGINT && p.startupOptions.has(withoutCtrlCQuit) {
+					// Deliver ^C to Update like a regular keypress.
+					p.Send(KeyMsg{Type: KeyCtrlC})
+					continue
+				}
+				p.msgs <- quitMsg{}
+				return
 			}
 		}
 	}()
@@ -203,11 +424,63 @@ func (p *Program) handleSignals() chan struct{} {
 	return ch
 }
 
+// isTerminal reports whether v is a file that's a terminal.
+func isTerminal(v interface{}) bool {
+	f, ok := v.(*os.File)
+	return ok && isatty.IsTerminal(f.Fd())
+}
+
+// altScreenSupported reports whether the output is a terminal known to
+// support the alternate screen buffer.
+func (p *Program) altScreenSupported() bool {
+	return isTerminal(p.output.TTY()) && !p.dumbTerminal() && terminalHasAltScreen(p.getenv)
+}
+
+// dumbTerminal reports whether the output is a terminal that doesn't support
+// escape sequences.
+func (p *Program) dumbTerminal() bool {
+	return isTerminal(p.output.TTY()) && p.getenv("TERM") == "dumb"
+}
+
+// requestWindowSize sends the current size in a WindowSizeMsg, or the last
+// known size if it can't be queried, without blocking.
+func (p *Program) requestWindowSize() {
+	switch {
+	case p.viewport.valid():
+		go p.Send(WindowSizeMsg{Width: p.viewport.width, Height: p.viewport.height})
+	case p.canQuerySize():
+		go p.checkResize()
+	case p.lastWindowSize.Width > 0:
+		go p.Send(p.lastWindowSize)
+	}
+}
+
+// canQuerySize reports whether the size of the output can be queried.
+func (p *Program) canQuerySize() bool {
+	if p.windowSizeFunc != nil {
+		return true
+	}
+	return isTerminal(p.output.TTY())
+}
+
+// inBandResize reports whether the terminal is asked to report resizes in
+// band. A viewport's size is fixed, so it's not asked then.
+func (p *Program) inBandResize() bool {
+	return p.startupOptions.has(withInBandResize) && !p.viewport.valid()
+}
+
 // handleResize handles terminal resize events.
 func (p *Program) handleResize() chan struct{} {
 	ch := make(chan struct{})
 
-	if f, ok := p.output.TTY().(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+	// A viewport's size is fixed, whatever the size of the terminal.
+	if p.viewport.valid() {
+		go p.Send(WindowSizeMsg{Width: p.viewport.width, Height: p.viewport.height})
+		close(ch)
+		return ch
+	}
+
+	if p.canQuerySize() {
 		// Get the initial terminal size and send it to the program.
 		go p.checkResize()
 
@@ -245,7 +518,7 @@ func (p *Program) handleCommands(cmds chan Cmd) chan struct{} {
 				// until Cmd returns.
 				go func() {
 					msg := cmd() // this can be long.
-					p.Send(msg)
+					p.Send(cmdDoneMsg{msg: msg})
 				}()
 			}
 		}
@@ -254,10 +527,77 @@ func (p *Program) handleCommands(cmds chan Cmd) chan struct{} {
 	return ch
 }
 
+// sendCmd passes a command on to be run, keeping track of the commands in
+// flight for barriers. It must only be called from the event loop.
+func (p *Program) sendCmd(cmds chan Cmd, cmd Cmd) {
+	if cmd != nil {
+		p.cmdsInFlight++
+	}
+	cmds <- cmd
+}
+
+// changeMode applies a change to the terminal's modes. It must only be called
+// from the event loop.
+func (p *Program) changeMode(msg Msg) {
+	switch msg := msg.(type) {
+	case enterAltScreenMsg:
+		p.renderer.enterAltScreen()
+
+	case exitAltScreenMsg:
+		p.renderer.exitAltScreen()
+
+	case enableMouseCellMotionMsg:
+		p.renderer.enableMouseCellMotion()
+
+	case enableMouseAllMotionMsg:
+		p.renderer.enableMouseAllMotion()
+
+	case disableMouseMsg:
+		p.renderer.disableMouseCellMotion()
+		p.renderer.disableMouseAllMotion()
+
+	case setReverseVideoMsg:
+		p.renderer.setReverseVideo(bool(msg))
+
+	case setAlternateScrollMsg:
+		p.renderer.setAlternateScroll(bool(msg))
+
+	case setInsertModeMsg:
+		p.renderer.setInsertMode(bool(msg))
+
+	case setCursorBlinkMsg:
+		p.renderer.setCursorStyle(p.renderer.cursorStyle().withBlink(bool(msg)))
+
+	case showCursorMsg:
+		p.renderer.showCursor()
+
+	case hideCursorMsg:
+		p.renderer.hideCursor()
+
+	case setCursorPositionMsg:
+		p.renderer.setCursorPosition(msg.x, msg.y)
+	}
+}
+
 // eventLoop is the central message loop. It receives and handles the default
 // Bubble Tea messages, update the model and triggers redraws.
 func (p *Program) eventLoop(model Model, cmds chan Cmd) (Model, error) {
+	idle := newIdleTimer(p.idleTimeout)
+	defer idle.stop()
+
 	for {
+		// Once no commands are left in flight, let the barriers waiting on
+		// them through.
+		if p.cmdsInFlight == 0 && len(p.barriers) > 0 {
+			tags := p.barriers
+			p.barriers = nil
+			go func() {
+				for _, tag := range tags {
+					p.Send(BarrierMsg{Tag: tag})
+				}
+			}()
+		}
+
 		select {
 		case <-p.ctx.Done():
 			return model, nil
@@ -265,65 +605,314 @@ func (p *Program) eventLoop(model Model, cmds chan Cmd) (Model, error) {
 		case err := <-p.errs:
 			return model, err
 
+		case <-idle.C():
+			// Count down again, so that an IdleMsg is sent for every
+			// timeout without input.
+			idle.restart()
+			go p.Send(IdleMsg{})
+
 		case msg := <-p.msgs:
+			var suspending bool
+
+			// A command returned; its message is handled like any other.
+			if done, ok := msg.(cmdDoneMsg); ok {
+				p.cmdsInFlight--
+				msg = done.msg
+			}
+
+			// Likewise for cancellable commands, unless they were
+			// cancelled.
+			if done, ok := msg.(cancellableDoneMsg); ok {
+				if _, ok := p.cancellable[done.tag]; !ok {
+					continue
+				}
+				delete(p.cancellable, done.tag)
+				msg = done.msg
+			}
+
+			if isUserInput(msg) {
+				idle.restart()
+			}
+
 			// Handle special internal messages.
 			switch msg := msg.(type) {
 			case quitMsg:
 				return model, nil
 
+			case WindowSizeMsg:
+				if msg.Width <= 0 || msg.Height <= 0 {
+					// Whoever sent it, a size without an area is never
+					// reported.
+					continue
+				}
+				p.lastWindowSize = msg
+				if p.startupOptions.has(withMousePixels) {
+					// The cell size changes along with the font size, which
+					// is usually what changed the size of the window.
+					p.renderer.execute(requestCellSizeSeq)
+				}
+
 			case clearScreenMsg:
 				p.renderer.clearScreen()
 
-			case enterAltScreenMsg:
-				p.renderer.enterAltScreen()
+			case clearToEndOfScreenMsg:
+				p.renderer.clearToEndOfScreen()
+
+			case clearToEndOfLineMsg:
+				p.renderer.clearToEndOfLine()
+
+			case clearLineMsg:
+				p.renderer.clearLine()
+
+			case scrollUpByMsg:
+				p.renderer.scrollUp(int(msg))
+
+			case scrollDownByMsg:
+				p.renderer.scrollDown(int(msg))
+
+			case enterAltScreenMsg, exitAltScreenMsg,
+				enableMouseCellMotionMsg, enableMouseAllMotionMsg, disableMouseMsg,
+				setReverseVideoMsg, setAlternateScrollMsg, setInsertModeMsg,
+				setCursorBlinkMsg, showCursorMsg, hideCursorMsg, setCursorPositionMsg:
+				p.changeMode(msg)
+
+			case batchModeChangeMsg:
+				var changes, others []Msg
+				for _, m := range msg {
+					if modeGroup(m) == modeGroupNone {
+						others = append(others, m)
+						continue
+					}
+					changes = append(changes, m)
+				}
+				sort.SliceStable(changes, func(i, j int) bool {
+					return modeGroup(changes[i]) < modeGroup(changes[j])
+				})
+				p.renderer.batch(func() {
+					for _, m := range changes {
+						p.changeMode(m)
+					}
+				})
+				if len(others) > 0 {
+					go func() {
+						for _, m := range others {
+							p.Send(m)
+						}
+					}()
+				}
+				if len(changes) == 0 || modeGroup(changes[0]) != modeGroupAltScreen {
+					continue
+				}
+
+			case resetTerminalMsg:
+				p.renderer.resetTerminal()
+				if p.startupOptions.has(withDECLocator) {
+					p.renderer.execute(enableDECLocatorSeq)
+				}
+				if p.startupOptions.has(withMouseHighlight) {
+					p.renderer.execute(enableMouseHighlightSeq)
+				}
+				if p.inBandResize() {
+					p.renderer.execute(enableInBandResizeSeq)
+				}
+
+			case setCWDMsg:
+				p.renderer.execute(fmt.Sprintf(setCWDSeq, string(msg)))
+
+			case rawSequenceMsg:
+				p.renderer.execute(string(msg))
+
+			case setTabStopMsg:
+				p.renderer.execute(setTabStopSeq)
 
-			case exitAltScreenMsg:
-				p.renderer.exitAltScreen()
+			case clearTabStopsMsg:
+				p.renderer.execute(clearTabStopsSeq)
+
+			case requestCursorPositionMsg:
+				p.renderer.execute(requestCursorPositionSeq)
+
+			case requestWindowSizeMsg:
+				p.requestWindowSize()
+				continue
+
+			case requestCellSizeMsg:
+				p.renderer.execute(requestCellSizeSeq)
+
+			case requestWindowPixelSizeMsg:
+				p.renderer.execute(requestWindowPixelSizeSeq)
+
+			case requestForegroundColorMsg:
+				p.renderer.execute(requestForegroundColorSeq)
+
+			case requestDeviceAttributesMsg:
+				p.requestDeviceAttributes()
+
+			case DeviceAttributesMsg:
+				if !p.deviceAttributes.reply() {
+					// A reply to a query we already timed out. The program
+					// has been told about it, so drop it.
+					continue
+				}
 
-			case enableMouseCellMotionMsg:
-				p.renderer.enableMouseCellMotion()
+			case deviceAttributesTimeoutMsg:
+				if !p.deviceAttributes.timeout(msg.id) {
+					continue
+				}
 
-			case enableMouseAllMotionMsg:
-				p.renderer.enableMouseAllMotion()
+			case requestTerminalVersionMsg:
+				p.requestTerminalVersion(false)
 
-			case disableMouseMsg:
-				p.renderer.disableMouseCellMotion()
-				p.renderer.disableMouseAllMotion()
+			case TerminalVersionMsg:
+				internal := p.terminalVersion.nextIsInternal()
+				if !p.terminalVersion.reply() {
+					continue
+				}
+				if p.startupOptions.has(withRepeatCompression) && supportsRepeat(msg.Name) {
+					if r, ok := p.renderer.(*standardRenderer); ok {
+						r.setRepeat(true)
+					}
+				}
+				if internal {
+					// The reply to our own query, Update didn't ask for it.
+					continue
+				}
 
-			case showCursorMsg:
-				p.renderer.showCursor()
+			case terminalVersionTimeoutMsg:
+				if !p.terminalVersion.timeout(msg.id) || msg.internal {
+					continue
+				}
 
-			case hideCursorMsg:
-				p.renderer.hideCursor()
+			case restartMsg:
+				msg.done <- p.restart()
+				continue
 
 			case execMsg:
 				// NB: this blocks.
 				p.exec(msg.cmd, msg.fn)
 
+			case suspendMsg:
+				if !suspendSupported {
+					continue
+				}
+				// Update gets to see the SuspendMsg first, then we suspend.
+				suspending = true
+
 			case BatchMsg:
 				for _, cmd := range msg {
-					cmds <- cmd
+					p.sendCmd(cmds, cmd)
 				}
 				continue
 
+			case barrierMsg:
+				p.barriers = append(p.barriers, msg.tag)
+				continue
+
+			case afterRenderMsg:
+				// It's in flight until the frame is rendered and fn has
+				// returned.
+				p.cmdsInFlight++
+				fn := msg.fn
+				p.renderer.afterFlush(func() {
+					go func() {
+						p.Send(cmdDoneMsg{msg: fn()})
+					}()
+				})
+				continue
+
+			case cancellableMsg:
+				if p.cancellable == nil {
+					p.cancellable = make(map[CmdTag]struct{})
+				}
+				p.cancellable[msg.tag] = struct{}{}
+				p.sendCmd(cmds, func() Msg {
+					return cancellableDoneMsg{tag: msg.tag, msg: msg.cmd()}
+				})
+				continue
+
+			case cancelMsg:
+				delete(p.cancellable, msg.tag)
+				continue
+
+			case pushSnapshotMsg:
+				if s, ok := model.(Snapshotter); ok {
+					p.snapshots = append(p.snapshots, s.Snapshot())
+				}
+
+			case popSnapshotMsg:
+				s, ok := model.(Snapshotter)
+				if !ok || len(p.snapshots) == 0 {
+					break
+				}
+				last := len(p.snapshots) - 1
+				model = s.Restore(p.snapshots[last])
+				p.snapshots[last] = nil
+				p.snapshots = p.snapshots[:last]
+
 			case sequenceMsg:
+				if len(msg) == 0 {
+					break
+				}
+				// The sequence is in flight until its last command returns.
+				p.cmdsInFlight++
 				go func() {
 					// Execute commands one at a time, in order.
-					for _, cmd := range msg {
+					for i, cmd := range msg {
+						if i == len(msg)-1 {
+							p.Send(cmdDoneMsg{msg: cmd()})
+							break
+						}
 						p.Send(cmd())
 					}
 				}()
 			}
 
+			// Confirm alt screen switches. Update gets to see the new state
+			// instead of the internal message that requested it.
+			switch msg.(type) {
+			case enterAltScreenMsg, exitAltScreenMsg, batchModeChangeMsg:
+				msg = AltScreenMsg{Active: p.renderer.altScreen()}
+			case deviceAttributesTimeoutMsg:
+				// Likewise, an unanswered device attributes query is
+				// reported as an empty reply.
+				msg = DeviceAttributesMsg{}
+			case terminalVersionTimeoutMsg:
+				msg = TerminalVersionMsg{}
+			case suspendMsg:
+				msg = SuspendMsg{}
+			case pendingCmdsMsg:
+				tags := make(PendingCmdsMsg, 0, len(p.cancellable))
+				for tag := range p.cancellable {
+					tags = append(tags, tag)
+				}
+				sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+				msg = tags
+			}
+
 			// Process internal messages for the renderer.
 			if r, ok := p.renderer.(*standardRenderer); ok {
 				r.handleMessages(msg)
 			}
 
 			var cmd Cmd
+			prev := model
 			model, cmd = model.Update(msg) // run update
-			cmds <- cmd                    // process command (if any)
-			p.renderer.write(model.View()) // send view to renderer
+			if model == nil {
+				// Rather than crash on the next View, stop with a model
+				// that works.
+				return prev, fmt.Errorf("%w: %T", ErrNilModel, prev)
+			}
+			p.sendCmd(cmds, cmd) // process command (if any)
+			p.render(model)      // send view to renderer
+
+			switch msg.(type) {
+			case flushMsg, forceRenderMsg:
+				p.renderer.flush()
+			}
+
+			if suspending {
+				// NB: this blocks until the program is resumed.
+				p.suspend()
+			}
 		}
 	}
 }
@@ -369,9 +958,17 @@ func (p *Program) Run() (Model, error) {
 		p.input = f
 	}
 
+	if isTerminal(p.input) {
+		atomic.StoreUint32(&p.inputIsTTY, 1)
+	}
+	if isTerminal(p.output.TTY()) {
+		atomic.StoreUint32(&p.outputIsTTY, 1)
+	}
+
 	// Handle signals.
 	if !p.startupOptions.has(withoutSignalHandler) {
 		handlers.add(p.handleSignals())
+		handlers.add(p.listenForSuspend())
 	}
 
 	// Recover from panics.
@@ -386,9 +983,41 @@ func (p *Program) Run() (Model, error) {
 		}()
 	}
 
-	// If no renderer is set use the standard one.
+	// If no renderer is set use the standard one, or the plain one if the
+	// output can't handle escape sequences.
 	if p.renderer == nil {
-		p.renderer = newRenderer(p.output, p.startupOptions.has(withANSICompressor))
+		p.rendererMtx.Lock()
+		out := p.output
+		if p.outputTap != nil {
+			out = termenv.NewOutput(&tapWriter{w: p.output, tap: p.outputTap},
+				termenv.WithProfile(p.output.Profile),
+			)
+		}
+		if p.startupOptions.has(withoutANSI) || p.dumbTerminal() {
+			p.renderer = newPlainRenderer(out)
+		} else {
+			p.renderer = newRenderer(out, p.startupOptions.has(withANSICompressor))
+		}
+		p.rendererMtx.Unlock()
+	}
+	if r, ok := p.renderer.(*standardRenderer); ok {
+		r.manualFlush = p.startupOptions.has(withManualFlush)
+		r.mouseUTF8 = p.startupOptions.has(withMouseUTF8)
+		// Terminals prefer SGR over UTF-8 when both are enabled, so only
+		// request it when UTF-8 wasn't asked for.
+		r.mouseSGR = !r.mouseUTF8
+		r.mousePixels = p.startupOptions.has(withMousePixels)
+		r.maxFrameSize = p.maxFrameSize
+		r.viewport = p.viewport
+		r.onRender = p.renderMetrics
+	}
+
+	// Wait until we may start, if the program was created paused.
+	select {
+	case <-p.resumed:
+	case <-p.ctx.Done():
+		p.runExitFuncs()
+		return p.initialModel, ErrProgramKilled
 	}
 
 	// Check if output is a TTY before entering raw mode, hiding the cursor and
@@ -400,18 +1029,48 @@ func (p *Program) Run() (Model, error) {
 	// Honor program startup options.
 	if p.startupOptions&withAltScreen != 0 {
 		p.renderer.enterAltScreen()
+	} else if p.startupOptions.has(withAltScreenIfSupported) {
+		if p.altScreenSupported() {
+			p.renderer.enterAltScreen()
+		} else {
+			go p.Send(AltScreenUnavailableMsg{})
+		}
 	}
 	if p.startupOptions&withMouseCellMotion != 0 {
 		p.renderer.enableMouseCellMotion()
 	} else if p.startupOptions&withMouseAllMotion != 0 {
 		p.renderer.enableMouseAllMotion()
+	} else if p.startupOptions&withReportScroll != 0 {
+		// The terminal only reports the wheel when mouse tracking is on.
+		p.renderer.enableMouseCellMotion()
+	}
+	if p.startupOptions.has(withDECLocator) {
+		p.renderer.execute(enableDECLocatorSeq)
+	}
+	if p.startupOptions.has(withMouseHighlight) {
+		p.renderer.execute(enableMouseHighlightSeq)
+	}
+	if p.inBandResize() {
+		p.renderer.execute(enableInBandResizeSeq)
+	}
+	if p.startupOptions.has(withMousePixels) {
+		// Ask for the cell size to derive the cells of mouse events from.
+		p.renderer.execute(requestCellSizeSeq)
+	}
+	if p.startupOptions.has(withRepeatCompression) {
+		// Ask which terminal this is, to know whether it supports REP.
+		p.requestTerminalVersion(true)
 	}
 
-	// Initialize the program.
-	model := p.initialModel
+	// Initialize the program. The initial command's messages, including any
+	// special ones that change the terminal's state, only get processed once
+	// the event loop below is running, so they're always applied to a started
+	// renderer.
+	model := p.wrapModel(p.initialModel)
 	if initCmd := model.Init(); initCmd != nil {
 		ch := make(chan struct{})
 		handlers.add(ch)
+		p.cmdsInFlight++
 
 		go func() {
 			defer close(ch)
@@ -425,20 +1084,34 @@ func (p *Program) Run() (Model, error) {
 
 	// Start the renderer.
 	p.renderer.start()
+	p.runTimeMtx.Lock()
+	p.startedAt = time.Now()
+	p.runTimeMtx.Unlock()
+	defer func() {
+		p.runTimeMtx.Lock()
+		p.stoppedAt = time.Now()
+		p.runTimeMtx.Unlock()
+	}()
 
 	// Render the initial view.
-	p.renderer.write(model.View())
+	p.render(model)
+
+	// Report the color profile.
+	go p.Send(ColorProfileMsg{Profile: p.ColorProfile()})
 
 	// Subscribe to user input.
 	if p.input != nil {
 		if err := p.initCancelReader(); err != nil {
-			return model, err
+			return p.unwrapModel(model), err
 		}
 	}
 
 	// Handle resize events.
 	handlers.add(p.handleResize())
 
+	// Pass on the messages sent while the program was paused.
+	handlers.add(p.sendPausedMsgs())
+
 	// Process commands.
 	handlers.add(p.handleCommands(cmds))
 
@@ -449,7 +1122,7 @@ func (p *Program) Run() (Model, error) {
 		err = ErrProgramKilled
 	} else {
 		// Ensure we rendered the final state of the model.
-		p.renderer.write(model.View())
+		p.render(model)
 	}
 
 	// Tear down.
@@ -467,7 +1140,7 @@ func (p *Program) Run() (Model, error) {
 	// Restore terminal state.
 	p.shutdown(killed)
 
-	return model, err
+	return p.unwrapModel(model), err
 }
 
 // StartReturningModel initializes the program and runs its event loops,
@@ -493,16 +1166,41 @@ func (p *Program) Start() error {
 // messages to be injected from outside the program for interoperability
 // purposes.
 //
-// If the program hasn't started yet this will be a blocking operation.
+// If the program hasn't started yet this will be a blocking operation, unless
+// it was created with WithStartPaused, in which case the message is queued.
 // If the program has already been terminated this will be a no-op, so it's safe
 // to send messages after the program has exited.
 func (p *Program) Send(msg Msg) {
+	p.pausedMtx.Lock()
+	if p.paused {
+		p.pausedMsgs = append(p.pausedMsgs, msg)
+		p.pausedMtx.Unlock()
+		return
+	}
+	p.pausedMtx.Unlock()
+
 	select {
 	case <-p.ctx.Done():
 	case p.msgs <- msg:
 	}
 }
 
+// Execute runs a command from outside the program, just like a command
+// returned from Update: it runs in its own goroutine and the message it
+// returns is sent to Update. Commands like Batch and Sequence work as they do
+// when returned from Update. This saves running a command yourself and then
+// sending its result with Send.
+//
+// Like Send, this blocks until the program has started, and it's a no-op once
+// the program has exited. Don't call it from Update; return the command
+// instead.
+func (p *Program) Execute(cmd Cmd) {
+	if cmd == nil {
+		return
+	}
+	p.Send(BatchMsg{cmd})
+}
+
 // Quit is a convenience function for quitting Bubble Tea programs. Use it
 // when you need to shut down a Bubble Tea program from the outside.
 //
@@ -514,13 +1212,61 @@ func (p *Program) Quit() {
 	p.Send(Quit())
 }
 
-// Kill stops the program immediately and restores the former terminal state.
-// The final render that you would normally see when quitting will be skipped.
+// Kill stops the program immediately, for emergencies such as rendering
+// hanging. The final render that you would normally see when quitting is
+// skipped, and so is most of the teardown: the terminal is taken out of raw
+// mode, but the modes the program set, such as the alternate screen, mouse
+// tracking or the hidden cursor, are left as they are, so the terminal may
+// need to be reset. Prefer Quit, which restores the terminal fully.
 // [program.Run] returns a [ErrProgramKilled] error.
+//
+// Canceling the context set with WithContext stops the program in the same
+// way, but restores the terminal.
 func (p *Program) Kill() {
+	atomic.StoreUint32(&p.killed, 1)
 	p.cancel()
 }
 
+// wrapModel wraps the given model with the program's middleware, so that the
+// first middleware ends up outermost.
+func (p *Program) wrapModel(model Model) Model {
+	for i := len(p.middleware) - 1; i >= 0; i-- {
+		model = p.middleware[i](model)
+	}
+	return model
+}
+
+// unwrapModel undoes wrapModel for the model returned by Run, for middleware
+// that implements Unwrap. The others stay wrapped.
+func (p *Program) unwrapModel(model Model) Model {
+	for range p.middleware {
+		u, ok := model.(interface{ Unwrap() Model })
+		if !ok {
+			break
+		}
+		model = u.Unwrap()
+	}
+	return model
+}
+
+// render sends the view of the given model to the renderer, using Render if
+// the model implements Renderable, and updates the cursor if it implements
+// CursorModel.
+func (p *Program) render(model Model) {
+	if c, ok := model.(CursorModel); ok {
+		p.renderer.syncCursor(c.Cursor())
+	}
+
+	r, ok := model.(Renderable)
+	if !ok {
+		p.renderer.write(model.View())
+		return
+	}
+	p.frame.Reset()
+	r.Render(&p.frame)
+	p.renderer.writeBytes(p.frame.Bytes())
+}
+
 // shutdown performs operations to free up resources and restore the terminal
 // to its original state.
 func (p *Program) shutdown(kill bool) {
@@ -532,28 +1278,195 @@ func (p *Program) shutdown(kill bool) {
 		}
 	}
 
-	_ = p.restoreTerminalState()
+	if kill && atomic.LoadUint32(&p.killed) != 0 {
+		_ = p.restoreTTY()
+	} else {
+		_ = p.restoreTerminalState()
+	}
 	if p.restoreOutput != nil {
 		_ = p.restoreOutput()
 	}
+
+	p.runExitFuncs()
+}
+
+// MouseMode returns the mode of mouse tracking currently in effect, as set by
+// the startup options and the commands enabling and disabling the mouse. It's
+// MouseModeOff before the program has started and after it has exited. It's
+// safe to call from any goroutine.
+func (p *Program) MouseMode() MouseMode {
+	p.rendererMtx.RLock()
+	r := p.renderer
+	p.rendererMtx.RUnlock()
+	if r == nil {
+		return MouseModeOff
+	}
+
+	var tracking MouseMode
+	switch {
+	case r.mouseAllMotionEnabled():
+		tracking = MouseModeAllMotion
+	case r.mouseCellMotionEnabled():
+		tracking = MouseModeCellMotion
+	default:
+		return MouseModeOff
+	}
+
+	switch {
+	case p.startupOptions.has(withMousePixels):
+		return MouseModePixelsMotion
+	case p.startupOptions.has(withMouseUTF8):
+		return MouseModeExtendedMotion
+	default:
+		return tracking
+	}
+}
+
+// InputIsTTY reports whether the program's input is a terminal, which is where
+// keys and mouse events come from. It takes WithInput and WithInputTTY into
+// account, as well as the terminal opened when input is piped in. It's only
+// known once the program has started running, and is false before then. It's
+// safe to call from any goroutine.
+func (p *Program) InputIsTTY() bool {
+	return atomic.LoadUint32(&p.inputIsTTY) == 1
+}
+
+// OutputIsTTY reports whether the program's output is a terminal, as set with
+// WithOutput. Use it to decide between a fancy interface and plain text, for
+// instance when output is redirected to a file. It's only known once the
+// program has started running, and is false before then. It's safe to call
+// from any goroutine.
+func (p *Program) OutputIsTTY() bool {
+	return atomic.LoadUint32(&p.outputIsTTY) == 1
+}
+
+// StartedAt returns when the program started running, that is, when its
+// renderer started, after the terminal was set up. It's the zero time before
+// then. It's safe to call from any goroutine.
+func (p *Program) StartedAt() time.Time {
+	p.runTimeMtx.Lock()
+	defer p.runTimeMtx.Unlock()
+
+	return p.startedAt
+}
+
+// Uptime returns how long the program has been running since StartedAt. Once
+// Run has returned, it's how long the program ran for, and before the program
+// has started it's zero. It's safe to call from any goroutine.
+func (p *Program) Uptime() time.Duration {
+	p.runTimeMtx.Lock()
+	defer p.runTimeMtx.Unlock()
+
+	switch {
+	case p.startedAt.IsZero():
+		return 0
+	case !p.stoppedAt.IsZero():
+		return p.stoppedAt.Sub(p.startedAt)
+	default:
+		return time.Since(p.startedAt)
+	}
+}
+
+// Resume starts a program created with WithStartPaused: Run proceeds to set
+// up the terminal, render and process messages, starting with the ones sent
+// while it was paused. It's safe to call from any goroutine, and calling it
+// more than once, or on a program that isn't paused, does nothing.
+func (p *Program) Resume() {
+	p.resumeOnce.Do(func() {
+		close(p.resumed)
+	})
+}
+
+// sendPausedMsgs passes on the messages sent while the program was paused to
+// the event loop, in order. Messages sent in the meantime are queued after
+// them, so that they can't overtake them.
+func (p *Program) sendPausedMsgs() chan struct{} {
+	ch := make(chan struct{})
+
+	go func() {
+		defer close(ch)
+
+		for {
+			p.pausedMtx.Lock()
+			msgs := p.pausedMsgs
+			p.pausedMsgs = nil
+			if len(msgs) == 0 {
+				// All caught up, from now on messages can be sent directly.
+				p.paused = false
+				p.pausedMtx.Unlock()
+				return
+			}
+			p.pausedMtx.Unlock()
+
+			for _, msg := range msgs {
+				select {
+				case <-p.ctx.Done():
+					p.pausedMtx.Lock()
+					p.paused = false
+					p.pausedMtx.Unlock()
+					return
+				case p.msgs <- msg:
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// OnExit registers a function to be called when the program exits, such as
+// for closing files or flushing caches. Functions are called in the reverse
+// order they were registered in, after the terminal has been restored. They
+// run however the program exits: when it quits, is killed or recovers from a
+// panic.
+//
+// Each function is called at most once. OnExit is safe to call from any
+// goroutine, including from commands.
+func (p *Program) OnExit(fn func()) {
+	if fn == nil {
+		return
+	}
+
+	p.exitFuncsMtx.Lock()
+	defer p.exitFuncsMtx.Unlock()
+	p.exitFuncs = append(p.exitFuncs, fn)
+}
+
+// runExitFuncs calls the functions registered with OnExit, last one first.
+func (p *Program) runExitFuncs() {
+	p.exitFuncsMtx.Lock()
+	fns := p.exitFuncs
+	p.exitFuncs = nil
+	p.exitFuncsMtx.Unlock()
+
+	for i := len(fns) - 1; i >= 0; i-- {
+		fns[i]()
+	}
 }
 
 // ReleaseTerminal restores the original terminal state and cancels the input
 // reader. You can return control to the Program with RestoreTerminal.
 func (p *Program) ReleaseTerminal() error {
-	p.ignoreSignals = true
+	atomic.StoreUint32(&p.ignoreSignals, 1)
 	p.cancelReader.Cancel()
 	p.waitForReadLoop()
 
 	p.altScreenWasActive = p.renderer.altScreen()
+	p.mouseCellMotionWasActive = p.renderer.mouseCellMotionEnabled()
+	p.mouseAllMotionWasActive = p.renderer.mouseAllMotionEnabled()
+	p.reverseVideoWasActive = p.renderer.reverseVideo()
+	p.alternateScrollWasActive = p.renderer.alternateScroll()
+	p.insertModeWasActive = p.renderer.insertMode()
+	p.cursorStyleWas = p.renderer.cursorStyle()
 	return p.restoreTerminalState()
 }
 
 // RestoreTerminal reinitializes the Program's input reader, restores the
-// terminal to the former state when the program was running, and repaints.
-// Use it to reinitialize a Program after running ReleaseTerminal.
+// terminal to the former state when the program was running, including the
+// altscreen and mouse modes, and repaints. Use it to reinitialize a Program
+// after running ReleaseTerminal.
 func (p *Program) RestoreTerminal() error {
-	p.ignoreSignals = false
+	atomic.StoreUint32(&p.ignoreSignals, 0)
 
 	if err := p.initTerminal(); err != nil {
 		return err
@@ -562,6 +1475,34 @@ func (p *Program) RestoreTerminal() error {
 		return err
 	}
 
+	if p.mouseCellMotionWasActive {
+		p.renderer.enableMouseCellMotion()
+	}
+	if p.mouseAllMotionWasActive {
+		p.renderer.enableMouseAllMotion()
+	}
+	if p.reverseVideoWasActive {
+		p.renderer.setReverseVideo(true)
+	}
+	if p.alternateScrollWasActive {
+		p.renderer.setAlternateScroll(true)
+	}
+	if p.insertModeWasActive {
+		p.renderer.setInsertMode(true)
+	}
+	if p.cursorStyleWas != cursorStyleDefault {
+		p.renderer.setCursorStyle(p.cursorStyleWas)
+	}
+	if p.startupOptions.has(withDECLocator) {
+		p.renderer.execute(enableDECLocatorSeq)
+	}
+	if p.startupOptions.has(withMouseHighlight) {
+		p.renderer.execute(enableMouseHighlightSeq)
+	}
+	if p.inBandResize() {
+		p.renderer.execute(enableInBandResizeSeq)
+	}
+
 	if p.altScreenWasActive {
 		p.renderer.enterAltScreen()
 	} else {
@@ -578,6 +1519,63 @@ func (p *Program) RestoreTerminal() error {
 	return nil
 }
 
+// Restart stops the renderer and the input reader, restores the terminal and
+// then sets everything up again, as if the program was starting anew. Unlike
+// quitting and running a new program, the model and any commands in flight
+// survive the restart. Terminal modes such as the altscreen and the mouse are
+// re-applied, and the view is fully repainted. This is useful for things like
+// reloading configuration that affects the terminal.
+//
+// The restart is carried out by the event loop, and Restart blocks until it's
+// done, so don't call it from Init, Update or View, where it would deadlock.
+// Call it from a command instead:
+//
+//	func restart(p *tea.Program) tea.Cmd {
+//		return func() tea.Msg {
+//			return restartedMsg{err: p.Restart()}
+//		}
+//	}
+//
+// If the program isn't running it waits for it to start. If the program
+// exits before the restart is carried out, ErrProgramKilled is returned.
+func (p *Program) Restart() error {
+	done := make(chan error, 1)
+
+	select {
+	case <-p.ctx.Done():
+		return ErrProgramKilled
+	case p.msgs <- restartMsg{done: done}:
+	}
+
+	select {
+	case <-p.ctx.Done():
+		return ErrProgramKilled
+	case err := <-done:
+		return err
+	}
+}
+
+// restartMsg is an internal message that signals to restart the program. It's
+// sent by Program.Restart, which waits for the result on done.
+type restartMsg struct {
+	done chan error
+}
+
+// restart tears down the renderer and the terminal state and sets them up
+// again. It must only be called from the event loop.
+func (p *Program) restart() error {
+	if err := p.ReleaseTerminal(); err != nil {
+		return err
+	}
+	p.renderer.kill()
+
+	if err := p.RestoreTerminal(); err != nil {
+		return err
+	}
+	p.renderer.start()
+	return nil
+}
+
 // Println prints above the Program. This output is unmanaged by the program
 // and will persist across renders by the Program.
 //
@@ -3,9 +3,18 @@ package tea
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/muesli/termenv"
 )
 
 type incrementMsg struct{}
@@ -96,6 +105,9 @@ func TestTeaKill(t *testing.T) {
 	if _, err := p.Run(); err != ErrProgramKilled {
 		t.Fatalf("Expected %v, got %v", ErrProgramKilled, err)
 	}
+	if strings.Contains(buf.String(), "\x1b[?25h") {
+		t.Errorf("expected the terminal modes to be left as they are, got %q", buf.String())
+	}
 }
 
 func TestTeaContext(t *testing.T) {
@@ -118,6 +130,9 @@ func TestTeaContext(t *testing.T) {
 	if _, err := p.Run(); err != ErrProgramKilled {
 		t.Fatalf("Expected %v, got %v", ErrProgramKilled, err)
 	}
+	if !strings.HasSuffix(buf.String(), "\x1b[?25h\x1b[?1002l\x1b[?1003l") {
+		t.Errorf("expected the terminal to be restored, got %q", buf.String())
+	}
 }
 
 func TestTeaBatchMsg(t *testing.T) {
@@ -198,3 +213,725 @@ func TestTeaNoRun(t *testing.T) {
 	m := &testModel{}
 	NewProgram(m, WithInput(&in), WithOutput(&buf))
 }
+
+func TestTeaConcurrentPrograms(t *testing.T) {
+	const n = 8
+
+	var wg sync.WaitGroup
+	bufs := make([]bytes.Buffer, n)
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(buf *bytes.Buffer) {
+			defer wg.Done()
+
+			in := bytes.NewBufferString("q")
+			p := NewProgram(&testModel{}, WithInput(in), WithOutput(buf))
+			if _, err := p.Run(); err != nil {
+				errs <- err
+			}
+		}(&bufs[i])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for i := range bufs {
+		if !bytes.Contains(bufs[i].Bytes(), []byte("success")) {
+			t.Errorf("program %d: expected output, got %q", i, bufs[i].String())
+		}
+	}
+}
+
+func TestTeaInitSpecialCommands(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testReportModel{
+		init: Sequence(EnableMouseCellMotion, EnterAltScreen),
+		done: func(msg Msg) bool {
+			_, ok := msg.(AltScreenMsg)
+			return ok
+		},
+	}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	mouse := strings.Index(out, "\x1b[?1002h")
+	alt := strings.Index(out, "\x1b[?1049h")
+	if mouse < 0 || alt < 0 || mouse > alt {
+		t.Fatalf("expected mouse and altscreen to be enabled in order, got %q", out)
+	}
+	if !strings.HasSuffix(out, "\x1b[?1049l\x1b[?25h") {
+		t.Errorf("expected the altscreen to be exited on teardown, got %q", out)
+	}
+}
+
+type restartedMsg struct{ err error }
+
+func TestTeaRestart(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	var p *Program
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(restartedMsg)
+		return ok
+	}}
+	m.init = func() Msg {
+		return restartedMsg{err: p.Restart()}
+	}
+	p = NewProgram(m, WithInput(&in), WithOutput(&buf), WithAltScreen(), WithMouseCellMotion())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	last := m.msgs[len(m.msgs)-1].(restartedMsg)
+	if last.err != nil {
+		t.Fatalf("unexpected restart error: %v", last.err)
+	}
+
+	out := buf.String()
+	if n := strings.Count(out, "\x1b[?1049h"); n != 2 {
+		t.Errorf("expected the altscreen to be entered twice, got %d times in %q", n, out)
+	}
+	if n := strings.Count(out, "\x1b[?1002h"); n != 2 {
+		t.Errorf("expected the mouse to be enabled twice, got %d times in %q", n, out)
+	}
+}
+
+func TestTeaRestartNotRunning(t *testing.T) {
+	p := NewProgram(nil)
+	p.Kill()
+
+	if err := p.Restart(); err != ErrProgramKilled {
+		t.Errorf("expected ErrProgramKilled, got %v", err)
+	}
+}
+
+func TestTeaExecute(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	go func() {
+		p.Execute(nil)
+		p.Execute(Sequence(
+			func() Msg { return incrementMsg{} },
+			func() Msg { return incrementMsg{} },
+			Quit,
+		))
+	}()
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.counter.Load() != 2 {
+		t.Fatalf("expected counter to be 2, got %v", m.counter.Load())
+	}
+}
+
+func TestTeaOnExit(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	var calls []int
+	p.OnExit(func() { calls = append(calls, 1) })
+	p.OnExit(nil)
+	p.OnExit(func() { calls = append(calls, 2) })
+
+	go p.Send(Quit())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(calls, []int{2, 1}) {
+		t.Fatalf("expected exit functions to be called in reverse order, got %v", calls)
+	}
+}
+
+type panicModel struct{}
+
+func (panicModel) Init() Cmd { return nil }
+
+func (panicModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(incrementMsg); ok {
+		panic("boom")
+	}
+	return panicModel{}, nil
+}
+
+func (panicModel) View() string { return "" }
+
+func TestTeaOnExitPanic(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	p := NewProgram(panicModel{}, WithInput(&in), WithOutput(&buf))
+
+	called := false
+	p.OnExit(func() { called = true })
+
+	go p.Send(incrementMsg{})
+
+	_, _ = p.Run()
+
+	if !called {
+		t.Fatal("expected exit function to be called after a panic")
+	}
+}
+
+func TestTeaMessageBufferBackpressure(t *testing.T) {
+	p := NewProgram(&testModel{}, WithMessageBufferSize(2))
+
+	var sent int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			p.Send(incrementMsg{})
+			atomic.AddInt64(&sent, 1)
+		}
+	}()
+
+	// The program isn't running, so nothing takes messages off the buffer
+	// and the third Send has to wait.
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt64(&sent); n != 2 {
+		t.Fatalf("expected 2 messages to be buffered, got %d", n)
+	}
+
+	p.Kill()
+	<-done
+}
+
+func TestTeaStartPaused(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithStartPaused())
+
+	errs := make(chan error)
+	go func() {
+		_, err := p.Run()
+		errs <- err
+	}()
+
+	// Messages sent while paused are queued, even without a buffer.
+	p.Send(incrementMsg{})
+	p.Send(incrementMsg{})
+
+	time.Sleep(50 * time.Millisecond)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output while paused, got %q", buf.String())
+	}
+	if m.counter.Load() != nil {
+		t.Fatal("expected no messages to be processed while paused")
+	}
+
+	p.Resume()
+	p.Resume()
+	p.Send(Quit())
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if m.counter.Load() != 2 {
+		t.Fatalf("expected counter to be 2, got %v", m.counter.Load())
+	}
+	if !strings.Contains(buf.String(), "success") {
+		t.Fatalf("expected the view to be rendered after resuming, got %q", buf.String())
+	}
+}
+
+func TestTeaStartPausedKill(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	p := NewProgram(&testModel{}, WithInput(&in), WithOutput(&buf), WithStartPaused())
+
+	var exited bool
+	p.OnExit(func() { exited = true })
+	p.Send(incrementMsg{})
+
+	go p.Kill()
+
+	if _, err := p.Run(); err != ErrProgramKilled {
+		t.Fatalf("expected ErrProgramKilled, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output, got %q", buf.String())
+	}
+	if !exited {
+		t.Fatal("expected the exit functions to be called")
+	}
+}
+
+func TestTeaShownCursor(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	in.Write([]byte("q"))
+
+	p := NewProgram(&testModel{}, WithInput(&in), WithOutput(&buf), WithShownCursor())
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if out := buf.String(); strings.Contains(out, "\x1b[?25l") {
+		t.Errorf("expected the cursor not to be hidden, got %q", out)
+	}
+}
+
+type uptimeMsg struct {
+	startedAt time.Time
+	uptime    time.Duration
+}
+
+func TestTeaUptime(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	var p *Program
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(uptimeMsg)
+		return ok
+	}}
+	m.init = func() Msg {
+		time.Sleep(10 * time.Millisecond)
+		return uptimeMsg{startedAt: p.StartedAt(), uptime: p.Uptime()}
+	}
+	p = NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	if !p.StartedAt().IsZero() || p.Uptime() != 0 {
+		t.Fatalf("expected no start time nor uptime before running, got %v and %v", p.StartedAt(), p.Uptime())
+	}
+
+	before := time.Now()
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	running := m.msgs[len(m.msgs)-1].(uptimeMsg)
+	if running.startedAt.Before(before) || running.uptime < 10*time.Millisecond {
+		t.Errorf("expected to be running since after %v for 10ms, got %v for %v", before, running.startedAt, running.uptime)
+	}
+
+	// The uptime stops growing once the program has exited.
+	uptime := p.Uptime()
+	if uptime < running.uptime {
+		t.Errorf("expected the uptime to be at least %v, got %v", running.uptime, uptime)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if p.Uptime() != uptime {
+		t.Errorf("expected the uptime to stay %v after exiting, got %v", uptime, p.Uptime())
+	}
+}
+
+// bigModel has a large view, which it can both return from View and write
+// with Render.
+type bigModel struct {
+	lines []string
+}
+
+func newBigModel() bigModel {
+	m := bigModel{lines: make([]string, 200)}
+	for i := range m.lines {
+		m.lines[i] = strings.Repeat(fmt.Sprintf("%d ", i), 50)
+	}
+	return m
+}
+
+func (m bigModel) Init() Cmd {
+	return nil
+}
+
+func (m bigModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(KeyMsg); ok {
+		return m, Quit
+	}
+	return m, nil
+}
+
+func (m bigModel) View() string {
+	var b strings.Builder
+	m.Render(&b)
+	return b.String()
+}
+
+func (m bigModel) Render(w io.Writer) {
+	for _, line := range m.lines {
+		_, _ = io.WriteString(w, line)
+		_, _ = io.WriteString(w, "\n")
+	}
+}
+
+// renderOnlyModel panics if View is called.
+type renderOnlyModel struct {
+	bigModel
+}
+
+func (m renderOnlyModel) View() string {
+	panic("View called on a Renderable model")
+}
+
+func TestTeaRenderable(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := renderOnlyModel{newBigModel()}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithoutCatchPanics())
+
+	go p.Send(Quit())
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), m.lines[0]) {
+		t.Errorf("expected the rendered view to be written, got %q", buf.String())
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	m := newBigModel()
+
+	for _, bc := range []struct {
+		name  string
+		model Model
+	}{
+		{"View", struct{ Model }{m}}, // hides Render
+		{"Render", m},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			p := &Program{renderer: newRenderer(termenv.NewOutput(io.Discard), false)}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p.render(bc.model)
+			}
+		})
+	}
+}
+
+// cursorModel shows the cursor after the number of increments it received.
+type cursorModel struct {
+	testModel
+	n int
+}
+
+func (m *cursorModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(incrementMsg); ok {
+		m.n++
+	}
+	return m, nil
+}
+
+func (m *cursorModel) View() string {
+	return "edit\n"
+}
+
+func (m *cursorModel) Cursor() (bool, int, int) {
+	return m.n > 0, m.n, 0
+}
+
+func TestTeaCursorModel(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &cursorModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	go func() {
+		p.Send(incrementMsg{})
+		p.Send(incrementMsg{})
+		p.Send(Quit())
+	}()
+
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "\x1b[1;2H\x1b[?25h") {
+		t.Errorf("expected the cursor to be shown after the first increment, got %q", out)
+	}
+	if !strings.Contains(out, "\x1b[1;3H") {
+		t.Errorf("expected the cursor to follow the model, got %q", out)
+	}
+	if n := strings.Count(out, "\x1b[?25h"); n != 2 {
+		t.Errorf("expected the cursor to be shown once, and on teardown, got %d times in %q", n, out)
+	}
+}
+
+// tagMiddleware records the messages it sees and tags the view.
+type tagMiddleware struct {
+	Model
+	tag  string
+	seen *[]string
+}
+
+func (m tagMiddleware) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(incrementMsg); ok {
+		*m.seen = append(*m.seen, m.tag)
+	}
+	var cmd Cmd
+	m.Model, cmd = m.Model.Update(msg)
+	return m, cmd
+}
+
+func (m tagMiddleware) View() string {
+	return "[" + m.tag + "]" + m.Model.View()
+}
+
+func (m tagMiddleware) Unwrap() Model {
+	return m.Model
+}
+
+func TestTeaMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	var seen []string
+	tag := func(tag string) func(Model) Model {
+		return func(m Model) Model {
+			return tagMiddleware{Model: m, tag: tag, seen: &seen}
+		}
+	}
+
+	m := &testModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithMiddleware(tag("outer")), WithMiddleware(tag("inner")))
+
+	go func() {
+		p.Send(incrementMsg{})
+		p.Send(Quit())
+	}()
+
+	model, err := p.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"outer", "inner"}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected middleware to see messages in order %v, got %v", expected, seen)
+	}
+	if m.counter.Load() != 1 {
+		t.Errorf("expected the model to be updated, got %v", m.counter.Load())
+	}
+	if !strings.Contains(buf.String(), "[outer][inner]success") {
+		t.Errorf("expected the view to be wrapped, got %q", buf.String())
+	}
+	if model != m {
+		t.Errorf("expected the model to be unwrapped, got %#v", model)
+	}
+}
+
+// nilModel returns a nil model from Update on any key.
+type nilModel struct {
+	testModel
+}
+
+func (m *nilModel) Update(msg Msg) (Model, Cmd) {
+	if _, ok := msg.(KeyMsg); ok {
+		return nil, nil
+	}
+	return m, nil
+}
+
+func TestTeaNilModel(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+
+	m := &nilModel{}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+
+	go p.Send(KeyMsg{Type: KeyEnter})
+
+	model, err := p.Run()
+	if !errors.Is(err, ErrNilModel) {
+		t.Fatalf("expected ErrNilModel, got %v", err)
+	}
+	if model != m {
+		t.Errorf("expected the last model to be returned, got %#v", model)
+	}
+}
+
+func TestTeaRawModeUnavailable(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close() //nolint:errcheck
+	defer w.Close() //nolint:errcheck
+
+	var buf bytes.Buffer
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(RawModeUnavailableMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(r), WithOutput(&buf))
+	go func() {
+		time.Sleep(2 * time.Second)
+		p.Quit()
+	}()
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range m.msgs {
+		if msg, ok := msg.(RawModeUnavailableMsg); ok {
+			if msg.Err == nil {
+				t.Error("expected an error explaining why")
+			}
+			return
+		}
+	}
+	t.Fatalf("expected RawModeUnavailableMsg, got %v", m.msgs)
+}
+
+func TestTeaRawModeNotFile(t *testing.T) {
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	m := &testReportModel{done: func(msg Msg) bool {
+		_, ok := msg.(RawModeUnavailableMsg)
+		return ok
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf))
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		p.Quit()
+	}()
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if m.quit {
+		t.Error("didn't expect RawModeUnavailableMsg for input that isn't a file")
+	}
+}
+
+func TestTeaIdleTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	type tickMsg struct{}
+
+	var buf bytes.Buffer
+	var in bytes.Buffer
+	var idle []time.Duration
+	start := time.Now()
+	m := &testReportModel{done: func(msg Msg) bool {
+		if _, ok := msg.(IdleMsg); ok {
+			idle = append(idle, time.Since(start))
+		}
+		return len(idle) == 2
+	}}
+	p := NewProgram(m, WithInput(&in), WithOutput(&buf), WithIdleTimeout(timeout))
+
+	// Keys keep the program from going idle, ticks don't.
+	go func() {
+		for i := 0; i < 15; i++ {
+			p.Send(KeyMsg{Type: KeyRunes, Runes: []rune{'a'}})
+			p.Send(tickMsg{})
+			time.Sleep(timeout / 5)
+		}
+	}()
+	go func() {
+		time.Sleep(5 * time.Second)
+		p.Quit()
+	}()
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(idle) != 2 {
+		t.Fatalf("expected two IdleMsgs, got %d", len(idle))
+	}
+	if idle[0] < 15*timeout/5 {
+		t.Errorf("expected no IdleMsg while typing, got one after %v", idle[0])
+	}
+	if d := idle[1] - idle[0]; d < timeout {
+		t.Errorf("expected IdleMsgs to be at least %v apart, got %v", timeout, d)
+	}
+}
+
+func TestTeaOutputTap(t *testing.T) {
+	for _, opt := range []ProgramOption{WithOutputTap(nil), WithANSICompressor(), WithoutANSI()} {
+		var buf bytes.Buffer
+		var in bytes.Buffer
+		var tapped bytes.Buffer
+		var last time.Time
+		tap := func(p []byte, now time.Time) {
+			tapped.Write(p)
+			if now.Before(last) {
+				t.Errorf("expected chunks in order, got %v after %v", now, last)
+			}
+			last = now
+		}
+
+		m := &testModel{}
+		// The option under test comes first, so that the tap overrides a
+		// nil one.
+		p := NewProgram(m, opt, WithInput(&in), WithOutput(&buf), WithOutputTap(tap))
+		go func() {
+			for {
+				time.Sleep(time.Millisecond)
+				if m.executed.Load() != nil {
+					p.Quit()
+					return
+				}
+			}
+		}()
+		if _, err := p.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		if tapped.Len() == 0 {
+			t.Fatal("expected the tap to see output")
+		}
+		if tapped.String() != buf.String() {
+			t.Errorf("expected the tap to see %q, got %q", buf.String(), tapped.String())
+		}
+	}
+}
+
+func TestTeaIsTTY(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close() //nolint:errcheck
+	defer w.Close() //nolint:errcheck
+
+	var buf bytes.Buffer
+	m := &testReportModel{done: func(Msg) bool { return true }}
+	p := NewProgram(m, WithInput(r), WithOutput(&buf))
+	if p.InputIsTTY() || p.OutputIsTTY() {
+		t.Fatal("expected neither to be known before running")
+	}
+	if _, err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither a pipe nor a buffer is a terminal.
+	if p.InputIsTTY() {
+		t.Error("expected the input not to be a terminal")
+	}
+	if p.OutputIsTTY() {
+		t.Error("expected the output not to be a terminal")
+	}
+	for _, v := range []interface{}{r, &buf, nil} {
+		if isTerminal(v) {
+			t.Errorf("expected %T not to be a terminal", v)
+		}
+	}
+}
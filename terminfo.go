@@ -0,0 +1,128 @@
+package tea
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The indexes of the enter and exit alternate screen capabilities, smcup and
+// rmcup, among the string capabilities of a compiled terminfo entry.
+const (
+	terminfoSmcup = 28
+	terminfoRmcup = 40
+)
+
+// The magic numbers of compiled terminfo entries, whose numbers are 16 bits
+// wide in the legacy format and 32 bits wide in the extended one.
+const (
+	terminfoMagic         = 0432
+	terminfoExtendedMagic = 01036
+)
+
+// errTerminfoNotFound is returned when there's no terminfo entry for a
+// terminal.
+var errTerminfoNotFound = errors.New("terminfo entry not found")
+
+// terminfoDirs returns the directories to look for terminfo entries in, in
+// the order ncurses searches them.
+func terminfoDirs(getenv func(string) string) []string {
+	var dirs []string
+	if dir := getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home := getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	defaults := []string{"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo"}
+	if list := getenv("TERMINFO_DIRS"); list != "" {
+		for _, dir := range strings.Split(list, ":") {
+			if dir == "" {
+				// An empty entry stands for the default directories.
+				dirs = append(dirs, defaults...)
+				continue
+			}
+			dirs = append(dirs, dir)
+		}
+		return dirs
+	}
+	return append(dirs, defaults...)
+}
+
+// readTerminfo reads the compiled terminfo entry of the given terminal.
+// Entries are filed under their first letter, or on some systems, such as
+// macOS, its hexadecimal code.
+func readTerminfo(term string, getenv func(string) string) ([]byte, error) {
+	if term == "" || strings.ContainsAny(term, "/\\") {
+		return nil, errTerminfoNotFound
+	}
+	for _, dir := range terminfoDirs(getenv) {
+		for _, sub := range []string{term[:1], fmt.Sprintf("%x", term[0])} {
+			b, err := os.ReadFile(filepath.Join(dir, sub, term))
+			if err == nil {
+				return b, nil
+			}
+		}
+	}
+	return nil, errTerminfoNotFound
+}
+
+// terminfoHasStrings reports whether a compiled terminfo entry defines all
+// of the string capabilities at the given indexes.
+func terminfoHasStrings(b []byte, caps ...int) (bool, error) {
+	if len(b) < 12 {
+		return false, errors.New("terminfo entry too short")
+	}
+	header := make([]int, 6)
+	for i := range header {
+		header[i] = int(int16(binary.LittleEndian.Uint16(b[i*2:])))
+	}
+	numSize := 2
+	switch header[0] {
+	case terminfoMagic:
+	case terminfoExtendedMagic:
+		numSize = 4
+	default:
+		return false, errors.New("not a compiled terminfo entry")
+	}
+	namesSize, boolCount, numCount, strCount := header[1], header[2], header[3], header[4]
+	if namesSize < 0 || boolCount < 0 || numCount < 0 || strCount < 0 {
+		return false, errors.New("malformed terminfo entry")
+	}
+
+	// The numbers start on an even byte.
+	off := 12 + namesSize + boolCount
+	if off%2 != 0 {
+		off++
+	}
+	off += numCount * numSize
+	if len(b) < off+strCount*2 {
+		return false, errors.New("terminfo entry too short")
+	}
+
+	for _, c := range caps {
+		if c >= strCount {
+			return false, nil
+		}
+		// Absent and cancelled capabilities have negative offsets.
+		if int16(binary.LittleEndian.Uint16(b[off+c*2:])) < 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// terminfoHasAltScreen reports whether the terminfo entry of the terminal set
+// in TERM defines both smcup and rmcup, which enter and exit the alternate
+// screen. It's false if there's no entry to tell.
+func terminfoHasAltScreen(getenv func(string) string) bool {
+	b, err := readTerminfo(getenv("TERM"), getenv)
+	if err != nil {
+		return false
+	}
+	ok, err := terminfoHasStrings(b, terminfoSmcup, terminfoRmcup)
+	return ok && err == nil
+}
@@ -0,0 +1,126 @@
+package tea
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// compileTerminfo builds a compiled terminfo entry with the given string
+// capabilities, by offset into the string table, negative for absent or
+// cancelled ones.
+func compileTerminfo(extended bool, strs ...int16) []byte {
+	var b bytes.Buffer
+	names := "test|test terminal\x00"
+	magic, numSize := int16(terminfoMagic), 2
+	if extended {
+		magic, numSize = terminfoExtendedMagic, 4
+	}
+	// Three booleans make the names and booleans odd in length, so that
+	// padding is needed before the numbers.
+	for _, v := range []int16{magic, int16(len(names)), 3, 2, int16(len(strs)), 4} {
+		_ = binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString(names)
+	b.Write([]byte{1, 0, 1})
+	if b.Len()%2 != 0 {
+		b.WriteByte(0)
+	}
+	b.Write(make([]byte, 2*numSize))
+	for _, v := range strs {
+		_ = binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("abc\x00")
+	return b.Bytes()
+}
+
+// altScreenStrings returns string capabilities up to rmcup, with smcup and
+// rmcup set as given.
+func altScreenStrings(smcup, rmcup int16) []int16 {
+	strs := make([]int16, terminfoRmcup+1)
+	for i := range strs {
+		strs[i] = -1
+	}
+	strs[terminfoSmcup] = smcup
+	strs[terminfoRmcup] = rmcup
+	return strs
+}
+
+func TestTerminfoHasStrings(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    []byte
+		expected bool
+		err      bool
+	}{
+		{"legacy", compileTerminfo(false, altScreenStrings(0, 0)...), true, false},
+		{"extended", compileTerminfo(true, altScreenStrings(0, 0)...), true, false},
+		{"absent", compileTerminfo(false, altScreenStrings(0, -1)...), false, false},
+		{"cancelled", compileTerminfo(false, altScreenStrings(-2, 0)...), false, false},
+		{"too few strings", compileTerminfo(false, 0, 0), false, false},
+		{"truncated", compileTerminfo(false, altScreenStrings(0, 0)...)[:40], false, true},
+		{"not terminfo", []byte("#!/bin/sh\necho hello\n"), false, true},
+		{"empty", nil, false, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := terminfoHasStrings(tc.entry, terminfoSmcup, terminfoRmcup)
+			if ok != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, ok)
+			}
+			if (err != nil) != tc.err {
+				t.Errorf("expected error: %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestTerminfoHasAltScreen(t *testing.T) {
+	dir := t.TempDir()
+	write := func(sub, term string, entry []byte) {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, sub, term), entry, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("f", "fancy", compileTerminfo(false, altScreenStrings(0, 0)...))
+	write("70", "plain", compileTerminfo(false, altScreenStrings(-1, -1)...)) // 'p' in hex
+
+	for _, tc := range []struct {
+		term     string
+		expected bool
+	}{
+		{"fancy", true},
+		{"plain", false},
+		{"unknown", false},
+		{"../f/fancy", false},
+		{"", false},
+	} {
+		env := environ{"TERMINFO=" + dir, "TERMINFO_DIRS=" + dir, "HOME=" + dir, "TERM=" + tc.term}
+		if got := terminfoHasAltScreen(env.Getenv); got != tc.expected {
+			t.Errorf("expected %q to have an alternate screen: %v, got %v", tc.term, tc.expected, got)
+		}
+	}
+}
+
+func TestTerminfoDirs(t *testing.T) {
+	env := environ{"TERMINFO=/a", "HOME=/home", "TERMINFO_DIRS=/b::/c"}
+	dirs := terminfoDirs(env.Getenv)
+	expected := []string{
+		"/a", filepath.Join("/home", ".terminfo"), "/b",
+		"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo",
+		"/c",
+	}
+	if len(dirs) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, dirs)
+	}
+	for i := range dirs {
+		if dirs[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, dirs)
+		}
+	}
+}
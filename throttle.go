@@ -0,0 +1,109 @@
+package tea
+
+import (
+	"sync"
+	"time"
+)
+
+// mouseMotionThrottle limits the rate at which mouse motion events are
+// delivered to the program. Motion events arriving within the interval of the
+// last delivered one are held back, and only the most recent of them is
+// delivered once the interval has elapsed, so the final pointer position is
+// never lost.
+//
+// Events other than mouse motion are never throttled. Any held back motion
+// event is delivered before them so that ordering is preserved.
+type mouseMotionThrottle struct {
+	mtx      sync.Mutex
+	interval time.Duration
+	send     func(Msg)
+
+	// when the last motion event was delivered
+	last time.Time
+
+	// the most recent motion event we held back, if any
+	pending *MouseMsg
+
+	// the timer delivering the pending event, and its generation, so that a
+	// timer firing after it was stopped doesn't deliver anything
+	timer *time.Timer
+	gen   int
+}
+
+func newMouseMotionThrottle(interval time.Duration, send func(Msg)) *mouseMotionThrottle {
+	return &mouseMotionThrottle{
+		interval: interval,
+		send:     send,
+	}
+}
+
+// filter returns the messages that should be delivered right away, holding
+// back motion events that arrived too soon.
+func (t *mouseMotionThrottle) filter(msgs []Msg, now time.Time) []Msg {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	out := make([]Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		m, ok := msg.(MouseMsg)
+		if !ok || m.Type != MouseMotion {
+			if t.pending != nil {
+				out = append(out, *t.pending)
+				t.pending = nil
+				t.stopTimer()
+			}
+			out = append(out, msg)
+			continue
+		}
+
+		if now.Sub(t.last) >= t.interval {
+			t.pending = nil
+			t.stopTimer()
+			t.last = now
+			out = append(out, m)
+			continue
+		}
+
+		// Too soon. Hold on to the latest position and deliver it once the
+		// interval has elapsed.
+		t.pending = &m
+		if t.timer == nil {
+			t.gen++
+			gen := t.gen
+			t.timer = time.AfterFunc(t.interval-now.Sub(t.last), func() {
+				t.flush(gen)
+			})
+		}
+	}
+
+	return out
+}
+
+// flush delivers the pending motion event, if any.
+func (t *mouseMotionThrottle) flush(gen int) {
+	t.mtx.Lock()
+	if gen != t.gen {
+		t.mtx.Unlock()
+		return
+	}
+	t.timer = nil
+	m := t.pending
+	t.pending = nil
+	if m != nil {
+		t.last = time.Now()
+	}
+	t.mtx.Unlock()
+
+	if m != nil {
+		t.send(*m)
+	}
+}
+
+// stopTimer cancels the delivery of the pending event. The mutex must be held.
+func (t *mouseMotionThrottle) stopTimer() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+		t.gen++
+	}
+}
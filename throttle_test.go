@@ -0,0 +1,72 @@
+package tea
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMouseMotionThrottle(t *testing.T) {
+	motion := func(x int) MouseMsg {
+		return MouseMsg{X: x, Type: MouseMotion}
+	}
+
+	t.Run("non-motion events pass through", func(t *testing.T) {
+		th := newMouseMotionThrottle(time.Hour, func(Msg) {})
+		now := time.Now()
+
+		msgs := []Msg{
+			MouseMsg{Type: MouseLeft},
+			MouseMsg{Type: MouseRelease},
+			MouseMsg{Type: MouseWheelUp},
+			MouseMsg{Type: MouseWheelDown},
+			KeyMsg{Type: KeyEnter},
+		}
+		for i := 0; i < 3; i++ {
+			if out := th.filter(msgs, now); !reflect.DeepEqual(out, msgs) {
+				t.Fatalf("expected %v, got %v", msgs, out)
+			}
+		}
+	})
+
+	t.Run("motion is throttled", func(t *testing.T) {
+		th := newMouseMotionThrottle(time.Hour, func(Msg) {})
+		now := time.Now()
+
+		out := th.filter([]Msg{motion(1), motion(2), motion(3)}, now)
+		if expected := []Msg{motion(1)}; !reflect.DeepEqual(out, expected) {
+			t.Fatalf("expected %v, got %v", expected, out)
+		}
+
+		// The latest held back motion is delivered before other events.
+		out = th.filter([]Msg{MouseMsg{Type: MouseLeft}}, now)
+		expected := []Msg{motion(3), MouseMsg{Type: MouseLeft}}
+		if !reflect.DeepEqual(out, expected) {
+			t.Fatalf("expected %v, got %v", expected, out)
+		}
+
+		// Once the interval has elapsed motion is delivered again.
+		out = th.filter([]Msg{motion(4)}, now.Add(time.Hour))
+		if expected := []Msg{motion(4)}; !reflect.DeepEqual(out, expected) {
+			t.Fatalf("expected %v, got %v", expected, out)
+		}
+	})
+
+	t.Run("latest motion is delivered after the interval", func(t *testing.T) {
+		sent := make(chan Msg, 1)
+		th := newMouseMotionThrottle(time.Millisecond*10, func(msg Msg) {
+			sent <- msg
+		})
+
+		th.filter([]Msg{motion(1), motion(2), motion(3)}, time.Now())
+
+		select {
+		case msg := <-sent:
+			if msg != motion(3) {
+				t.Fatalf("expected %v, got %v", motion(3), msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the held back motion to be delivered")
+		}
+	})
+}
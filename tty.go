@@ -1,7 +1,10 @@
 package tea
 
 import (
+	"bytes"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"time"
@@ -11,6 +14,20 @@ import (
 	"golang.org/x/term"
 )
 
+// RawModeUnavailableMsg is sent to Update when the input is a file, but the
+// terminal can't be put in raw mode, usually because it isn't a terminal at
+// all, such as when input is piped in. Err says why.
+//
+// The program carries on regardless, but input arrives the way the terminal
+// or the pipe delivers it: on a terminal, that's a line at a time once enter
+// is pressed, with the keys echoed, and ctrl+c interrupting the program
+// rather than arriving as a key. Mouse events are garbled or don't arrive at
+// all. It's sent when the program starts, and again whenever it takes back
+// the terminal, such as after Exec.
+type RawModeUnavailableMsg struct {
+	Err error
+}
+
 func (p *Program) initTerminal() error {
 	err := p.initInput()
 	if err != nil {
@@ -18,23 +35,59 @@ func (p *Program) initTerminal() error {
 	}
 
 	if p.console != nil {
-		err = p.console.SetRaw()
-		if err != nil {
-			return err
+		// Raw mode also turns off CR/LF translation of input, which would
+		// corrupt X10 mouse events.
+		if err := p.console.SetRaw(); err != nil {
+			p.rawModeErr = err
 		}
 	}
+	if p.rawModeErr != nil {
+		// Carry on with the input as it is, but let the program know.
+		go p.Send(RawModeUnavailableMsg{Err: p.rawModeErr})
+		p.rawModeErr = nil
+	}
 
-	p.renderer.hideCursor()
+	if !p.startupOptions.has(withShownCursor) {
+		p.renderer.hideCursor()
+	}
 	return nil
 }
 
 // restoreTerminalState restores the terminal to the state prior to running the
 // Bubble Tea program.
 func (p *Program) restoreTerminalState() error {
+	p.restoreTerminalModes()
+	return p.restoreTTY()
+}
+
+// restoreTerminalModes turns off the modes the program turned on.
+func (p *Program) restoreTerminalModes() {
 	if p.renderer != nil {
 		p.renderer.showCursor()
 		p.renderer.disableMouseCellMotion()
 		p.renderer.disableMouseAllMotion()
+		if p.startupOptions.has(withDECLocator) {
+			p.renderer.execute(disableDECLocatorSeq)
+		}
+		if p.startupOptions.has(withMouseHighlight) {
+			p.renderer.execute(disableMouseHighlightSeq)
+		}
+		if p.inBandResize() {
+			p.renderer.execute(disableInBandResizeSeq)
+		}
+
+		if p.renderer.reverseVideo() {
+			p.renderer.setReverseVideo(false)
+		}
+		if p.renderer.alternateScroll() {
+			p.renderer.setAlternateScroll(false)
+		}
+		if p.renderer.insertMode() {
+			p.renderer.setInsertMode(false)
+		}
+		if p.renderer.cursorStyle() != cursorStyleDefault {
+			p.renderer.setCursorStyle(cursorStyleDefault)
+		}
 
 		if p.renderer.altScreen() {
 			p.renderer.exitAltScreen()
@@ -43,7 +96,10 @@ func (p *Program) restoreTerminalState() error {
 			time.Sleep(time.Millisecond * 10)
 		}
 	}
+}
 
+// restoreTTY takes the terminal out of raw mode and restores the input.
+func (p *Program) restoreTTY() error {
 	if p.console != nil {
 		err := p.console.Reset()
 		if err != nil {
@@ -71,12 +127,41 @@ func (p *Program) initCancelReader() error {
 func (p *Program) readLoop() {
 	defer close(p.readLoopDone)
 
+	// the last message read, for dropping duplicate motion events
+	var last Msg
+
+	// the mouse button pressed last, for annotating releases
+	var pressed MouseEventType
+
+	// the size of a cell in pixels, for finding the cells of mouse events
+	// reported in pixels
+	var cell CellSizeMsg
+
+	// whether the last bulk input ended with a CR, for normalizing a CRLF
+	// split across reads
+	var cr bool
+
+	var input io.Reader = p.cancelReader
+	if p.inputDebug != nil {
+		input = &inputDebugReader{r: input, w: p.inputDebug}
+	}
+	if p.inputReadTimeout > 0 {
+		seq := newSequenceReader(input, p.inputReadTimeout)
+		defer seq.close()
+		input = seq
+	}
+	var raw *rawInputReader
+	if p.startupOptions.has(withRawInput) || p.startupOptions.has(withRawInputOnly) {
+		raw = &rawInputReader{r: input}
+		input = raw
+	}
+
 	for {
 		if p.ctx.Err() != nil {
 			return
 		}
 
-		msgs, err := readInputs(p.cancelReader)
+		msgs, err := readInputs(input, p.startupOptions.has(withMousePixels))
 		if err != nil {
 			if !errors.Is(err, io.EOF) && !errors.Is(err, cancelreader.ErrCanceled) {
 				select {
@@ -88,10 +173,223 @@ func (p *Program) readLoop() {
 			return
 		}
 
+		msgs = p.handleUnknownSequences(msgs)
+		if p.startupOptions.has(withBulkInputDetection) {
+			msgs = bulkInput(msgs)
+		}
+		if p.startupOptions.has(withPasteNormalization) {
+			msgs, cr = normalizeLineEndings(msgs, cr)
+		}
+		if p.startupOptions.has(withMousePixels) {
+			msgs, cell = deriveMouseCells(msgs, cell)
+		}
+		if p.startupOptions.has(withMouseHighlight) {
+			p.startHighlights(msgs)
+		}
+		if p.viewport.valid() {
+			msgs = viewportMouseEvents(msgs, p.viewport)
+		}
+		msgs, pressed = annotateReleases(msgs, pressed)
+		if p.mouseFilter != nil {
+			msgs = p.mouseFilter.filter(msgs)
+		}
+		if p.startupOptions.has(withReportScroll) {
+			msgs = coalesceWheelEvents(msgs)
+		}
+		msgs, last = dropDuplicateMotions(msgs, last)
+		if p.mouseMotionThrottle != nil {
+			msgs = p.mouseMotionThrottle.filter(msgs, time.Now())
+		}
+		if p.scrollGrouper != nil {
+			msgs = p.scrollGrouper.filter(msgs, time.Now())
+		}
+		msgs = p.hover.filter(msgs)
+
+		if raw != nil {
+			if p.startupOptions.has(withRawInputOnly) {
+				msgs = []Msg{RawMsg(raw.last)}
+			} else {
+				msgs = append([]Msg{RawMsg(raw.last)}, msgs...)
+			}
+		}
+
 		for _, msg := range msgs {
-			p.msgs <- msg
+			select {
+			case <-p.ctx.Done():
+				return
+			case p.msgs <- msg:
+			}
+		}
+	}
+}
+
+// inputDebugReader writes a hex dump of everything read from r to w. The
+// bytes read are passed on untouched.
+type inputDebugReader struct {
+	r io.Reader
+	w io.Writer
+}
+
+func (d *inputDebugReader) Read(b []byte) (int, error) {
+	n, err := d.r.Read(b)
+	if n > 0 {
+		_, _ = fmt.Fprintf(d.w, "read %d bytes: %q\n%s", n, b[:n], hex.Dump(b[:n]))
+	}
+	return n, err
+}
+
+// sequenceReader reads from r, and when a read ends in the middle of an escape
+// sequence, waits up to timeout for the rest of it to arrive so that the
+// sequence is returned in one piece. Reads happen in a goroutine, so that
+// waiting can time out.
+type sequenceReader struct {
+	timeout time.Duration
+	reads   chan sequenceRead
+	done    chan struct{}
+
+	// bytes read but not yet returned, and the error to return after them
+	buf []byte
+	err error
+}
+
+type sequenceRead struct {
+	b   []byte
+	err error
+}
+
+func newSequenceReader(r io.Reader, timeout time.Duration) *sequenceReader {
+	s := &sequenceReader{
+		timeout: timeout,
+		reads:   make(chan sequenceRead),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		for {
+			var buf [256]byte
+			n, err := r.Read(buf[:])
+			select {
+			case s.reads <- sequenceRead{b: buf[:n], err: err}:
+			case <-s.done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s *sequenceReader) Read(b []byte) (int, error) {
+	if len(s.buf) == 0 && s.err == nil {
+		read := <-s.reads
+		s.buf, s.err = read.b, read.err
+		if s.err == nil && incompleteSequence(s.buf) {
+			s.completeSequence()
 		}
 	}
+	if len(s.buf) == 0 {
+		// Once the underlying reader fails, the goroutine reading from it
+		// is gone, so keep returning the error.
+		return 0, s.err
+	}
+
+	n := copy(b, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// completeSequence appends what's read next to the buffer, for as long as it
+// ends in an incomplete escape sequence and the rest arrives in time.
+func (s *sequenceReader) completeSequence() {
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+
+	for s.err == nil && incompleteSequence(s.buf) {
+		select {
+		case read := <-s.reads:
+			s.buf = append(s.buf, read.b...)
+			s.err = read.err
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+// close stops the goroutine reading from the underlying reader, once its
+// current read returns.
+func (s *sequenceReader) close() {
+	close(s.done)
+}
+
+// incompleteSequence reports whether b ends in an escape sequence that's been
+// cut off: a lone ESC, a CSI or SS3 sequence missing its final byte, an X10
+// mouse event missing some of its bytes, or an OSC sequence missing its
+// terminator.
+func incompleteSequence(b []byte) bool {
+	i := bytes.LastIndexByte(b, '\x1b')
+	if i < 0 {
+		return false
+	}
+	seq := b[i+1:]
+	if len(seq) == 0 {
+		return true
+	}
+
+	switch seq[0] {
+	case '[':
+		if len(seq) >= 2 && seq[1] == 'M' {
+			return len(seq) < 5
+		}
+		for _, c := range seq[1:] {
+			if c >= 0x40 && c <= 0x7e {
+				return false
+			}
+		}
+		return true
+	case 'O':
+		return len(seq) < 2
+	case ']':
+		return bytes.IndexByte(seq, '\a') < 0
+	case 'P':
+		// DCS sequences only end with ST, whose ESC would be the last one.
+		return true
+	}
+	return false
+}
+
+// rawInputReader keeps a copy of the bytes of the last read from r.
+type rawInputReader struct {
+	r    io.Reader
+	last []byte
+}
+
+func (c *rawInputReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.last = append([]byte(nil), b[:n]...)
+	return n, err
+}
+
+// handleUnknownSequences hands the unrecognized sequences among the given
+// messages to the program's unknown sequence handler, replacing them with the
+// messages it returns. Sequences are dropped if there's no handler or it
+// returns nil.
+func (p *Program) handleUnknownSequences(msgs []Msg) []Msg {
+	out := msgs[:0]
+	for _, msg := range msgs {
+		seq, ok := msg.(unknownSequenceMsg)
+		if !ok {
+			out = append(out, msg)
+			continue
+		}
+		if p.unknownSequenceHandler == nil {
+			continue
+		}
+		if msg := p.unknownSequenceHandler([]byte(seq)); msg != nil {
+			out = append(out, msg)
+		}
+	}
+	return out
 }
 
 // waitForReadLoop waits for the cancelReader to finish its read loop.
@@ -108,24 +406,60 @@ func (p *Program) waitForReadLoop() {
 // checkResize detects the current size of the output and informs the program
 // via a WindowSizeMsg.
 func (p *Program) checkResize() {
+	if p.windowSizeFunc != nil {
+		p.sendWindowSize(p.windowSizeFunc)
+		return
+	}
+
 	f, ok := p.output.TTY().(*os.File)
 	if !ok || !isatty.IsTerminal(f.Fd()) {
 		// can't query window size
 		return
 	}
 
-	w, h, err := term.GetSize(int(f.Fd()))
-	if err != nil {
+	p.sendWindowSize(func() (int, int, error) {
+		return term.GetSize(int(f.Fd()))
+	})
+}
+
+// How often and how long apart we query the window size again when it's
+// reported as zero.
+const (
+	windowSizeRetries       = 10
+	windowSizeRetryInterval = 20 * time.Millisecond
+)
+
+// sendWindowSize queries the window size with the given function and sends it
+// to the program. Some terminals briefly report a width or height of zero
+// while starting up or resizing, which we don't pass on. Instead, we query the
+// size again a little later, until it's valid or we give up.
+func (p *Program) sendWindowSize(getSize func() (int, int, error)) {
+	for i := 0; ; i++ {
+		w, h, err := getSize()
+		if err != nil {
+			select {
+			case <-p.ctx.Done():
+			case p.errs <- err:
+			}
+
+			return
+		}
+
+		if w > 0 && h > 0 {
+			p.Send(WindowSizeMsg{
+				Width:  w,
+				Height: h,
+			})
+			return
+		}
+
+		if i == windowSizeRetries {
+			return
+		}
 		select {
 		case <-p.ctx.Done():
-		case p.errs <- err:
+			return
+		case <-time.After(windowSizeRetryInterval):
 		}
-
-		return
 	}
-
-	p.Send(WindowSizeMsg{
-		Width:  w,
-		Height: h,
-	})
 }
@@ -14,7 +14,9 @@ func (p *Program) initInput() error {
 	if f, ok := p.input.(*os.File); ok {
 		c, err := console.ConsoleFromFile(f)
 		if err != nil {
-			return nil //nolint:nilerr // ignore error, this was just a test
+			// Not a terminal, so there's no raw mode to enter.
+			p.rawModeErr = err
+			return nil
 		}
 		p.console = c
 	}
@@ -40,3 +42,9 @@ func openInputTTY() (*os.File, error) {
 	}
 	return f, nil
 }
+
+// terminalHasAltScreen reports whether the terminal supports the alternate
+// screen buffer, according to its terminfo entry.
+func terminalHasAltScreen(getenv func(string) string) bool {
+	return terminfoHasAltScreen(getenv)
+}
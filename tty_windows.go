@@ -16,6 +16,9 @@ func (p *Program) initInput() error {
 		// input. We do this so we can hand input off to containerd/console to
 		// set raw mode, and do it in this fashion because the method
 		// console.ConsoleFromFile isn't supported on Windows.
+		//
+		// Note that this swaps out the process-wide stdin, which is why only
+		// one Program should own the terminal at a time.
 		p.windowsStdin = os.Stdin
 		os.Stdin = f
 
@@ -45,3 +48,10 @@ func openInputTTY() (*os.File, error) {
 	}
 	return f, nil
 }
+
+// terminalHasAltScreen reports whether the terminal supports the alternate
+// screen buffer. There's no terminfo on Windows, but consoles that handle
+// escape sequences handle this one too.
+func terminalHasAltScreen(func(string) string) bool {
+	return true
+}
@@ -0,0 +1,24 @@
+package tea
+
+import "fmt"
+
+// UpdateTyped calls Update on the given model and returns the resulting model
+// as the same concrete type, saving you from type asserting it yourself. It's
+// handy when composing models:
+//
+//	func (m parent) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+//	    var cmd tea.Cmd
+//	    m.child, cmd = tea.UpdateTyped(m.child, msg)
+//	    return m, cmd
+//	}
+//
+// UpdateTyped panics if Update returns a model of a different type, which is
+// almost certainly a bug in the child model.
+func UpdateTyped[T Model](m T, msg Msg) (T, Cmd) {
+	model, cmd := m.Update(msg)
+	t, ok := model.(T)
+	if !ok {
+		panic(fmt.Sprintf("tea: Update of %T returned a model of type %T", m, model))
+	}
+	return t, cmd
+}
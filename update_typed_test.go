@@ -0,0 +1,48 @@
+package tea
+
+import "testing"
+
+type testTypedModel struct {
+	count int
+}
+
+func (m testTypedModel) Init() Cmd {
+	return nil
+}
+
+func (m testTypedModel) Update(msg Msg) (Model, Cmd) {
+	switch msg.(type) {
+	case incrementMsg:
+		m.count++
+		return m, Quit
+	case KeyMsg:
+		// Return a model of a different type.
+		return &testModel{}, nil
+	}
+	return m, nil
+}
+
+func (m testTypedModel) View() string {
+	return ""
+}
+
+func TestUpdateTyped(t *testing.T) {
+	t.Run("same type", func(t *testing.T) {
+		m, cmd := UpdateTyped(testTypedModel{}, incrementMsg{})
+		if m.count != 1 {
+			t.Errorf("expected count to be 1, got %d", m.count)
+		}
+		if cmd == nil {
+			t.Errorf("expected a command")
+		}
+	})
+
+	t.Run("different type", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected a panic")
+			}
+		}()
+		UpdateTyped(testTypedModel{}, KeyMsg{})
+	})
+}
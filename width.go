@@ -0,0 +1,13 @@
+package tea
+
+import "github.com/muesli/ansi"
+
+// CellWidth returns the number of terminal columns s takes up when printed.
+// ANSI escape sequences take up no room, wide runes such as CJK characters
+// take up two columns, and combining marks take up none.
+//
+// Use it instead of len when laying out views: len counts bytes, which only
+// matches the width for plain ASCII.
+func CellWidth(s string) int {
+	return ansi.PrintableRuneWidth(s)
+}
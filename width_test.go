@@ -0,0 +1,29 @@
+package tea
+
+import "testing"
+
+func TestCellWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{"empty", "", 0},
+		{"ascii", "hello", 5},
+		{"cjk", "日本語", 6},
+		{"mixed cjk", "a日b", 4},
+		{"combining mark", "é", 1},
+		{"combining marks", "à́̂b", 2},
+		{"sgr", "\x1b[1;31mred\x1b[0m", 3},
+		{"sgr around cjk", "\x1b[38;5;200m漢字\x1b[m!", 5},
+		{"only sgr", "\x1b[0m", 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CellWidth(tc.s); got != tc.want {
+				t.Errorf("CellWidth(%q) = %d, want %d", tc.s, got, tc.want)
+			}
+		})
+	}
+}